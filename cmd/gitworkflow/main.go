@@ -1,171 +1,1180 @@
 package main
 
 import (
+	"bufio"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/thomaschangsf/vamos/internal/llm"
+	"github.com/thomaschangsf/vamos/pkg/config"
 	"github.com/thomaschangsf/vamos/pkg/gitworkflow"
 )
 
 func main() {
-	// Create workflow manager
-	wm := gitworkflow.NewWorkflowManager()
+	// Load configuration
+	cfg := config.NewConfig()
+
+	// Global flags come before the subcommand; --dry-run is also accepted after it
+	globalFlags := flag.NewFlagSet("vamosGitWF", flag.ContinueOnError)
+	dryRun := globalFlags.Bool("dry-run", false, "Show the git commands that would change the repository without running them")
+	workspaceFile := globalFlags.String("workspace", "", "Run story-start, sync, story-push or tag in every repository listed in this workspace file, e.g. "+gitworkflow.WorkspaceFileName)
+	outputFormat := globalFlags.String("output", "text", outputUsage)
+	if err := globalFlags.Parse(os.Args[1:]); err != nil {
+		flagError(err)
+	}
+	args := globalFlags.Args()
+	setOutput(*outputFormat)
 
 	// Define subcommands
-	storyStartCmd := flag.NewFlagSet("story-start", flag.ExitOnError)
+	storyStartCmd := flag.NewFlagSet("story-start", flag.ContinueOnError)
 	storyID := storyStartCmd.String("id", "", "Story ID (required)")
 	description := storyStartCmd.String("description", "", "Story description (optional)")
+	startAutostash := storyStartCmd.Bool("autostash", false, "Stash uncommitted changes, including untracked files, and reapply them on the new branch")
+	startWorktree := storyStartCmd.Bool("worktree", false, "Create the branch in a new worktree next to the main one instead of switching branches here")
+
+	storyCommitCmd := flag.NewFlagSet("story-commit", flag.ContinueOnError)
+	commitType := storyCommitCmd.String("type", "", "Commit type, e.g. feat, fix, chore (default: from config)")
+	scope := storyCommitCmd.String("scope", "", "Commit scope (optional)")
+	commitDesc := storyCommitCmd.String("description", "", "Commit description (required unless --ai is given)")
+	commitBody := storyCommitCmd.String("body", "", "Commit body (optional)")
+	breaking := storyCommitCmd.Bool("breaking", false, "Mark the commit as a breaking change with '!'")
+	breakingDesc := storyCommitCmd.String("breaking-description", "", "Describe the breaking change in a BREAKING CHANGE footer")
+	stagedOnly := storyCommitCmd.Bool("staged", false, "Commit only already-staged changes")
+	commitPaths := storyCommitCmd.String("paths", "", "Comma-separated files or directories to stage (also accepted as arguments)")
+	commitGlobs := storyCommitCmd.String("glob", "", "Comma-separated glob patterns of changed files to stage, e.g. 'pkg/**/*.go'")
+	interactive := storyCommitCmd.Bool("interactive", false, "Show each changed file's diff and choose whether to stage it")
+	commitAI := storyCommitCmd.Bool("ai", false, "Suggest the commit message from the staged diff with the language model (needs LLM_API_KEY)")
+	commitHint := storyCommitCmd.String("hint", "", "Describe the change in your own words to guide the suggested message (with --ai)")
+
+	storyPushCmd := flag.NewFlagSet("story-push", flag.ContinueOnError)
+	skipChecks := storyPushCmd.Bool("skip-checks", false, "Push without running the push.checks from the config")
+
+	storyPRCmd := flag.NewFlagSet("story-pr", flag.ContinueOnError)
+	prTitle := storyPRCmd.String("title", "", "Pull request title (default: story ID and description from the branch name)")
+	prBody := storyPRCmd.String("body", "", "Pull request body (default: pull_request.body_template rendered with the story's commits)")
+	prDraft := storyPRCmd.Bool("draft", false, "Open the pull request as a draft (default: pull_request.draft)")
+	prReviewers := storyPRCmd.String("reviewers", "", "Comma-separated users or org/team slugs to request reviews from (default: pull_request.reviewers)")
+	prLabels := storyPRCmd.String("labels", "", "Comma-separated labels to add (default: pull_request.labels)")
+	prBodyFile := storyPRCmd.String("body-file", "", "Read the pull request body from this file, e.g. one written by 'pr-describe --file'")
+
+	storyListCmd := flag.NewFlagSet("story-list", flag.ContinueOnError)
+	listFetch := storyListCmd.Bool("fetch", false, "Fetch the remote first so its story branches are current")
+
+	storySwitchCmd := flag.NewFlagSet("story-switch", flag.ContinueOnError)
+
+	storyFinishCmd := flag.NewFlagSet("story-finish", flag.ContinueOnError)
+	finishID := storyFinishCmd.String("id", "", "Story ID to finish (default: the current story branch)")
+
+	storySquashCmd := flag.NewFlagSet("story-squash", flag.ContinueOnError)
+	squashType := storySquashCmd.String("type", "", "Commit type (default: from the oldest Conventional Commit on the branch)")
+	squashScope := storySquashCmd.String("scope", "", "Commit scope (optional)")
+	squashDesc := storySquashCmd.String("description", "", "Commit description (default: from the oldest Conventional Commit on the branch)")
+	squashBody := storySquashCmd.String("body", "", "Commit body (optional)")
+	squashCombine := storySquashCmd.Bool("combine-bodies", false, "Add the original commit messages to the body")
+
+	storyFixupCmd := flag.NewFlagSet("story-fixup", flag.ContinueOnError)
+	fixupStaged := storyFixupCmd.Bool("staged", false, "Use only already-staged changes")
 
-	storyCommitCmd := flag.NewFlagSet("story-commit", flag.ExitOnError)
-	scope := storyCommitCmd.String("scope", "", "Commit scope (required)")
-	commitDesc := storyCommitCmd.String("description", "", "Commit description (required)")
+	statusCmd := flag.NewFlagSet("status", flag.ContinueOnError)
+	statusFetch := statusCmd.Bool("fetch", false, "Fetch the remote first so the ahead/behind counts are current")
+	statusJSON := statusCmd.Bool("json", false, "Print the status as JSON; short for --output json")
 
-	undoCmd := flag.NewFlagSet("undo", flag.ExitOnError)
+	worktreeListCmd := flag.NewFlagSet("worktree-list", flag.ContinueOnError)
+
+	worktreeRemoveCmd := flag.NewFlagSet("worktree-remove", flag.ContinueOnError)
+	worktreeForce := worktreeRemoveCmd.Bool("force", false, "Remove the worktree even if it has uncommitted changes, which are lost")
+
+	pruneCmd := flag.NewFlagSet("prune", flag.ContinueOnError)
+	pruneStaleDays := pruneCmd.Int("stale-days", 0, "Also offer unmerged branches without commits for this many days (default: prune.stale_days)")
+	pruneRemote := pruneCmd.Bool("remote", true, "Also offer branches on the remote")
+	pruneYes := pruneCmd.Bool("yes", false, "Delete without asking for confirmation")
+
+	prDescribeCmd := flag.NewFlagSet("pr-describe", flag.ContinueOnError)
+	describeBranch := prDescribeCmd.String("branch", "", "Branch to describe (default: the current branch)")
+	describeFile := prDescribeCmd.String("file", "", "Write the description to this file instead of printing it")
+
+	undoCmd := flag.NewFlagSet("undo", flag.ContinueOnError)
 	hard := undoCmd.Bool("hard", false, "Discard changes (default: keep changes)")
 
-	revertCmd := flag.NewFlagSet("revert", flag.ExitOnError)
+	revertCmd := flag.NewFlagSet("revert", flag.ContinueOnError)
 	commitHash := revertCmd.String("commit", "", "Commit hash to revert (required)")
 
-	tagCmd := flag.NewFlagSet("tag", flag.ExitOnError)
+	tagCmd := flag.NewFlagSet("tag", flag.ContinueOnError)
 	version := tagCmd.String("version", "", "Version number (e.g., v1.0.3) (required)")
 	tagMessage := tagCmd.String("message", "", "Tag message (required)")
 	pushTag := tagCmd.Bool("push", false, "Push tag to remote")
 
-	syncCmd := flag.NewFlagSet("sync", flag.ExitOnError)
-	syncMain := syncCmd.Bool("main", false, "Sync main branch (default: sync current branch)")
+	syncCmd := flag.NewFlagSet("sync", flag.ContinueOnError)
+	syncMain := syncCmd.Bool("main", false, "Sync the base branch (default: sync current branch)")
+	syncStrategy := syncCmd.String("strategy", "", "How to reconcile a diverged branch: rebase or merge (default: sync.strategy from .vamos.yaml)")
+	syncPush := syncCmd.Bool("push", false, "Push local commits once the branch is up to date, publishing it if it has no remote branch")
+	syncAutostash := syncCmd.Bool("autostash", false, "Stash uncommitted changes, including untracked files, while syncing and reapply them afterwards")
+	syncStrict := syncCmd.Bool("strict", false, "Fail with exit status 6 when the branch is only ahead and --push is not given")
 
-	resolveCmd := flag.NewFlagSet("resolve", flag.ExitOnError)
+	resolveCmd := flag.NewFlagSet("resolve", flag.ContinueOnError)
 	useRebase := resolveCmd.Bool("rebase", true, "Use rebase to resolve conflicts (default: true)")
+	resolveContinue := resolveCmd.Bool("continue", false, "Continue the rebase or merge once every conflict is resolved")
+	resolveAbort := resolveCmd.Bool("abort", false, "Abort the rebase or merge and restore the branch")
+	resolveOurs := resolveCmd.Bool("ours", false, "Keep our side of the conflicted files given as arguments (default: all)")
+	resolveTheirs := resolveCmd.Bool("theirs", false, "Keep their side of the conflicted files given as arguments (default: all)")
+	resolveMark := resolveCmd.Bool("mark", false, "Stage the files given as arguments after fixing their conflicts by hand (default: all)")
+	resolveInteractive := resolveCmd.Bool("interactive", false, "Choose ours, theirs or edited for each conflicted file")
+
+	changelogCmd := flag.NewFlagSet("changelog", flag.ContinueOnError)
+	changelogFrom := changelogCmd.String("from", "", "Start revision, exclusive (default: the tag before --to)")
+	changelogTo := changelogCmd.String("to", "HEAD", "End revision, inclusive")
+	changelogFormat := changelogCmd.String("format", "markdown", "Output format: markdown or json")
+	changelogPrepend := changelogCmd.String("prepend", "", "Prepend the markdown section to this changelog file instead of printing it")
+
+	releaseCmd := flag.NewFlagSet("release", flag.ContinueOnError)
+	releasePre := releaseCmd.String("pre", "", "Create a pre-release with this identifier, e.g. rc gives v1.2.0-rc.1")
+	releaseBuild := releaseCmd.String("build", "", "Build metadata to append, e.g. 20261016 gives v1.2.0+20261016")
+	releaseBump := releaseCmd.String("bump", "", "Force the bump instead of computing it: major, minor or patch")
+	releaseMessage := releaseCmd.String("message", "", "Tag message (default: 'Release <version>')")
+	releasePush := releaseCmd.Bool("push", false, "Push the tag to remote")
+
+	backupsCmd := flag.NewFlagSet("backups", flag.ContinueOnError)
+
+	flagSets := map[string]*flag.FlagSet{
+		"story-start":     storyStartCmd,
+		"story-commit":    storyCommitCmd,
+		"story-push":      storyPushCmd,
+		"story-pr":        storyPRCmd,
+		"pr-describe":     prDescribeCmd,
+		"story-list":      storyListCmd,
+		"story-switch":    storySwitchCmd,
+		"story-finish":    storyFinishCmd,
+		"prune":           pruneCmd,
+		"story-squash":    storySquashCmd,
+		"story-fixup":     storyFixupCmd,
+		"status":          statusCmd,
+		"worktree-list":   worktreeListCmd,
+		"worktree-remove": worktreeRemoveCmd,
+		"undo":            undoCmd,
+		"revert":          revertCmd,
+		"tag":             tagCmd,
+		"sync":            syncCmd,
+		"resolve":         resolveCmd,
+		"changelog":       changelogCmd,
+		"release":         releaseCmd,
+		"backups":         backupsCmd,
+	}
+	for _, flagSet := range flagSets {
+		flagSet.BoolVar(dryRun, "dry-run", *dryRun, "Show the git commands that would change the repository without running them")
+		flagSet.StringVar(outputFormat, "output", *outputFormat, outputUsage)
+	}
 
 	// Check if a subcommand was provided
-	if len(os.Args) < 2 {
-		fmt.Println("Expected one of: 'story-start', 'story-commit', 'story-push', 'undo', 'revert', 'tag', 'sync', 'resolve', or 'example'")
-		os.Exit(1)
+	if len(args) < 1 {
+		usage(nil, "Expected one of: 'story-start', 'story-commit', 'story-push', 'story-pr', 'pr-describe', 'story-list', 'story-switch', 'story-finish', 'story-squash', 'story-fixup', 'status', 'worktree-list', 'worktree-remove', 'prune', 'undo', 'revert', 'tag', 'release', 'sync', 'resolve', 'changelog', 'backups', 'config', or 'example'")
 	}
 
 	// Parse the subcommand
-	switch os.Args[1] {
+	command := args[0]
+	if flagSet, ok := flagSets[command]; ok {
+		if err := flagSet.Parse(args[1:]); err != nil {
+			flagError(err)
+		}
+	}
+	if command == "status" && *statusJSON {
+		*outputFormat = "json"
+	}
+	setOutput(*outputFormat)
+	jsonResult.Operation = command
+	jsonResult.DryRun = *dryRun
+
+	if *workspaceFile != "" {
+		workspace, err := loadWorkspace(cfg, *workspaceFile)
+		if err != nil {
+			fail(err)
+		}
+		if *dryRun {
+			workspace.EnableDryRun(os.Stdout)
+		}
+
+		var results []gitworkflow.RepoResult
+		switch command {
+		case "story-start":
+			if *storyID == "" {
+				usage(nil, "Error: --id is required")
+			}
+			if *startWorktree {
+				usage(nil, "Error: --worktree cannot be combined with --workspace")
+			}
+			results, err = workspace.StartStory(*storyID, *description, gitworkflow.StartOptions{Autostash: *startAutostash})
+		case "sync":
+			if *syncMain {
+				results, err = workspace.Run(func(wm *gitworkflow.WorkflowManager) (string, error) {
+					return "synced base branch with remote", wm.SyncMainBranch()
+				})
+			} else {
+				results, err = workspace.Sync(gitworkflow.SyncOptions{Strategy: *syncStrategy, Push: *syncPush, Autostash: *syncAutostash, Strict: *syncStrict})
+			}
+		case "story-push":
+			results, err = workspace.PushStory(gitworkflow.PushOptions{SkipChecks: *skipChecks})
+		case "tag":
+			if *version == "" || *tagMessage == "" {
+				usage(nil, "Error: --version and --message are required")
+			}
+			jsonResult.Tag = *version
+			results, err = workspace.Tag(*version, *tagMessage, *pushTag)
+		default:
+			usage(nil, "Error: %s does not support --workspace; use story-start, sync, story-push or tag", command)
+		}
+		jsonResult.Repos = results
+		reportWorkspace(*dryRun, results)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			stop(err)
+		}
+		exit(gitworkflow.ExitOK)
+	}
+
+	// openRepository loads the repository's workflow config. Commands call it
+	// once their arguments are checked, so usage errors, example and config
+	// show work outside a repository too.
+	openRepository := func() *gitworkflow.WorkflowManager {
+		wm, err := newWorkflowManager(cfg, "")
+		if err != nil {
+			fail(err)
+		}
+		if *dryRun {
+			wm.EnableDryRun(os.Stdout)
+			skippedCommands = wm.SkippedCommands
+		}
+		if jsonOutput != nil {
+			if branch, err := wm.GetCurrentBranch(); err == nil && branch != "HEAD" {
+				jsonResult.Branch = branch
+			}
+		}
+		return wm
+	}
+
+	switch command {
 	case "example":
-		wm.PrintExample()
-		os.Exit(0)
+		gitworkflow.NewWorkflowManager().PrintExample()
 
 	case "story-start":
-		storyStartCmd.Parse(os.Args[2:])
 		if *storyID == "" {
-			fmt.Println("Error: --id is required")
-			storyStartCmd.PrintDefaults()
-			os.Exit(1)
+			usage(storyStartCmd, "Error: --id is required")
 		}
-		err := wm.CreateStoryBranch(*storyID, *description)
-		if err != nil {
-			log.Fatal(err)
+		if *startWorktree && *startAutostash {
+			usage(nil, "Error: --autostash cannot be combined with --worktree, which leaves this working tree as it is")
 		}
-		if *description != "" {
-			fmt.Printf("Created and switched to branch: W-%s-%s\n", *storyID, *description)
-		} else {
-			fmt.Printf("Created and switched to branch: W-%s\n", *storyID)
+		wm := openRepository()
+		if *startWorktree {
+			worktree, err := wm.StartStoryInWorktree(*storyID, *description)
+			if err != nil {
+				fail(err)
+			}
+			jsonResult.Branch = worktree.Branch
+			jsonResult.Data = worktree
+			report(*dryRun, "Created branch %s in worktree %s\nStart working on it with: cd %s", worktree.Branch, worktree.Path, worktree.Path)
+			break
 		}
+		branchName, err := wm.StartStory(*storyID, *description, gitworkflow.StartOptions{Autostash: *startAutostash})
+		if err != nil {
+			fail(err)
+		}
+		jsonResult.Branch = branchName
+		report(*dryRun, "Created and switched to branch: %s", branchName)
 
 	case "story-commit":
-		storyCommitCmd.Parse(os.Args[2:])
-		if *scope == "" || *commitDesc == "" {
-			fmt.Println("Error: --scope and --description are required")
-			storyCommitCmd.PrintDefaults()
-			os.Exit(1)
+		if *commitDesc == "" && !*commitAI {
+			usage(storyCommitCmd, "Error: --description is required")
+		}
+		staging := gitworkflow.StageOptions{
+			StagedOnly:  *stagedOnly,
+			Paths:       append(splitList(*commitPaths), storyCommitCmd.Args()...),
+			Globs:       splitList(*commitGlobs),
+			Interactive: *interactive,
+			In:          os.Stdin,
+			Out:         os.Stdout,
+		}
+		wm := openRepository()
+		var message gitworkflow.CommitMessage
+		var err error
+		if *commitAI {
+			message, err = wm.CommitStoryWithSuggestion(staging, *commitHint, os.Stdin, os.Stdout)
+			if errors.Is(err, gitworkflow.ErrCommitRejected) {
+				fmt.Println("Commit message rejected; the index is back as it was")
+				stop(err)
+			}
+		} else {
+			message, err = wm.CommitStory(gitworkflow.CommitMessage{
+				Type:           *commitType,
+				Scope:          *scope,
+				Description:    *commitDesc,
+				Body:           *commitBody,
+				Breaking:       *breaking,
+				BreakingChange: *breakingDesc,
+			}, staging)
 		}
-		err := wm.CommitChanges(*scope, *commitDesc)
 		if err != nil {
-			log.Fatal(err)
+			fail(err)
 		}
-		fmt.Printf("Committed changes: feat(%s): %s\n", *scope, *commitDesc)
+		jsonResult.Commits = []gitworkflow.Commit{headCommit(wm, message.Header())}
+		report(*dryRun, "Committed changes: %s", message.Header())
 
 	case "story-push":
-		err := wm.PushStoryBranch()
+		wm := openRepository()
+		err := wm.PushStory(gitworkflow.PushOptions{SkipChecks: *skipChecks})
 		if err != nil {
-			log.Fatal(err)
+			fail(err)
 		}
-		fmt.Println("Pushed branch to remote")
+		report(*dryRun, "Pushed branch to remote")
 
-	case "undo":
-		undoCmd.Parse(os.Args[2:])
-		var err error
-		if *hard {
-			err = wm.UndoLastCommitHard()
-			fmt.Println("Undid last commit and discarded changes")
+	case "story-pr":
+		if *prBody != "" && *prBodyFile != "" {
+			usage(nil, "Error: --body and --body-file cannot be combined")
+		}
+		wm := openRepository()
+		branchName, err := wm.GetCurrentBranch()
+		if err != nil {
+			fail(err)
+		}
+		body := *prBody
+		if *prBodyFile != "" {
+			data, err := os.ReadFile(*prBodyFile)
+			if err != nil {
+				fail(err)
+			}
+			body = string(data)
+		}
+		pr, err := wm.CreatePullRequest(branchName, gitworkflow.PullRequestOptions{
+			Title:     *prTitle,
+			Body:      body,
+			Draft:     *prDraft,
+			Reviewers: splitList(*prReviewers),
+			Labels:    splitList(*prLabels),
+		})
+		if err != nil {
+			fail(err)
+		}
+		jsonResult.Data = pr
+		if pr.Existing {
+			jsonResult.Message = fmt.Sprintf("Pull request #%d is already open: %s", pr.Number, pr.URL)
+			fmt.Println(jsonResult.Message)
 		} else {
-			err = wm.UndoLastCommit()
-			fmt.Println("Undid last commit, changes are in working directory")
+			report(*dryRun, "Opened pull request #%d: %s", pr.Number, pr.URL)
 		}
+
+	case "story-list":
+		wm := openRepository()
+		stories, err := wm.ListStories(gitworkflow.StoryListOptions{Fetch: *listFetch})
 		if err != nil {
-			log.Fatal(err)
+			fail(err)
+		}
+		jsonResult.Data = stories
+		if len(stories) == 0 {
+			fmt.Println("No story branches")
+		}
+		for _, story := range stories {
+			marker := " "
+			if story.Current {
+				marker = "*"
+			}
+			where := "local+remote"
+			switch {
+			case !story.Remote:
+				where = "local"
+			case !story.Local:
+				where = "remote"
+			}
+			merged := ""
+			if story.Merged {
+				merged = "  merged"
+			}
+			fmt.Printf("%s %-8s %-30s %-12s +%d -%d  %s%s\n", marker, story.ID, story.Branch, where,
+				story.Ahead, story.Behind, story.LastCommit.Format("2006-01-02 15:04"), merged)
+		}
+
+	case "story-switch":
+		if storySwitchCmd.NArg() != 1 {
+			usage(nil, "Expected: 'story-switch <story-id>'")
+		}
+		wm := openRepository()
+		branchName, err := wm.SwitchStory(storySwitchCmd.Arg(0))
+		if err != nil {
+			fail(err)
+		}
+		jsonResult.Branch = branchName
+		report(*dryRun, "Switched to branch: %s", branchName)
+
+	case "story-finish":
+		wm := openRepository()
+		result, err := wm.FinishStory(*finishID)
+		if err != nil {
+			fail(err)
+		}
+		jsonResult.Branch = result.Branch
+		jsonResult.Data = result
+		report(*dryRun, "%s is merged; switched to %s", result.Branch, result.Base)
+		if *dryRun {
+			break
+		}
+		if result.DeletedLocal {
+			fmt.Printf("Deleted branch %s\n", result.Branch)
+		}
+		if result.DeletedRemote {
+			fmt.Printf("Deleted branch %s/%s\n", wm.Remote(), result.Branch)
+		}
+
+	case "story-squash":
+		wm := openRepository()
+		result, err := wm.SquashStory(gitworkflow.SquashOptions{
+			Message: gitworkflow.CommitMessage{
+				Type:        *squashType,
+				Scope:       *squashScope,
+				Description: *squashDesc,
+				Body:        *squashBody,
+			},
+			CombineBodies: *squashCombine,
+		})
+		if err != nil {
+			fail(err)
+		}
+		jsonResult.Branch = result.Branch
+		jsonResult.Commits = []gitworkflow.Commit{headCommit(wm, result.Message.Header())}
+		jsonResult.Data = result
+		report(*dryRun, "Squashed %d commits into: %s (a backup was saved, see 'backups list')", result.Squashed, result.Message.Header())
+		if result.Published && !*dryRun {
+			warn("%s/%s still has the old commits; update it with 'git push --force-with-lease'", wm.Remote(), result.Branch)
+		}
+
+	case "story-fixup":
+		if storyFixupCmd.NArg() < 1 {
+			usage(nil, "Expected: 'story-fixup [--staged] <commit> [paths...]'")
+		}
+		wm := openRepository()
+		fixed, err := wm.FixupCommit(storyFixupCmd.Arg(0), gitworkflow.StageOptions{
+			StagedOnly: *fixupStaged,
+			Paths:      storyFixupCmd.Args()[1:],
+		})
+		if err != nil {
+			fail(err)
+		}
+		jsonResult.Commits = []gitworkflow.Commit{*fixed}
+		report(*dryRun, "Folded the changes into %q (a backup was saved, see 'backups list')", fixed.Subject)
+
+	case "status":
+		wm := openRepository()
+		status, err := wm.Status(gitworkflow.StatusOptions{Fetch: *statusFetch})
+		if err != nil {
+			fail(err)
+		}
+		jsonResult.Data = status
+		printStatus(status)
+
+	case "worktree-list":
+		wm := openRepository()
+		worktrees, err := wm.ListWorktrees()
+		if err != nil {
+			fail(err)
+		}
+		jsonResult.Data = worktrees
+		for _, worktree := range worktrees {
+			marker := " "
+			if worktree.Current {
+				marker = "*"
+			}
+			story := worktree.StoryID
+			if story == "" {
+				story = "-"
+			}
+			branch := worktree.Branch
+			if branch == "" {
+				branch = "(detached at " + gitworkflow.ShortHash(worktree.Head) + ")"
+			}
+			var notes []string
+			if worktree.Main {
+				notes = append(notes, "main")
+			}
+			if worktree.Locked {
+				notes = append(notes, "locked")
+			}
+			if worktree.Prunable {
+				notes = append(notes, "prunable")
+			}
+			line := fmt.Sprintf("%s %-8s %-30s %s  %s", marker, story, branch, worktree.Path, strings.Join(notes, ", "))
+			fmt.Println(strings.TrimRight(line, " "))
+		}
+
+	case "worktree-remove":
+		if worktreeRemoveCmd.NArg() != 1 {
+			usage(nil, "Expected: 'worktree-remove [--force] <story-id>'")
+		}
+		wm := openRepository()
+		worktree, err := wm.RemoveWorktree(worktreeRemoveCmd.Arg(0), gitworkflow.WorktreeRemoveOptions{Force: *worktreeForce})
+		if err != nil {
+			fail(err)
+		}
+		jsonResult.Data = worktree
+		report(*dryRun, "Removed worktree %s; branch %s was kept", worktree.Path, worktree.Branch)
+
+	case "prune":
+		wm := openRepository()
+		candidates, err := wm.PruneCandidates(gitworkflow.PruneOptions{StaleDays: *pruneStaleDays, Remote: *pruneRemote})
+		if err != nil {
+			fail(err)
+		}
+		jsonResult.Data = candidates
+		if len(candidates) == 0 {
+			fmt.Println("No branches to prune")
+			break
+		}
+		for _, candidate := range candidates {
+			fmt.Printf("%-40s %s  %s  %s\n", candidate.Name(wm.Remote()), gitworkflow.ShortHash(candidate.Commit),
+				candidate.LastCommit.Format("2006-01-02"), candidate.Reason)
+		}
+		if !*pruneYes && !*dryRun && jsonOutput != nil {
+			// Scripts cannot answer the prompt
+			warn("Nothing deleted; pass --yes to delete these %d branch(es)", len(candidates))
+			break
+		}
+		if !*pruneYes && !*dryRun {
+			fmt.Printf("Delete these %d branch(es) [y/N]? ", len(candidates))
+			answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+			if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+				fmt.Println("Nothing deleted")
+				break
+			}
+		}
+		deleted, err := wm.DeleteBranches(candidates)
+		if err != nil {
+			fail(err)
+		}
+		report(*dryRun, "Deleted %d branch(es); recreate one with 'git branch <name> <commit>' using the commit above", len(deleted))
+
+	case "pr-describe":
+		wm := openRepository()
+		branchName := *describeBranch
+		if branchName == "" {
+			current, err := wm.GetCurrentBranch()
+			if err != nil {
+				fail(err)
+			}
+			branchName = current
+		}
+		description, err := wm.DescribePullRequest(branchName)
+		if err != nil {
+			fail(err)
+		}
+		jsonResult.Branch = branchName
+		jsonResult.Data = description
+		if *describeFile == "" {
+			fmt.Print(description)
+			break
+		}
+		if *dryRun {
+			fmt.Printf("would write to %s:\n\n%s", *describeFile, description)
+			break
+		}
+		if err := os.WriteFile(*describeFile, []byte(description), 0644); err != nil {
+			fail(err)
+		}
+		report(false, "Wrote pull request description to %s", *describeFile)
+
+	case "undo":
+		wm := openRepository()
+		if *hard {
+			if err := wm.UndoLastCommitHard(); err != nil {
+				fail(err)
+			}
+			report(*dryRun, "Undid last commit and discarded changes (a backup was saved, see 'backups list')")
+		} else {
+			if err := wm.UndoLastCommit(); err != nil {
+				fail(err)
+			}
+			report(*dryRun, "Undid last commit, changes are in working directory")
 		}
 
 	case "revert":
-		revertCmd.Parse(os.Args[2:])
 		if *commitHash == "" {
-			fmt.Println("Error: --commit is required")
-			revertCmd.PrintDefaults()
-			os.Exit(1)
+			usage(revertCmd, "Error: --commit is required")
 		}
+		wm := openRepository()
 		err := wm.RevertCommit(*commitHash)
 		if err != nil {
-			log.Fatal(err)
+			fail(err)
 		}
-		fmt.Printf("Reverted commit %s\n", *commitHash)
+		report(*dryRun, "Reverted commit %s", *commitHash)
 
 	case "tag":
-		tagCmd.Parse(os.Args[2:])
 		if *version == "" || *tagMessage == "" {
-			fmt.Println("Error: --version and --message are required")
-			tagCmd.PrintDefaults()
-			os.Exit(1)
+			usage(tagCmd, "Error: --version and --message are required")
 		}
+		jsonResult.Tag = *version
+		wm := openRepository()
 		err := wm.CreateTag(*version, *tagMessage)
 		if err != nil {
-			log.Fatal(err)
+			fail(err)
 		}
-		fmt.Printf("Created tag %s: %s\n", *version, *tagMessage)
-
 		if *pushTag {
 			err = wm.PushTag(*version)
 			if err != nil {
-				log.Fatal(err)
+				fail(err)
+			}
+			report(*dryRun, "Created tag %s: %s and pushed it to remote", *version, *tagMessage)
+		} else {
+			report(*dryRun, "Created tag %s: %s", *version, *tagMessage)
+		}
+
+	case "release":
+		options := gitworkflow.ReleaseOptions{PreRelease: *releasePre, Build: *releaseBuild}
+		if *releaseBump != "" {
+			bump, err := gitworkflow.ParseBump(*releaseBump)
+			if err != nil {
+				usage(releaseCmd, "Error: %v", err)
+			}
+			options.Bump = bump
+		}
+		wm := openRepository()
+		plan, err := wm.PlanRelease(options)
+		if err != nil {
+			fail(err)
+		}
+		jsonResult.Tag = plan.Next.String()
+		jsonResult.Commits = plan.Commits
+		jsonResult.Data = plan
+		if *dryRun {
+			previous := plan.Previous
+			if previous == "" {
+				previous = "none"
 			}
-			fmt.Printf("Pushed tag %s to remote\n", *version)
+			fmt.Printf("Next version: %s (%s bump, previous: %s)\n", plan.Next, plan.Bump, previous)
+			for _, commit := range plan.Commits {
+				fmt.Printf("  %s %s [%s]\n", gitworkflow.ShortHash(commit.Hash), commit.Subject, gitworkflow.CommitBump(commit))
+			}
+		}
+		if err := wm.Release(plan, *releaseMessage, *releasePush); err != nil {
+			fail(err)
+		}
+		if *releasePush {
+			report(*dryRun, "Created tag %s and pushed it to remote", plan.Next)
+		} else {
+			report(*dryRun, "Created tag %s", plan.Next)
 		}
 
 	case "sync":
-		syncCmd.Parse(os.Args[2:])
-		var err error
+		wm := openRepository()
 		if *syncMain {
-			err = wm.SyncMainBranch()
-			fmt.Println("Synced main branch with remote")
+			if err := wm.SyncMainBranch(); err != nil {
+				fail(err)
+			}
+			report(*dryRun, "Synced base branch with remote")
 		} else {
-			err = wm.SyncWithRemote()
-			fmt.Println("Synced current branch with remote")
+			result, err := wm.Sync(gitworkflow.SyncOptions{Strategy: *syncStrategy, Push: *syncPush, Autostash: *syncAutostash, Strict: *syncStrict})
+			if result != nil {
+				jsonResult.Data = result
+			}
+			if err != nil {
+				fail(err)
+			}
+			report(*dryRun, "%s", result.Summary())
+		}
+
+	case "resolve":
+		var choices []gitworkflow.ConflictChoice
+		if *resolveOurs {
+			choices = append(choices, gitworkflow.ChoiceOurs)
+		}
+		if *resolveTheirs {
+			choices = append(choices, gitworkflow.ChoiceTheirs)
+		}
+		if *resolveMark {
+			choices = append(choices, gitworkflow.ChoiceEdited)
 		}
+		if len(choices) > 1 {
+			usage(nil, "Error: use only one of --ours, --theirs and --mark")
+		}
+
+		wm := openRepository()
+		operation, err := wm.InProgressOperation()
 		if err != nil {
-			log.Fatal(err)
+			fail(err)
 		}
 
-	case "resolve":
-		resolveCmd.Parse(os.Args[2:])
-		var err error
-		if *useRebase {
-			err = wm.ResolveConflictsRebase()
-			fmt.Println("Resolved conflicts by rebasing onto origin/main")
-		} else {
-			err = wm.ResolveConflictsMerge()
-			fmt.Println("Resolved conflicts by merging origin/main")
+		switch {
+		case *resolveAbort:
+			if err := wm.AbortResolve(); err != nil {
+				fail(err)
+			}
+			report(*dryRun, "Aborted the %s; the branch is back where it started", operation)
+			exit(gitworkflow.ExitOK)
+
+		case operation == gitworkflow.OperationNone && !*resolveContinue && len(choices) == 0 && !*resolveInteractive:
+			baseBranch, err := wm.BaseBranch()
+			if err != nil {
+				fail(err)
+			}
+			upstream := fmt.Sprintf("%s/%s", wm.Remote(), baseBranch)
+			if *useRebase {
+				err = wm.ResolveConflictsRebase()
+				if err == nil {
+					report(*dryRun, "Rebased onto %s without conflicts", upstream)
+				}
+			} else {
+				err = wm.ResolveConflictsMerge()
+				if err == nil {
+					report(*dryRun, "Merged %s without conflicts", upstream)
+				}
+			}
+			var conflictErr *gitworkflow.ConflictError
+			if errors.As(err, &conflictErr) {
+				fmt.Printf("Stopped: %v\n\n", err)
+			} else if err != nil {
+				fail(err)
+			}
+
+		default:
+			if len(choices) == 1 {
+				if len(resolveCmd.Args()) == 0 {
+					err = wm.ResolveAll(choices[0])
+				}
+				for _, file := range resolveCmd.Args() {
+					if err = wm.ResolveFile(file, choices[0]); err != nil {
+						break
+					}
+					report(*dryRun, "Resolved %s (%s)", file, choices[0])
+				}
+				if err != nil {
+					fail(err)
+				}
+			}
+			if *resolveInteractive {
+				if err := wm.ResolveInteractive(os.Stdin, os.Stdout); err != nil {
+					fail(err)
+				}
+			}
+			if *resolveContinue {
+				err := wm.ContinueResolve()
+				var conflictErr *gitworkflow.ConflictError
+				if errors.As(err, &conflictErr) {
+					fmt.Printf("Cannot continue: %v\n\n", err)
+				} else if err != nil {
+					fail(err)
+				}
+			}
+		}
+
+		// Always say where things stand when stopping part way
+		state, err := wm.ConflictState()
+		if err != nil {
+			fail(err)
+		}
+		if state.Operation != gitworkflow.OperationNone {
+			fmt.Print(state.Describe())
+			jsonResult.Data = state
+			if len(state.Files) > 0 {
+				stop(&gitworkflow.ConflictError{State: state})
+			}
+			stop(fmt.Errorf("the %s is not finished; run 'vamosGitWF resolve --continue'", state.Operation))
+		}
+		if *resolveContinue {
+			report(*dryRun, "Finished the %s", operation)
+		}
+
+	case "changelog":
+		if *changelogFormat != "markdown" && *changelogFormat != "json" {
+			usage(changelogCmd, "Error: --format must be markdown or json")
+		}
+		if *changelogPrepend != "" && *changelogFormat != "markdown" {
+			usage(nil, "Error: --prepend requires --format markdown")
+		}
+		wm := openRepository()
+		changelog, err := wm.GenerateChangelog(*changelogFrom, *changelogTo)
+		if err != nil {
+			fail(err)
+		}
+		jsonResult.Data = changelog
+		switch {
+		case *changelogFormat == "json":
+			data, err := json.MarshalIndent(changelog, "", "  ")
+			if err != nil {
+				fail(err)
+			}
+			fmt.Println(string(data))
+		case *changelogPrepend != "" && *dryRun:
+			fmt.Printf("would prepend to %s:\n\n", *changelogPrepend)
+			fmt.Print(changelog.Markdown(wm.Config().Changelog.StoryURL))
+		case *changelogPrepend != "":
+			if err := gitworkflow.PrependChangelog(*changelogPrepend, changelog.Markdown(wm.Config().Changelog.StoryURL)); err != nil {
+				fail(err)
+			}
+			report(false, "Prepended %s to %s", changelog.Version, *changelogPrepend)
+		default:
+			fmt.Print(changelog.Markdown(wm.Config().Changelog.StoryURL))
+		}
+
+	case "backups":
+		backupsArgs := backupsCmd.Args()
+		if len(backupsArgs) < 1 || (backupsArgs[0] != "list" && backupsArgs[0] != "restore") {
+			usage(nil, "Expected: 'backups list' or 'backups restore <id>'")
+		}
+		if backupsArgs[0] == "restore" && len(backupsArgs) < 2 {
+			usage(nil, "Expected: 'backups restore <id>'")
+		}
+		wm := openRepository()
+		if backupsArgs[0] == "list" {
+			backups, err := wm.Backups()
+			if err != nil {
+				fail(err)
+			}
+			jsonResult.Data = backups
+			if len(backups) == 0 {
+				fmt.Println("No backups")
+			}
+			for _, backup := range backups {
+				stash := ""
+				if backup.Stash != "" {
+					stash = " +uncommitted changes"
+				}
+				fmt.Printf("%s  %-20s %s  before %s%s\n", backup.ID, backup.Branch, gitworkflow.ShortHash(backup.Commit), backup.Operation, stash)
+			}
+			exit(gitworkflow.ExitOK)
+		}
+		taken, err := wm.RestoreBackup(backupsArgs[1])
+		if len(taken) > 0 && !*dryRun {
+			for _, backup := range taken {
+				fmt.Printf("Backed up %s before restoring as %s\n", backup.Branch, backup.ID)
+			}
+			jsonResult.Data = taken
 		}
 		if err != nil {
-			log.Fatal(err)
+			fail(err)
 		}
+		report(*dryRun, "Restored backup %s", backupsArgs[1])
+
+	case "config":
+		if len(args) < 2 || args[1] != "show" {
+			usage(nil, "Expected: 'config show'")
+		}
+		executor := gitworkflow.NewExecExecutor("")
+		workflowConfig, configPath := gitworkflow.DefaultConfig(), ""
+		if _, err := executor.Execute("git", "rev-parse", "--show-toplevel"); err == nil {
+			if workflowConfig, configPath, err = loadWorkflowConfig(cfg, executor); err != nil {
+				fail(err)
+			}
+		} else {
+			// Outside a repository there is no config file to load
+			applyEnvironment(cfg, &workflowConfig)
+		}
+		jsonResult.Data = map[string]string{"path": configPath, "config": workflowConfig.String()}
+		if configPath != "" {
+			fmt.Printf("# Effective workflow config (loaded from %s)\n", configPath)
+		} else {
+			fmt.Printf("# Effective workflow config (defaults, no %s found)\n", gitworkflow.ConfigFileName)
+		}
+		fmt.Print(workflowConfig.String())
 
 	default:
-		fmt.Println("Expected one of: 'story-start', 'story-commit', 'story-push', 'undo', 'revert', 'tag', 'sync', 'resolve', or 'example'")
-		os.Exit(1)
+		usage(nil, "Expected one of: 'story-start', 'story-commit', 'story-push', 'story-pr', 'pr-describe', 'story-list', 'story-switch', 'story-finish', 'story-squash', 'story-fixup', 'status', 'worktree-list', 'worktree-remove', 'prune', 'undo', 'revert', 'tag', 'release', 'sync', 'resolve', 'changelog', 'backups', 'config', or 'example'")
+	}
+	exit(gitworkflow.ExitOK)
+}
+
+// splitList splits a comma-separated flag value, ignoring empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// outputUsage describes the --output flag
+const outputUsage = "Output format: text, or json to write a single JSON result to stdout and everything else to stderr"
+
+// jsonResult collects what the command did. With --output json it is written
+// to stdout when the command exits.
+var jsonResult = &gitworkflow.Result{}
+
+// jsonOutput is where jsonResult is written, or nil without --output json
+var jsonOutput io.Writer
+
+// skippedCommands returns the commands a dry run reported, or is nil outside a dry run
+var skippedCommands func() []string
+
+// setOutput switches to the output format. With json, everything meant to be
+// read by people, including prompts, goes to stderr so that stdout holds only
+// the JSON result.
+func setOutput(format string) {
+	switch format {
+	case "text":
+	case "json":
+		if jsonOutput == nil {
+			jsonOutput = os.Stdout
+			os.Stdout = os.Stderr
+		}
+	default:
+		usage(nil, "Error: --output must be text or json")
+	}
+}
+
+// outputFlag returns the value of the last --output flag in args, or an empty
+// string when there is none
+func outputFlag(args []string) string {
+	var format string
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "output" {
+			continue
+		}
+		if !hasValue && i+1 < len(args) {
+			value = args[i+1]
+		}
+		format = value
+	}
+	return format
+}
+
+// flagError exits after the flag package failed to parse the command line and
+// printed why along with the flags. The flags after the failing one were not
+// parsed, so --output is looked for in the arguments themselves.
+func flagError(err error) {
+	if outputFlag(os.Args[1:]) == "json" {
+		setOutput("json")
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		exit(gitworkflow.ExitOK)
+	}
+	stopUsage(err.Error())
+}
+
+// report prints a success message, or notes that nothing changed in a dry run
+func report(dryRun bool, format string, args ...interface{}) {
+	if dryRun {
+		jsonResult.Message = "Dry run: no changes were made"
+	} else {
+		jsonResult.Message = fmt.Sprintf(format, args...)
+	}
+	fmt.Println(jsonResult.Message)
+}
+
+// warn prints something the user needs to act on and adds it to the result's warnings
+func warn(format string, args ...interface{}) {
+	warning := fmt.Sprintf(format, args...)
+	jsonResult.Warnings = append(jsonResult.Warnings, warning)
+	fmt.Println(warning)
+}
+
+// headCommit describes the commit just created with subject. It has no hash in a dry run.
+func headCommit(wm *gitworkflow.WorkflowManager, subject string) gitworkflow.Commit {
+	commit := gitworkflow.Commit{Subject: subject, Conventional: true}
+	if !wm.DryRun() {
+		commit.Hash, _ = wm.GetLastCommitHash()
+	}
+	return commit
+}
+
+// newWorkflowManager creates a WorkflowManager for the repository in dir, or
+// the current directory when dir is empty, with the config from loadWorkflowConfig
+func newWorkflowManager(cfg *config.Config, dir string) (*gitworkflow.WorkflowManager, error) {
+	executor := gitworkflow.NewExecExecutor(dir)
+	workflowConfig, _, err := loadWorkflowConfig(cfg, executor)
+	if err != nil {
+		return nil, err
+	}
+
+	wm := gitworkflow.NewWorkflowManagerWithConfig(workflowConfig, executor)
+	if workflowConfig.GitHub.Token != "" {
+		wm.SetPullRequestClient(gitworkflow.NewGitHubClient(workflowConfig.GitHub.APIURL, workflowConfig.GitHub.Token))
+	}
+	if cfg.LLMAPIKey != "" {
+		wm.SetTextGenerator(llm.NewClient(cfg.LLMAPIKey, cfg.LLMModelName))
+	}
+	return wm, nil
+}
+
+// loadWorkflowConfig loads the workflow config of the repository executor runs
+// in, with environment variables taking precedence over it. The path of the
+// config file is returned, or an empty string when the repository has none.
+func loadWorkflowConfig(cfg *config.Config, executor gitworkflow.CommandExecutorInterface) (gitworkflow.Config, string, error) {
+	workflowConfig, configPath, err := gitworkflow.LoadRepoConfig(executor)
+	if err != nil {
+		return gitworkflow.Config{}, "", err
+	}
+	applyEnvironment(cfg, &workflowConfig)
+	if err := workflowConfig.Validate(); err != nil {
+		return gitworkflow.Config{}, "", err
+	}
+	return workflowConfig, configPath, nil
+}
+
+// applyEnvironment overrides workflowConfig with the settings from environment variables
+func applyEnvironment(cfg *config.Config, workflowConfig *gitworkflow.Config) {
+	if cfg.GitRemote != "" {
+		workflowConfig.Remote = cfg.GitRemote
+	}
+	if cfg.GitBaseBranch != "" {
+		workflowConfig.BaseBranch = cfg.GitBaseBranch
+	}
+	if cfg.GitHubToken != "" {
+		workflowConfig.GitHub.Token = cfg.GitHubToken
+	}
+}
+
+// loadWorkspace creates a Workspace with a WorkflowManager for every repository in the workspace file
+func loadWorkspace(cfg *config.Config, path string) (*gitworkflow.Workspace, error) {
+	workspaceConfig, err := gitworkflow.LoadWorkspaceFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var repos []gitworkflow.WorkspaceRepo
+	for _, dir := range workspaceConfig.Repos {
+		wm, err := newWorkflowManager(cfg, dir)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", dir, err)
+		}
+		repos = append(repos, gitworkflow.WorkspaceRepo{Name: filepath.Base(dir), Manager: wm})
+	}
+	return gitworkflow.NewWorkspace(repos, workspaceConfig.Parallelism), nil
+}
+
+// printStatus prints where the current branch stands, one aspect per line
+func printStatus(status *gitworkflow.Status) {
+	branch := status.Branch
+	switch {
+	case status.Detached:
+		branch = "(detached HEAD)"
+	case status.StoryID != "":
+		branch += " (story " + status.StoryID + ")"
+	}
+	fmt.Printf("Branch:       %s\n", branch)
+	if status.Upstream != "" {
+		fmt.Printf("Upstream:     %s, %d ahead, %d behind\n", status.Upstream, status.Ahead, status.Behind)
+	} else if !status.Detached {
+		fmt.Println("Upstream:     none (not pushed yet)")
+	}
+	if status.Base != "" {
+		fmt.Printf("Base:         %s, %d ahead, %d behind\n", status.Base, status.BaseAhead, status.BaseBehind)
+	}
+	changes := fmt.Sprintf("%d staged, %d unstaged, %d untracked", status.Staged, status.Unstaged, status.Untracked)
+	if status.Conflicted > 0 {
+		changes += fmt.Sprintf(", %d conflicted", status.Conflicted)
+	}
+	fmt.Printf("Changes:      %s\n", changes)
+	if status.Operation != gitworkflow.OperationNone {
+		fmt.Printf("In progress:  %s (see 'vamosGitWF resolve')\n", status.Operation)
+	}
+	latestTag := status.LatestTag
+	if latestTag == "" {
+		latestTag = "none"
+	}
+	fmt.Printf("Latest tag:   %s\n", latestTag)
+	fmt.Printf("Stashes:      %d\n", status.Stashes)
+}
+
+// reportWorkspace prints the outcome in every repository of a workspace
+func reportWorkspace(dryRun bool, results []gitworkflow.RepoResult) {
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Printf("%-20s FAILED  %v\n", result.Repo, result.Err)
+			var gitErr *gitworkflow.GitError
+			if errors.As(result.Err, &gitErr) && gitErr.Hint() != "" {
+				fmt.Printf("%-20s         Hint: %s\n", "", gitErr.Hint())
+			}
+			var conflictErr *gitworkflow.ConflictError
+			if errors.As(result.Err, &conflictErr) {
+				fmt.Printf("%-20s         Hint: %s\n", "", conflictErr.Hint())
+			}
+			var checksErr *gitworkflow.ChecksError
+			if errors.As(result.Err, &checksErr) {
+				fmt.Print(checksErr.Report())
+			}
+			continue
+		}
+		fmt.Printf("%-20s ok      %s\n", result.Repo, result.Summary)
+	}
+	if dryRun {
+		fmt.Println("Dry run: no changes were made")
+	}
+}
+
+// fail prints err and exits with the exit code for its kind. Git failures
+// also show git's own output and a hint, conflicts the conflicted files and
+// how to go on, failed pre-push checks their report.
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+
+	var conflictErr *gitworkflow.ConflictError
+	if errors.As(err, &conflictErr) {
+		fmt.Fprintf(os.Stderr, "\n%s\nHint: %s\n", conflictErr.State.Describe(), conflictErr.Hint())
+		jsonResult.Data = conflictErr.State
+	}
+
+	var checksErr *gitworkflow.ChecksError
+	if errors.As(err, &checksErr) {
+		fmt.Fprintf(os.Stderr, "\n%s\nHint: Fix the failures and push again, or push anyway with --skip-checks.\n", checksErr.Report())
+	}
+
+	var gitErr *gitworkflow.GitError
+	if errors.As(err, &gitErr) {
+		if stderr := strings.TrimSpace(gitErr.Stderr); stderr != "" {
+			fmt.Fprintf(os.Stderr, "\n%s output:\n", gitErr.Command)
+			for _, line := range strings.Split(stderr, "\n") {
+				fmt.Fprintf(os.Stderr, "  %s\n", line)
+			}
+		}
+		if hint := gitErr.Hint(); hint != "" {
+			fmt.Fprintf(os.Stderr, "\nHint: %s\n", hint)
+		}
+	}
+	stop(err)
+}
+
+// stop exits with the exit code for the kind of err without printing it
+func stop(err error) {
+	jsonResult.Error = gitworkflow.NewResultError(err)
+	exit(jsonResult.Error.ExitCode)
+}
+
+// usage reports a mistake on the command line, with the flags of flagSet if
+// given, and exits with ExitUsage
+func usage(flagSet *flag.FlagSet, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	fmt.Println(message)
+	if flagSet != nil {
+		flagSet.PrintDefaults()
+	}
+	stopUsage(strings.TrimPrefix(message, "Error: "))
+}
+
+// stopUsage exits with ExitUsage for a command line mistake without printing it
+func stopUsage(message string) {
+	jsonResult.Error = &gitworkflow.ResultError{
+		Code:     gitworkflow.CodeUsage,
+		ExitCode: gitworkflow.ExitUsage,
+		Message:  message,
+	}
+	exit(gitworkflow.ExitUsage)
+}
+
+// exit writes the result with --output json and exits with code
+func exit(code int) {
+	if jsonOutput != nil {
+		jsonResult.OK = code == gitworkflow.ExitOK
+		if skippedCommands != nil {
+			jsonResult.WouldRun = skippedCommands()
+		}
+		data, err := json.MarshalIndent(jsonResult, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to write the result: %v\n", err)
+			os.Exit(gitworkflow.ExitFailure)
+		}
+		fmt.Fprintln(jsonOutput, string(data))
 	}
+	os.Exit(code)
 }
@@ -0,0 +1,197 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is the root of the public GitHub REST API
+const DefaultBaseURL = "https://api.github.com"
+
+// apiVersion is the REST API version requests are made against
+const apiVersion = "2022-11-28"
+
+// HTTPClientInterface defines the interface for sending HTTP requests
+type HTTPClientInterface interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Client represents a GitHub REST API client
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient HTTPClientInterface
+}
+
+// NewPullRequest holds the fields of a pull request to open
+type NewPullRequest struct {
+	Title string `json:"title"`
+	Head  string `json:"head"`
+	Base  string `json:"base"`
+	Body  string `json:"body,omitempty"`
+	Draft bool   `json:"draft,omitempty"`
+}
+
+// PullRequest is a pull request as returned by the API
+type PullRequest struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	HTMLURL string `json:"html_url"`
+	Draft   bool   `json:"draft"`
+	State   string `json:"state"`
+}
+
+// APIError is an error response from the API
+type APIError struct {
+	StatusCode int
+	Message    string `json:"message"`
+	Errors     []struct {
+		Message string `json:"message"`
+		Code    string `json:"code"`
+		Field   string `json:"field"`
+	} `json:"errors"`
+}
+
+func (e *APIError) Error() string {
+	text := fmt.Sprintf("GitHub API returned %d", e.StatusCode)
+	if e.Message != "" {
+		text += ": " + e.Message
+	}
+	for _, detail := range e.Errors {
+		if detail.Message != "" {
+			text += ": " + detail.Message
+		} else if detail.Code != "" {
+			text += fmt.Sprintf(": %s %s", detail.Field, detail.Code)
+		}
+	}
+	return text
+}
+
+// NewClient creates a new GitHub client. An empty baseURL uses DefaultBaseURL;
+// GitHub Enterprise serves the API under https://<host>/api/v3.
+func NewClient(baseURL, token string) *Client {
+	return NewClientWithHTTPClient(baseURL, token, &http.Client{Timeout: 30 * time.Second})
+}
+
+// NewClientWithHTTPClient creates a new GitHub client that sends requests through httpClient
+func NewClientWithHTTPClient(baseURL, token string, httpClient HTTPClientInterface) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		token:      token,
+		httpClient: httpClient,
+	}
+}
+
+// CreatePullRequest opens a pull request in owner/repo
+func (c *Client) CreatePullRequest(ctx context.Context, owner, repo string, pr NewPullRequest) (*PullRequest, error) {
+	var created PullRequest
+	if err := c.do(ctx, http.MethodPost, repoPath(owner, repo, "pulls"), pr, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// FindPullRequest returns the open pull request from head, given as "owner:branch",
+// or nil if there is none
+func (c *Client) FindPullRequest(ctx context.Context, owner, repo, head string) (*PullRequest, error) {
+	query := url.Values{"head": {head}, "state": {"open"}}
+	var found []PullRequest
+	if err := c.do(ctx, http.MethodGet, repoPath(owner, repo, "pulls")+"?"+query.Encode(), nil, &found); err != nil {
+		return nil, err
+	}
+	if len(found) == 0 {
+		return nil, nil
+	}
+	return &found[0], nil
+}
+
+// RequestReviewers asks users, or teams given as "org/team", to review a pull request
+func (c *Client) RequestReviewers(ctx context.Context, owner, repo string, number int, reviewers []string) error {
+	request := struct {
+		Reviewers     []string `json:"reviewers,omitempty"`
+		TeamReviewers []string `json:"team_reviewers,omitempty"`
+	}{}
+	for _, reviewer := range reviewers {
+		if _, team, isTeam := strings.Cut(reviewer, "/"); isTeam {
+			request.TeamReviewers = append(request.TeamReviewers, team)
+		} else {
+			request.Reviewers = append(request.Reviewers, reviewer)
+		}
+	}
+	return c.do(ctx, http.MethodPost, repoPath(owner, repo, fmt.Sprintf("pulls/%d/requested_reviewers", number)), request, nil)
+}
+
+// AddLabels adds labels to an issue or pull request
+func (c *Client) AddLabels(ctx context.Context, owner, repo string, number int, labels []string) error {
+	request := struct {
+		Labels []string `json:"labels"`
+	}{Labels: labels}
+	return c.do(ctx, http.MethodPost, repoPath(owner, repo, fmt.Sprintf("issues/%d/labels", number)), request, nil)
+}
+
+// do sends a request with body encoded as JSON and decodes the response into result
+func (c *Client) do(ctx context.Context, method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", apiVersion)
+	req.Header.Set("User-Agent", "vamosGitWF")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response to %s %s: %w", method, path, err)
+	}
+
+	if resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		if err := json.Unmarshal(data, apiErr); err != nil {
+			apiErr.Message = strings.TrimSpace(string(data))
+		}
+		return apiErr
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(data, result); err != nil {
+			return fmt.Errorf("failed to decode response to %s %s: %w", method, path, err)
+		}
+	}
+	return nil
+}
+
+// repoPath returns the API path of a resource in owner/repo
+func repoPath(owner, repo, resource string) string {
+	return fmt.Sprintf("/repos/%s/%s/%s", url.PathEscape(owner), url.PathEscape(repo), resource)
+}
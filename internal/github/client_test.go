@@ -0,0 +1,117 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewClient(t *testing.T) {
+	client := NewClient("", "token")
+	assert.Equal(t, DefaultBaseURL, client.baseURL)
+	assert.Equal(t, "token", client.token)
+	assert.NotNil(t, client.httpClient)
+
+	client = NewClient("https://github.example.com/api/v3/", "token")
+	assert.Equal(t, "https://github.example.com/api/v3", client.baseURL)
+}
+
+func TestCreatePullRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/repos/acme/widgets/pulls", r.URL.Path)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		assert.Equal(t, "application/vnd.github+json", r.Header.Get("Accept"))
+
+		body, _ := io.ReadAll(r.Body)
+		assert.JSONEq(t, `{"title":"W-1: Login","head":"W-1-login","base":"main","body":"Changes","draft":true}`, string(body))
+
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"number":42,"title":"W-1: Login","html_url":"https://github.com/acme/widgets/pull/42","draft":true,"state":"open"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "secret")
+	pr, err := client.CreatePullRequest(context.Background(), "acme", "widgets", NewPullRequest{
+		Title: "W-1: Login",
+		Head:  "W-1-login",
+		Base:  "main",
+		Body:  "Changes",
+		Draft: true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 42, pr.Number)
+	assert.Equal(t, "https://github.com/acme/widgets/pull/42", pr.HTMLURL)
+	assert.True(t, pr.Draft)
+}
+
+func TestCreatePullRequestAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(`{"message":"Validation Failed","errors":[{"resource":"PullRequest","code":"custom","message":"No commits between main and W-1-login"}]}`))
+	}))
+	defer server.Close()
+
+	_, err := NewClient(server.URL, "secret").CreatePullRequest(context.Background(), "acme", "widgets", NewPullRequest{})
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusUnprocessableEntity, apiErr.StatusCode)
+	assert.Equal(t, "GitHub API returned 422: Validation Failed: No commits between main and W-1-login", err.Error())
+}
+
+func TestFindPullRequest(t *testing.T) {
+	tests := []struct {
+		name       string
+		response   string
+		wantNumber int
+	}{
+		{name: "Found", response: `[{"number":7,"html_url":"https://github.com/acme/widgets/pull/7"}]`, wantNumber: 7},
+		{name: "Not found", response: `[]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodGet, r.Method)
+				assert.Equal(t, "acme:W-1-login", r.URL.Query().Get("head"))
+				assert.Equal(t, "open", r.URL.Query().Get("state"))
+				w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+
+			pr, err := NewClient(server.URL, "secret").FindPullRequest(context.Background(), "acme", "widgets", "acme:W-1-login")
+			require.NoError(t, err)
+			if tt.wantNumber == 0 {
+				assert.Nil(t, pr)
+			} else {
+				require.NotNil(t, pr)
+				assert.Equal(t, tt.wantNumber, pr.Number)
+			}
+		})
+	}
+}
+
+func TestRequestReviewersAndAddLabels(t *testing.T) {
+	requests := map[string]map[string][]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string][]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		requests[r.URL.Path] = body
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "secret")
+	require.NoError(t, client.RequestReviewers(context.Background(), "acme", "widgets", 42, []string{"alice", "acme/platform"}))
+	require.NoError(t, client.AddLabels(context.Background(), "acme", "widgets", 42, []string{"story"}))
+
+	assert.Equal(t, map[string][]string{"reviewers": {"alice"}, "team_reviewers": {"platform"}}, requests["/repos/acme/widgets/pulls/42/requested_reviewers"])
+	assert.Equal(t, map[string][]string{"labels": {"story"}}, requests["/repos/acme/widgets/issues/42/labels"])
+}
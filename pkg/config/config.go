@@ -18,18 +18,26 @@ type Config struct {
 	// Web Configuration
 	WebPort    int
 	WebBaseURL string
+
+	// Git Workflow Configuration
+	GitRemote     string
+	GitBaseBranch string
+	GitHubToken   string
 }
 
 // NewConfig creates a new configuration instance
 func NewConfig() *Config {
 	return &Config{
-		AWSRegion:    getEnvOrDefault("AWS_REGION", "us-west-2"),
-		AWSAccessKey: getEnvOrDefault("AWS_ACCESS_KEY", ""),
-		AWSSecretKey: getEnvOrDefault("AWS_SECRET_KEY", ""),
-		LLMAPIKey:    getEnvOrDefault("LLM_API_KEY", ""),
-		LLMModelName: getEnvOrDefault("LLM_MODEL_NAME", "gpt-3.5-turbo"),
-		WebPort:      8080,
-		WebBaseURL:   getEnvOrDefault("WEB_BASE_URL", "http://localhost:8080"),
+		AWSRegion:     getEnvOrDefault("AWS_REGION", "us-west-2"),
+		AWSAccessKey:  getEnvOrDefault("AWS_ACCESS_KEY", ""),
+		AWSSecretKey:  getEnvOrDefault("AWS_SECRET_KEY", ""),
+		LLMAPIKey:     getEnvOrDefault("LLM_API_KEY", ""),
+		LLMModelName:  getEnvOrDefault("LLM_MODEL_NAME", "gpt-3.5-turbo"),
+		WebPort:       8080,
+		WebBaseURL:    getEnvOrDefault("WEB_BASE_URL", "http://localhost:8080"),
+		GitRemote:     getEnvOrDefault("GIT_REMOTE", ""),
+		GitBaseBranch: getEnvOrDefault("GIT_BASE_BRANCH", ""),
+		GitHubToken:   getEnvOrDefault("GITHUB_TOKEN", os.Getenv("GH_TOKEN")),
 	}
 }
 
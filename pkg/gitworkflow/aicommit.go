@@ -0,0 +1,294 @@
+package gitworkflow
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// commitPromptTemplate asks for a Conventional Commits message. It is filled
+// with the rules, the optional context lines, the diff stat and the diff.
+const commitPromptTemplate = `Write a git commit message for the staged changes below.
+
+Rules:
+- The first line is "<type>(<scope>): <description>" or "<type>: <description>", at most 100 characters
+- <type> is one of: %s
+- %s
+- <description> is in the imperative mood, starts lower case and has no trailing period
+- Optionally add a blank line and a short body explaining what changed and why, wrapped at 72 characters
+- Reply with the commit message only, without code fences or commentary
+%s
+Files changed:
+%s
+Diff:
+%s`
+
+// ErrCommitRejected is returned when the suggested commit message is rejected
+var ErrCommitRejected = errors.New("commit message rejected")
+
+// SuggestCommitMessage asks the text generator for a Conventional Commits
+// message describing the staged changes. hint is passed on as extra context and
+// may be empty. A reply that breaks the configured rules is retried once.
+func (wm *WorkflowManager) SuggestCommitMessage(hint string) (CommitMessage, error) {
+	return wm.suggestCommitMessage(hint, nil)
+}
+
+// suggestCommitMessage suggests a message for the staged changes and those in
+// pending, pathspecs of changes that would be staged but are not yet
+func (wm *WorkflowManager) suggestCommitMessage(hint string, pending []string) (CommitMessage, error) {
+	stat, diff, err := wm.commitDiff(pending)
+	if err != nil {
+		return CommitMessage{}, fmt.Errorf("failed to read staged changes: %w", err)
+	}
+	if stat == "" {
+		return CommitMessage{}, fmt.Errorf("no staged changes to describe")
+	}
+
+	prompt := fmt.Sprintf(commitPromptTemplate,
+		strings.Join(wm.config.Commit.Types, ", "),
+		wm.scopeRule(),
+		wm.commitContext(hint),
+		stat,
+		fitDiff(diff, DefaultDiffTokenBudget*charsPerToken))
+
+	reply, err := wm.generateText(prompt)
+	if err != nil {
+		return CommitMessage{}, err
+	}
+	message, err := wm.parseSuggestedMessage(reply)
+	if err != nil {
+		wm.logf("rejected suggestion %q: %v", firstLine(reply), err)
+		retry := fmt.Sprintf("%s\n\nYour previous reply was rejected because %v. It was:\n%s\n\nReply again following the rules.", prompt, err, reply)
+		if reply, err = wm.generateText(retry); err != nil {
+			return CommitMessage{}, err
+		}
+		if message, err = wm.parseSuggestedMessage(reply); err != nil {
+			return CommitMessage{}, fmt.Errorf("the language model did not suggest a valid commit message: %w", err)
+		}
+	}
+	return message, nil
+}
+
+// CommitStoryWithSuggestion stages the changes selected by staging, asks the
+// text generator for a message and lets the user accept, edit or reject it
+// through in and out before committing. When the message is rejected, which
+// returns ErrCommitRejected, or anything fails, the index is put back the way
+// it was. A dry run suggests a message for the changes that would be staged.
+func (wm *WorkflowManager) CommitStoryWithSuggestion(staging StageOptions, hint string, in io.Reader, out io.Writer) (message CommitMessage, err error) {
+	if !wm.dryRun {
+		var index string
+		if index, err = wm.gitOutput("write-tree"); err != nil {
+			return CommitMessage{}, fmt.Errorf("failed to save the index: %w", err)
+		}
+		defer func() {
+			if err == nil {
+				return
+			}
+			if _, restoreErr := wm.git("read-tree", index); restoreErr != nil {
+				err = fmt.Errorf("%w; restoring the index also failed: %v", err, restoreErr)
+			}
+		}()
+	}
+
+	pathspecs, err := wm.stageForCommit(staging)
+	if err != nil {
+		return CommitMessage{}, err
+	}
+	var pending []string
+	if wm.dryRun {
+		// Nothing was staged, so describe what would have been
+		pending = pathspecs
+	}
+
+	suggestion, err := wm.suggestCommitMessage(hint, pending)
+	if err != nil {
+		return CommitMessage{}, err
+	}
+	message, err = wm.ReviewCommitMessage(suggestion, in, out)
+	if err != nil {
+		return message, err
+	}
+	return wm.Commit(message)
+}
+
+// commitDiff returns the stat and diff of the staged changes followed by those
+// of the changes in pending that are not staged yet. Untracked files in
+// pending are diffed as new files.
+func (wm *WorkflowManager) commitDiff(pending []string) (string, string, error) {
+	stat, err := wm.gitOutput("diff", "--cached", "--stat")
+	if err != nil {
+		return "", "", err
+	}
+	diff, err := wm.git("diff", "--cached", "--no-color", "--no-ext-diff")
+	if err != nil {
+		return "", "", err
+	}
+	if len(pending) == 0 {
+		return stat, diff.Stdout, nil
+	}
+
+	stats, diffs := []string{stat}, []string{diff.Stdout}
+	unstagedStat, err := wm.gitOutput(append([]string{"diff", "--stat", "--"}, pending...)...)
+	if err != nil {
+		return "", "", err
+	}
+	unstaged, err := wm.git(append([]string{"diff", "--no-color", "--no-ext-diff", "--"}, pending...)...)
+	if err != nil {
+		return "", "", err
+	}
+	stats, diffs = append(stats, unstagedStat), append(diffs, unstaged.Stdout)
+
+	untracked, err := wm.gitOutput(append([]string{"ls-files", "--others", "--exclude-standard", "-z", "--"}, pending...)...)
+	if err != nil {
+		return "", "", err
+	}
+	for _, file := range strings.Split(untracked, "\x00") {
+		if file == "" {
+			continue
+		}
+		// diff --no-index exits with 1 when the files differ
+		added, err := wm.git("diff", "--no-color", "--no-ext-diff", "--no-index", "--", "/dev/null", file)
+		if err != nil && exitCode(err) != 1 {
+			return "", "", err
+		}
+		stats, diffs = append(stats, fmt.Sprintf(" %s (new file)", file)), append(diffs, added.Stdout)
+	}
+
+	var nonEmpty []string
+	for _, stat := range stats {
+		if stat != "" {
+			nonEmpty = append(nonEmpty, stat)
+		}
+	}
+	return strings.Join(nonEmpty, "\n"), strings.Join(diffs, ""), nil
+}
+
+// ReviewCommitMessage shows message and asks whether to accept, edit or reject it
+func (wm *WorkflowManager) ReviewCommitMessage(message CommitMessage, in io.Reader, out io.Writer) (CommitMessage, error) {
+	if in == nil || out == nil {
+		return message, fmt.Errorf("reviewing a commit message needs an input and output")
+	}
+
+	reader := bufio.NewReader(in)
+	for {
+		fmt.Fprintf(out, "\n%s\n\n", indent(message.String(), "    "))
+		fmt.Fprint(out, "Commit with this message [y,e,n,?]? ")
+		answer, err := reader.ReadString('\n')
+		if err != nil && answer == "" {
+			return message, fmt.Errorf("commit message review aborted: %w", err)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			return message, nil
+		case "n", "no":
+			return message, ErrCommitRejected
+		case "e", "edit":
+			edited, err := wm.editCommitMessage(message, reader, out)
+			if err != nil {
+				return message, err
+			}
+			message = edited
+		default:
+			fmt.Fprintln(out, "y - commit with this message\ne - edit the header and body\nn - reject the message and put the index back as it was")
+		}
+	}
+}
+
+// editCommitMessage reads a replacement header and body from reader. Empty
+// answers keep the current header and body.
+func (wm *WorkflowManager) editCommitMessage(message CommitMessage, reader *bufio.Reader, out io.Writer) (CommitMessage, error) {
+	for {
+		fmt.Fprintf(out, "Header (empty keeps %q): ", message.Header())
+		header, err := reader.ReadString('\n')
+		if err != nil && header == "" {
+			return message, fmt.Errorf("commit message review aborted: %w", err)
+		}
+		header = strings.TrimSpace(header)
+		if header == "" {
+			break
+		}
+		if err := ValidateCommitHeader(header, wm.config.Commit); err != nil {
+			fmt.Fprintf(out, "%v\n", err)
+			continue
+		}
+
+		parsed, _ := ParseCommitHeader(header)
+		message.Type, message.Scope, message.Breaking, message.Description = parsed.Type, parsed.Scope, parsed.Breaking, parsed.Description
+		break
+	}
+
+	fmt.Fprintln(out, "Body, ending with an empty line (start with an empty line to keep the current body):")
+	var body []string
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		body = append(body, line)
+		if err != nil {
+			break
+		}
+	}
+	if len(body) > 0 {
+		message.Body = strings.Join(body, "\n")
+	}
+	return message, nil
+}
+
+// parseSuggestedMessage parses a suggested commit message and checks it against the configured rules
+func (wm *WorkflowManager) parseSuggestedMessage(reply string) (CommitMessage, error) {
+	message, err := ParseCommitMessage(reply)
+	if err != nil {
+		return message, err
+	}
+	if err := ValidateCommitHeader(message.Header(), wm.config.Commit); err != nil {
+		return message, err
+	}
+	return message, nil
+}
+
+// scopeRule describes which scopes the configuration allows
+func (wm *WorkflowManager) scopeRule() string {
+	if len(wm.config.Commit.Scopes) == 0 {
+		return "<scope> is optional: a short lower-case name for the area of the code that changed"
+	}
+	return "<scope> is optional; when given it is one of: " + strings.Join(wm.config.Commit.Scopes, ", ")
+}
+
+// commitContext returns the story and hint lines of the commit prompt
+func (wm *WorkflowManager) commitContext(hint string) string {
+	var lines []string
+	if branch, err := wm.GetCurrentBranch(); err == nil {
+		if storyID, ok := wm.StoryID(branch); ok {
+			lines = append(lines, fmt.Sprintf("The changes are for story %s on branch %s; do not repeat the story ID in the message.", storyID, branch))
+		}
+	}
+	if hint = strings.TrimSpace(hint); hint != "" {
+		lines = append(lines, "The author describes the change as: "+hint)
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return "\n" + strings.Join(lines, "\n") + "\n"
+}
+
+// firstLine returns the first line of text
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(text, "\n")
+	return line
+}
+
+// indent prefixes every non-empty line of text with prefix
+func indent(text, prefix string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
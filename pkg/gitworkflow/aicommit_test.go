@@ -0,0 +1,214 @@
+package gitworkflow
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	stagedStatCommand = "git diff --cached --stat"
+	stagedDiffCommand = "git diff --cached --no-color --no-ext-diff"
+)
+
+// stagedRepo returns an executor on story branch W-123-login with main.go staged
+func stagedRepo() *RecordingExecutor {
+	return NewRecordingExecutor().
+		OnOutput("git rev-parse --abbrev-ref HEAD", "W-123-login\n").
+		OnOutput(stagedStatCommand, " main.go | 3 +++\n 1 file changed, 3 insertions(+)\n").
+		OnOutput(stagedDiffCommand, fileDiff("main.go", 3))
+}
+
+func TestSuggestCommitMessage(t *testing.T) {
+	generator := &fakeGenerator{replies: []string{"feat(auth): add login form\n\nShow a form that posts to /login."}}
+	config := DefaultConfig()
+	config.Commit.Scopes = []string{"auth", "chat"}
+	wm := NewWorkflowManagerWithConfig(config, stagedRepo())
+	wm.SetTextGenerator(generator)
+
+	message, err := wm.SuggestCommitMessage("users can log in")
+	if err != nil {
+		t.Fatalf("SuggestCommitMessage() unexpected error: %v", err)
+	}
+	if message.Header() != "feat(auth): add login form" || message.Body != "Show a form that posts to /login." {
+		t.Errorf("Unexpected message: %+v", message)
+	}
+
+	prompt := generator.prompts[0]
+	for _, want := range []string{"one of: auth, chat", "story W-123", "users can log in", "main.go | 3 +++", "+line 2 of main.go"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("Expected the prompt to contain %q:\n%s", want, prompt)
+		}
+	}
+}
+
+func TestSuggestCommitMessageRetriesInvalidReply(t *testing.T) {
+	generator := &fakeGenerator{replies: []string{"feat(billing): add invoices", "feat(auth): add invoices"}}
+	config := DefaultConfig()
+	config.Commit.Scopes = []string{"auth"}
+	wm := NewWorkflowManagerWithConfig(config, stagedRepo())
+	wm.SetTextGenerator(generator)
+
+	message, err := wm.SuggestCommitMessage("")
+	if err != nil {
+		t.Fatalf("SuggestCommitMessage() unexpected error: %v", err)
+	}
+	if message.Scope != "auth" || len(generator.prompts) != 2 {
+		t.Errorf("Expected one retry to fix the scope, got %+v after %d prompt(s)", message, len(generator.prompts))
+	}
+	if !strings.Contains(generator.prompts[1], `scope "billing" is not allowed`) {
+		t.Errorf("Expected the retry to explain the problem:\n%s", generator.prompts[1])
+	}
+}
+
+func TestSuggestCommitMessageGivesUp(t *testing.T) {
+	wm := NewWorkflowManagerWithExecutor(stagedRepo())
+	wm.SetTextGenerator(&fakeGenerator{replies: []string{"Added a login form."}})
+
+	if _, err := wm.SuggestCommitMessage(""); err == nil || !strings.Contains(err.Error(), "did not suggest a valid commit message") {
+		t.Fatalf("SuggestCommitMessage() error = %v, want invalid suggestion", err)
+	}
+}
+
+func TestSuggestCommitMessageNothingStaged(t *testing.T) {
+	wm := NewWorkflowManagerWithExecutor(NewRecordingExecutor())
+	wm.SetTextGenerator(&fakeGenerator{})
+
+	if _, err := wm.SuggestCommitMessage(""); err == nil || !strings.Contains(err.Error(), "no staged changes") {
+		t.Fatalf("SuggestCommitMessage() error = %v, want nothing staged", err)
+	}
+}
+
+func TestReviewCommitMessage(t *testing.T) {
+	suggestion := CommitMessage{Type: "feat", Scope: "auth", Description: "add login form", Body: "Original body."}
+
+	tests := []struct {
+		name       string
+		input      string
+		wantHeader string
+		wantBody   string
+		wantErr    error
+	}{
+		{name: "accept", input: "y\n", wantHeader: "feat(auth): add login form", wantBody: "Original body."},
+		{name: "reject", input: "n\n", wantErr: ErrCommitRejected},
+		{
+			name:       "edit header and body",
+			input:      "e\nfix(auth): handle empty password\nReject empty passwords.\nExplain why.\n\ny\n",
+			wantHeader: "fix(auth): handle empty password",
+			wantBody:   "Reject empty passwords.\nExplain why.",
+		},
+		{
+			name:       "edit keeps what is left empty",
+			input:      "e\n\n\ny\n",
+			wantHeader: "feat(auth): add login form",
+			wantBody:   "Original body.",
+		},
+		{
+			name:       "invalid header is asked again",
+			input:      "e\nadded stuff\nchore: tidy up\n\ny\n",
+			wantHeader: "chore: tidy up",
+			wantBody:   "Original body.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			wm := NewWorkflowManagerWithExecutor(NewRecordingExecutor())
+			message, err := wm.ReviewCommitMessage(suggestion, strings.NewReader(tt.input), &out)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ReviewCommitMessage() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReviewCommitMessage() unexpected error: %v", err)
+			}
+			if message.Header() != tt.wantHeader || message.Body != tt.wantBody {
+				t.Errorf("ReviewCommitMessage() = %q / %q, want %q / %q", message.Header(), message.Body, tt.wantHeader, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestCommitStoryWithSuggestion(t *testing.T) {
+	executor := stagedRepo().
+		OnOutput(statusCommand, " M main.go\x00").
+		OnFailure("git diff --cached --quiet", 1, "")
+	wm := NewWorkflowManagerWithExecutor(executor)
+	wm.SetTextGenerator(&fakeGenerator{replies: []string{"feat: add login form"}})
+	var out bytes.Buffer
+
+	message, err := wm.CommitStoryWithSuggestion(StageOptions{}, "", strings.NewReader("y\n"), &out)
+	if err != nil {
+		t.Fatalf("CommitStoryWithSuggestion() unexpected error: %v", err)
+	}
+	want := "git commit -m feat: add login form\n\nRefs: W-123"
+	if last := executor.Commands[len(executor.Commands)-1]; last != want {
+		t.Errorf("Expected %q, got %q", want, last)
+	}
+	if !strings.Contains(out.String(), "    feat: add login form") {
+		t.Errorf("Expected the suggestion to be shown, got:\n%s", out.String())
+	}
+	if len(message.Refs) != 1 {
+		t.Errorf("Expected the story to be referenced, got %+v", message)
+	}
+}
+
+func TestCommitStoryWithSuggestionRejectedRestoresIndex(t *testing.T) {
+	repo := newGitRepo(t)
+	for file, contents := range map[string]string{"staged.txt": "staged\n", "README.md": "changed\n"} {
+		if err := os.WriteFile(filepath.Join(repo.dir, file), []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo.git("add", "staged.txt")
+	wm := repo.manager()
+	wm.SetTextGenerator(&fakeGenerator{replies: []string{"docs: update readme"}})
+
+	_, err := wm.CommitStoryWithSuggestion(StageOptions{}, "", strings.NewReader("n\n"), &bytes.Buffer{})
+	if !errors.Is(err, ErrCommitRejected) {
+		t.Fatalf("CommitStoryWithSuggestion() error = %v, want %v", err, ErrCommitRejected)
+	}
+	if staged := repo.git("diff", "--cached", "--name-only"); staged != "staged.txt" {
+		t.Errorf("Expected only staged.txt to stay staged, got %q", staged)
+	}
+	if unstaged := repo.git("diff", "--name-only"); unstaged != "README.md" {
+		t.Errorf("Expected README.md to be unstaged again, got %q", unstaged)
+	}
+}
+
+func TestCommitStoryWithSuggestionDryRun(t *testing.T) {
+	repo := newGitRepo(t)
+	if err := os.WriteFile(filepath.Join(repo.dir, "README.md"), []byte("changed readme\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repo.dir, "login.go"), []byte("package login\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	repo.git("checkout", "--quiet", "-b", "W-123-login")
+	generator := &fakeGenerator{replies: []string{"feat: add login"}}
+	wm := repo.manager()
+	wm.SetTextGenerator(generator)
+	var dryRun bytes.Buffer
+	wm.EnableDryRun(&dryRun)
+
+	if _, err := wm.CommitStoryWithSuggestion(StageOptions{}, "", strings.NewReader("y\n"), &bytes.Buffer{}); err != nil {
+		t.Fatalf("CommitStoryWithSuggestion() unexpected error: %v", err)
+	}
+	for _, want := range []string{"README.md | 2 +-", "login.go (new file)", "+changed readme", "+package login"} {
+		if !strings.Contains(generator.prompts[0], want) {
+			t.Errorf("Expected the prompt to contain %q:\n%s", want, generator.prompts[0])
+		}
+	}
+	if !strings.Contains(dryRun.String(), "would run: git commit") {
+		t.Errorf("Expected the commit to be reported, got:\n%s", dryRun.String())
+	}
+	if staged := repo.git("diff", "--cached", "--name-only"); staged != "" {
+		t.Errorf("Expected nothing to be staged, got %q", staged)
+	}
+}
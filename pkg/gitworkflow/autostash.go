@@ -0,0 +1,72 @@
+package gitworkflow
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// autostashPrefix starts the message of every stash made by autostash
+const autostashPrefix = "vamos autostash before "
+
+// autostash stashes every uncommitted change, including untracked files, runs
+// operation and then reapplies the stash. Nothing is stashed when the working
+// tree is clean. If operation stops part way, e.g. on rebase conflicts, the
+// stash is kept and the error says how to get it back.
+func (wm *WorkflowManager) autostash(operation string, run func() error) error {
+	changes, err := wm.ChangedFiles()
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		return run()
+	}
+
+	if _, err := wm.git("stash", "push", "--include-untracked", "-m", autostashPrefix+operation); err != nil {
+		return fmt.Errorf("failed to stash changes: %w", err)
+	}
+	// Name the stash by its commit so the instructions stay valid if more stashes are made
+	stash := "stash@{0}"
+	if commit, err := wm.gitOutput("rev-parse", "--verify", "--quiet", "refs/stash"); err == nil && commit != "" {
+		stash = ShortHash(commit)
+	}
+	wm.logf("stashed %d changed file(s) as %s", len(changes), stash)
+
+	if err := run(); err != nil {
+		if inProgress, stateErr := wm.InProgressOperation(); stateErr == nil && inProgress != OperationNone {
+			return fmt.Errorf("%w; your uncommitted changes are kept in the stash as %s, run 'git stash pop' once the %s is finished", err, stash, inProgress)
+		}
+		// Nothing is half done, so put the changes back where they were
+		if popErr := wm.popAutostash(operation, stash); popErr != nil {
+			return fmt.Errorf("%w; %v", err, popErr)
+		}
+		return err
+	}
+	return wm.popAutostash(operation, stash)
+}
+
+// popAutostash reapplies the changes stashed by autostash. When they do not
+// apply cleanly git keeps the stash and an ErrStashConflict is returned.
+func (wm *WorkflowManager) popAutostash(operation, stash string) error {
+	if _, err := wm.git("stash", "pop"); err != nil {
+		var gitErr *GitError
+		if errors.As(err, &gitErr) {
+			gitErr.Kind = ErrStashConflict
+		}
+		// git reports the conflicts on stdout, so name the files from the status instead
+		var conflicted []string
+		if changes, statusErr := wm.ChangedFiles(); statusErr == nil {
+			for _, change := range changes {
+				if isUnmerged(change) {
+					conflicted = append(conflicted, change.Path)
+				}
+			}
+		}
+		if len(conflicted) > 0 {
+			return fmt.Errorf("your stashed changes conflict in %s after %s and are kept in the stash as %s: %w", strings.Join(conflicted, ", "), operation, stash, err)
+		}
+		return fmt.Errorf("your stashed changes do not apply cleanly after %s and are kept in the stash as %s: %w", operation, stash, err)
+	}
+	wm.logf("reapplied stashed changes %s", stash)
+	return nil
+}
@@ -0,0 +1,152 @@
+package gitworkflow
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const (
+	autostashCommand = "git stash push --include-untracked -m vamos autostash before sync"
+	stashRefCommand  = "git rev-parse --verify --quiet refs/stash"
+)
+
+func TestAutostashCleanTree(t *testing.T) {
+	executor := NewRecordingExecutor()
+	wm := NewWorkflowManagerWithExecutor(executor)
+
+	ran := false
+	if err := wm.autostash("sync", func() error { ran = true; return nil }); err != nil {
+		t.Fatalf("autostash() unexpected error: %v", err)
+	}
+	if !ran {
+		t.Error("Expected the operation to run")
+	}
+	assertCommands(t, executor, []string{statusCommand})
+}
+
+func TestAutostash(t *testing.T) {
+	executor := NewRecordingExecutor().
+		OnOutput(statusCommand, " M main.go\x00?? notes.txt\x00").
+		OnOutput(stashRefCommand, "3f2a9c1d2e\n")
+	wm := NewWorkflowManagerWithExecutor(executor)
+
+	err := wm.autostash("sync", func() error {
+		_, err := wm.git("merge", "--ff-only", "origin/W-1")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("autostash() unexpected error: %v", err)
+	}
+	assertCommands(t, executor, []string{
+		statusCommand,
+		autostashCommand,
+		stashRefCommand,
+		"git merge --ff-only origin/W-1",
+		"git stash pop",
+	})
+}
+
+func TestAutostashPopConflict(t *testing.T) {
+	executor := NewRecordingExecutor().
+		OnOutput(statusCommand, " M main.go\x00").
+		OnOutput(statusCommand, "UU main.go\x00").
+		OnOutput(stashRefCommand, "3f2a9c1d2e\n").
+		OnFailure("git stash pop", 1, "CONFLICT (content): Merge conflict in main.go\nThe stash entry is kept in case you need it again.")
+	wm := NewWorkflowManagerWithExecutor(executor)
+
+	err := wm.autostash("sync", func() error { return nil })
+	if !errors.Is(err, ErrStashConflict) {
+		t.Fatalf("autostash() error = %v, want %v", err, ErrStashConflict)
+	}
+	if !strings.Contains(err.Error(), "conflict in main.go") || !strings.Contains(err.Error(), "kept in the stash as 3f2a9c1") {
+		t.Errorf("Expected the error to name the files and the stash, got %v", err)
+	}
+	var gitErr *GitError
+	if !errors.As(err, &gitErr) || !strings.Contains(gitErr.Hint(), "git stash drop") {
+		t.Errorf("Expected a recovery hint, got %#v", err)
+	}
+}
+
+func TestAutostashOperationFails(t *testing.T) {
+	executor := conflictRepo(t, "", " M main.go\x00").
+		OnOutput(stashRefCommand, "3f2a9c1d2e\n")
+	wm := NewWorkflowManagerWithExecutor(executor)
+	failure := errors.New("checkout failed")
+
+	err := wm.autostash("sync", func() error { return failure })
+	if !errors.Is(err, failure) {
+		t.Fatalf("autostash() error = %v, want %v", err, failure)
+	}
+	if last := executor.Commands[len(executor.Commands)-1]; last != "git stash pop" {
+		t.Errorf("Expected the stash to be reapplied, last command was %q", last)
+	}
+}
+
+func TestAutostashKeepsStashWhenOperationStops(t *testing.T) {
+	executor := conflictRepo(t, "rebase-merge", " M main.go\x00").
+		OnOutput(stashRefCommand, "3f2a9c1d2e\n")
+	wm := NewWorkflowManagerWithExecutor(executor)
+
+	err := wm.autostash("sync", func() error { return &ConflictError{State: &ConflictState{Operation: OperationRebase}} })
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("autostash() error = %v, want %v", err, ErrConflict)
+	}
+	if !strings.Contains(err.Error(), "run 'git stash pop' once the rebase is finished") {
+		t.Errorf("Expected instructions to reapply the stash, got %v", err)
+	}
+	for _, command := range executor.Commands {
+		if command == "git stash pop" {
+			t.Error("Expected the stash to be kept while the rebase is in progress")
+		}
+	}
+}
+
+func TestSyncAutostash(t *testing.T) {
+	executor := syncRepo("2\t0\n").
+		OnOutput(statusCommand, " M main.go\x00").
+		OnOutput(stashRefCommand, "3f2a9c1d2e\n")
+	wm := NewWorkflowManagerWithExecutor(executor)
+
+	result, err := wm.Sync(SyncOptions{Autostash: true})
+	if err != nil {
+		t.Fatalf("Sync() unexpected error: %v", err)
+	}
+	if result.Action != SyncFastForwarded {
+		t.Errorf("Expected a fast-forward, got %s", result.Action)
+	}
+	assertCommands(t, executor, []string{
+		"git fetch origin",
+		"git rev-parse --abbrev-ref HEAD",
+		upstreamCommand,
+		countCommand,
+		statusCommand,
+		autostashCommand,
+		stashRefCommand,
+		"git merge --ff-only origin/W-1",
+		"git stash pop",
+	})
+}
+
+func TestStartStoryAutostash(t *testing.T) {
+	executor := NewRecordingExecutor().
+		OnOutput(statusCommand, "?? draft.go\x00").
+		OnOutput(stashRefCommand, "3f2a9c1d2e\n").
+		OnOutput("git rev-parse --verify HEAD", "1111111aaaa\n")
+	wm := testManager(executor)
+
+	if _, err := wm.StartStory("456", "chat", StartOptions{Autostash: true}); err != nil {
+		t.Fatalf("StartStory() unexpected error: %v", err)
+	}
+	assertCommands(t, executor, []string{
+		statusCommand,
+		"git stash push --include-untracked -m vamos autostash before story-start W-456-chat",
+		stashRefCommand,
+		"git checkout main",
+		"git pull origin main",
+		"git checkout -b W-456-chat",
+		"git rev-parse --verify HEAD",
+		"git config branch.W-456-chat.vamosBase 1111111aaaa",
+		"git stash pop",
+	})
+}
@@ -0,0 +1,220 @@
+package gitworkflow
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// BackupRefPrefix is the namespace backup refs are written to
+const BackupRefPrefix = "refs/vamos/backup/"
+
+// backupIDFormat is the timestamp part of a backup ID
+const backupIDFormat = "20060102-150405"
+
+// Trailers recording what a backup was taken for
+const (
+	backupBranchTrailer    = "Branch: "
+	backupOperationTrailer = "Operation: "
+)
+
+// Backup is a snapshot of a branch, and of any uncommitted changes, taken before
+// an operation that can lose work. It is stored as a commit under BackupRefPrefix
+// whose first parent is the backed up HEAD and whose second parent, if any, is a
+// stash of the uncommitted changes.
+type Backup struct {
+	// ID names the backup, e.g. 20261016-142501-3f2a9c1
+	ID  string `json:"id"`
+	Ref string `json:"ref"`
+	// Commit is the HEAD commit that was backed up
+	Commit string `json:"commit"`
+	// Stash holds the uncommitted changes at the time, or is empty if there were none
+	Stash string `json:"stash,omitempty"`
+	// Branch is the branch that was checked out, or "HEAD" if it was detached
+	Branch    string    `json:"branch"`
+	Operation string    `json:"operation"`
+	Created   time.Time `json:"created"`
+}
+
+// Backup records the current HEAD and uncommitted changes under BackupRefPrefix.
+// The working tree is left untouched. It returns nil if the repository has no
+// commits yet, since there is nothing to lose.
+func (wm *WorkflowManager) Backup(operation string) (*Backup, error) {
+	head, err := wm.gitOutput("rev-parse", "--verify", "--quiet", "HEAD")
+	if err != nil && exitCode(err) != 1 {
+		return nil, fmt.Errorf("failed to read HEAD: %w", err)
+	}
+	if head == "" {
+		return nil, nil
+	}
+
+	branch, err := wm.GetCurrentBranch()
+	if err != nil {
+		return nil, err
+	}
+
+	// stash create records tracked changes without touching the working tree
+	stash, err := wm.gitOutput("stash", "create")
+	if err != nil {
+		return nil, fmt.Errorf("failed to stash uncommitted changes: %w", err)
+	}
+
+	return wm.writeBackup(head, stash, branch, operation)
+}
+
+// writeBackup records commit, and stash unless it is empty, as a backup of
+// branch taken before operation
+func (wm *WorkflowManager) writeBackup(commit, stash, branch, operation string) (*Backup, error) {
+	message := fmt.Sprintf("vamos backup before %s\n\n%s%s\n%s%s",
+		operation, backupBranchTrailer, branch, backupOperationTrailer, operation)
+	args := []string{"commit-tree", commit + "^{tree}", "-p", commit}
+	if stash != "" {
+		args = append(args, "-p", stash)
+	}
+	backupCommit, err := wm.gitOutput(append(args, "-m", message)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record backup: %w", err)
+	}
+
+	created := wm.now()
+	backup := &Backup{
+		ID:        fmt.Sprintf("%s-%s", created.Format(backupIDFormat), ShortHash(backupCommit)),
+		Commit:    commit,
+		Stash:     stash,
+		Branch:    branch,
+		Operation: operation,
+		Created:   created,
+	}
+	backup.Ref = BackupRefPrefix + backup.ID
+	wm.logf("backing up %s at %s as %s", branch, ShortHash(commit), backup.ID)
+	if _, err := wm.git("update-ref", backup.Ref, backupCommit); err != nil {
+		return nil, fmt.Errorf("failed to write backup ref %s: %w", backup.Ref, err)
+	}
+	return backup, nil
+}
+
+// backupBefore takes a backup before a destructive operation
+func (wm *WorkflowManager) backupBefore(operation string) error {
+	if _, err := wm.Backup(operation); err != nil {
+		return fmt.Errorf("refusing to %s without a backup: %w", operation, err)
+	}
+	return nil
+}
+
+// Backups lists the backups in the repository, newest first
+func (wm *WorkflowManager) Backups() ([]Backup, error) {
+	format := "--format=%(refname)%1f%(parent)%1f%(creatordate:unix)%1f%(contents)%1e"
+	output, err := wm.gitOutput("for-each-ref", "--sort=-creatordate", format, BackupRefPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	var backups []Backup
+	for _, record := range strings.Split(output, logRecordSeparator) {
+		fields := strings.SplitN(strings.TrimSpace(record), logFieldSeparator, 4)
+		if len(fields) != 4 {
+			continue
+		}
+		parents := strings.Fields(fields[1])
+		if len(parents) == 0 {
+			continue
+		}
+
+		backup := Backup{
+			ID:     strings.TrimPrefix(fields[0], BackupRefPrefix),
+			Ref:    fields[0],
+			Commit: parents[0],
+		}
+		if len(parents) > 1 {
+			backup.Stash = parents[1]
+		}
+		var seconds int64
+		if _, err := fmt.Sscan(fields[2], &seconds); err == nil {
+			backup.Created = time.Unix(seconds, 0)
+		}
+		for _, line := range strings.Split(fields[3], "\n") {
+			if value, ok := strings.CutPrefix(line, backupBranchTrailer); ok {
+				backup.Branch = value
+			}
+			if value, ok := strings.CutPrefix(line, backupOperationTrailer); ok {
+				backup.Operation = value
+			}
+		}
+		backups = append(backups, backup)
+	}
+	return backups, nil
+}
+
+// RestoreBackup puts the backed up branch back where it was and reapplies any
+// uncommitted changes. The current state is backed up first, and so is the
+// backed up branch when it is not checked out and has moved since, so a restore
+// can itself be undone; those backups are returned.
+func (wm *WorkflowManager) RestoreBackup(id string) ([]Backup, error) {
+	backups, err := wm.Backups()
+	if err != nil {
+		return nil, err
+	}
+	var backup *Backup
+	for i := range backups {
+		if backups[i].ID == id {
+			backup = &backups[i]
+		}
+	}
+	if backup == nil {
+		return nil, fmt.Errorf("no backup with ID %q; see 'vamosGitWF backups list'", id)
+	}
+
+	var taken []Backup
+	current, err := wm.Backup("restore " + id)
+	if err != nil {
+		return nil, fmt.Errorf("refusing to restore without a backup of the current state: %w", err)
+	}
+	if current != nil {
+		taken = append(taken, *current)
+	}
+
+	detached := backup.Branch == "" || backup.Branch == "HEAD"
+	if !detached && (current == nil || current.Branch != backup.Branch) {
+		// checkout -B moves the branch, which may have new commits since the backup
+		tip, err := wm.gitOutput("rev-parse", "--verify", "--quiet", "refs/heads/"+backup.Branch)
+		if err != nil && exitCode(err) != 1 {
+			return taken, fmt.Errorf("failed to resolve %s: %w", backup.Branch, err)
+		}
+		if tip != "" && tip != backup.Commit {
+			moved, err := wm.writeBackup(tip, "", backup.Branch, "restore "+id)
+			if err != nil {
+				return taken, fmt.Errorf("refusing to restore without a backup of %s: %w", backup.Branch, err)
+			}
+			taken = append(taken, *moved)
+		}
+	}
+
+	// Uncommitted changes are in the backup just taken
+	if _, err := wm.git("reset", "--hard", "--quiet"); err != nil {
+		return taken, fmt.Errorf("failed to clear the working tree: %w", err)
+	}
+
+	checkout := []string{"checkout", "--quiet", "-B", backup.Branch, backup.Commit}
+	if detached {
+		checkout = []string{"checkout", "--quiet", "--detach", backup.Commit}
+	}
+	if _, err := wm.git(checkout...); err != nil {
+		return taken, fmt.Errorf("failed to check out %s: %w", ShortHash(backup.Commit), err)
+	}
+
+	if backup.Stash != "" {
+		if _, err := wm.git("stash", "apply", backup.Stash); err != nil {
+			return taken, fmt.Errorf("restored %s but failed to reapply uncommitted changes from %s: %w",
+				ShortHash(backup.Commit), ShortHash(backup.Stash), err)
+		}
+	}
+	return taken, nil
+}
+
+// ShortHash abbreviates a commit hash for display
+func ShortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
@@ -0,0 +1,194 @@
+package gitworkflow
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const backupListCommand = "git for-each-ref --sort=-creatordate " +
+	"--format=%(refname)%1f%(parent)%1f%(creatordate:unix)%1f%(contents)%1e refs/vamos/backup/"
+
+// backupMessage returns the commit message Backup writes
+func backupMessage(branch, operation string) string {
+	return "vamos backup before " + operation + "\n\nBranch: " + branch + "\nOperation: " + operation
+}
+
+// backupRecord formats one backup as listed by the for-each-ref in Backups
+func backupRecord(id, parents, created, branch, operation string) string {
+	return strings.Join([]string{BackupRefPrefix + id, parents, created, backupMessage(branch, operation)}, "\x1f") + "\x1e\n"
+}
+
+func TestBackup(t *testing.T) {
+	commitTree := "git commit-tree abc1234def^{tree} -p abc1234def -p 5555555eee -m " + backupMessage("W-1-login", "undo --hard")
+	executor := NewRecordingExecutor().
+		OnOutput("git rev-parse --verify --quiet HEAD", "abc1234def\n").
+		OnOutput("git rev-parse --abbrev-ref HEAD", "W-1-login\n").
+		OnOutput("git stash create", "5555555eee\n").
+		OnOutput(commitTree, "9999999fff\n")
+	wm := NewWorkflowManagerWithExecutor(executor)
+	wm.now = fixedNow
+
+	backup, err := wm.Backup("undo --hard")
+	if err != nil {
+		t.Fatalf("Backup() unexpected error: %v", err)
+	}
+	want := Backup{
+		ID:        "20261016-142501-9999999",
+		Ref:       "refs/vamos/backup/20261016-142501-9999999",
+		Commit:    "abc1234def",
+		Stash:     "5555555eee",
+		Branch:    "W-1-login",
+		Operation: "undo --hard",
+		Created:   fixedNow(),
+	}
+	if *backup != want {
+		t.Errorf("Backup() = %+v, want %+v", *backup, want)
+	}
+	assertCommands(t, executor, []string{
+		"git rev-parse --verify --quiet HEAD",
+		"git rev-parse --abbrev-ref HEAD",
+		"git stash create",
+		commitTree,
+		"git update-ref refs/vamos/backup/20261016-142501-9999999 9999999fff",
+	})
+}
+
+func TestBackupWithoutCommits(t *testing.T) {
+	executor := NewRecordingExecutor().OnFailure("git rev-parse --verify --quiet HEAD", 1, "")
+
+	backup, err := NewWorkflowManagerWithExecutor(executor).Backup("undo --hard")
+	if err != nil || backup != nil {
+		t.Fatalf("Backup() = %v, %v; want nothing to back up", backup, err)
+	}
+}
+
+func TestUndoLastCommitHardRefusesWithoutBackup(t *testing.T) {
+	executor := NewRecordingExecutor().
+		OnOutput("git rev-parse --verify --quiet HEAD", "abc1234def\n").
+		OnFailure("git stash create", 128, "fatal: index file corrupt")
+
+	err := NewWorkflowManagerWithExecutor(executor).UndoLastCommitHard()
+	if err == nil || !strings.Contains(err.Error(), "refusing to undo --hard without a backup") {
+		t.Fatalf("Expected undo to be refused, got %v", err)
+	}
+	for _, command := range executor.Commands {
+		if strings.HasPrefix(command, "git reset") {
+			t.Errorf("Expected no reset without a backup, got %q", command)
+		}
+	}
+}
+
+func TestBackups(t *testing.T) {
+	output := backupRecord("20261016-142501-9999999", "abc1234def 5555555eee", "1792160701", "W-1-login", "undo --hard") +
+		backupRecord("20261015-090000-8888888", "0000000aaa", "1792054800", "HEAD", "rebase onto origin/main")
+	executor := NewRecordingExecutor().OnOutput(backupListCommand, output)
+
+	backups, err := NewWorkflowManagerWithExecutor(executor).Backups()
+	if err != nil {
+		t.Fatalf("Backups() unexpected error: %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 backups, got %+v", backups)
+	}
+
+	first := backups[0]
+	if first.ID != "20261016-142501-9999999" || first.Commit != "abc1234def" || first.Stash != "5555555eee" ||
+		first.Branch != "W-1-login" || first.Operation != "undo --hard" || first.Created.Unix() != 1792160701 {
+		t.Errorf("Unexpected first backup: %+v", first)
+	}
+	if second := backups[1]; second.Stash != "" || second.Branch != "HEAD" || second.Operation != "rebase onto origin/main" {
+		t.Errorf("Unexpected second backup: %+v", second)
+	}
+}
+
+func TestRestoreBackup(t *testing.T) {
+	tests := []struct {
+		name    string
+		parents string
+		branch  string
+		want    []string
+	}{
+		{
+			name:    "branch with stashed changes",
+			parents: "abc1234def 5555555eee",
+			branch:  "W-1-login",
+			want: []string{
+				"git rev-parse --verify --quiet refs/heads/W-1-login",
+				"git reset --hard --quiet",
+				"git checkout --quiet -B W-1-login abc1234def",
+				"git stash apply 5555555eee",
+			},
+		},
+		{
+			name:    "detached HEAD",
+			parents: "abc1234def",
+			branch:  "HEAD",
+			want: []string{
+				"git reset --hard --quiet",
+				"git checkout --quiet --detach abc1234def",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := backupRecord("20261016-142501-9999999", tt.parents, "1792160701", tt.branch, "undo --hard")
+			executor := NewRecordingExecutor().OnOutput(backupListCommand, output)
+			wm := NewWorkflowManagerWithExecutor(executor)
+
+			if _, err := wm.RestoreBackup("20261016-142501-9999999"); err != nil {
+				t.Fatalf("RestoreBackup() unexpected error: %v", err)
+			}
+			// The list and the backup of the current state come first; the repository
+			// in this test has no HEAD so that backup is skipped
+			assertCommands(t, executor, append([]string{backupListCommand, "git rev-parse --verify --quiet HEAD"}, tt.want...))
+		})
+	}
+
+	executor := NewRecordingExecutor().OnOutput(backupListCommand, "")
+	if _, err := NewWorkflowManagerWithExecutor(executor).RestoreBackup("missing"); err == nil ||
+		!strings.Contains(err.Error(), "no backup with ID") {
+		t.Errorf("Expected unknown backup error, got %v", err)
+	}
+}
+
+func TestRestoreBackupInRepo(t *testing.T) {
+	repo := newGitRepo(t)
+	wm := repo.manager()
+	repo.git("checkout", "--quiet", "-b", "W-1-login")
+	first := repo.commit("login.go", "feat: add login")
+	if err := os.WriteFile(filepath.Join(repo.dir, "login.go"), []byte("draft\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	backup, err := wm.Backup("undo --hard")
+	if err != nil {
+		t.Fatalf("Backup() unexpected error: %v", err)
+	}
+	if stashes := repo.git("stash", "list"); stashes != "" {
+		t.Errorf("Expected the backup to stay out of git stash list, got %q", stashes)
+	}
+
+	// More work lands on the branch, then the backup is restored from main
+	repo.git("checkout", "--quiet", "--", "login.go")
+	second := repo.commit("logout.go", "feat: add logout")
+	repo.git("checkout", "--quiet", "main")
+
+	taken, err := wm.RestoreBackup(backup.ID)
+	if err != nil {
+		t.Fatalf("RestoreBackup() unexpected error: %v", err)
+	}
+	if head := repo.git("rev-parse", "HEAD"); head != first {
+		t.Errorf("Expected W-1-login back at %s, got %s", first, head)
+	}
+	if content, _ := os.ReadFile(filepath.Join(repo.dir, "login.go")); string(content) != "draft\n" {
+		t.Errorf("Expected the uncommitted change back, got %q", content)
+	}
+	if len(taken) != 2 || taken[0].Branch != "main" || taken[1].Branch != "W-1-login" || taken[1].Commit != second {
+		t.Fatalf("Expected backups of main and of W-1-login at %s, got %+v", second, taken)
+	}
+	if commit := repo.git("rev-parse", taken[1].Ref+"^1"); commit != second {
+		t.Errorf("Expected the commit made since the backup to be kept in %s, got %s", taken[1].Ref, commit)
+	}
+}
@@ -0,0 +1,140 @@
+package gitworkflow
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// DefaultBranchMaxLength is the longest story branch name created when none is configured
+const DefaultBranchMaxLength = 60
+
+// DefaultStoryIDPattern is the story ID format accepted when none is configured
+const DefaultStoryIDPattern = `[A-Za-z0-9]+`
+
+// transliterations folds common accented and ligature letters to ASCII before
+// the remaining non-alphanumeric characters are replaced
+var transliterations = func() *strings.Replacer {
+	folds := map[string]string{
+		"àáâãäåāăą": "a", "çćĉċč": "c", "ďđð": "d", "èéêëēĕėęě": "e", "ĝğġģ": "g",
+		"ĥħ": "h", "ìíîïĩīĭįı": "i", "ĵ": "j", "ķ": "k", "ĺļľŀł": "l", "ñńņňŉ": "n",
+		"òóôõöøōŏő": "o", "ŕŗř": "r", "śŝşš": "s", "ţťŧ": "t", "ùúûüũūŭůűų": "u",
+		"ŵ": "w", "ýÿŷ": "y", "źżž": "z", "æ": "ae", "œ": "oe", "ß": "ss", "þ": "th",
+	}
+	var pairs []string
+	for letters, ascii := range folds {
+		for _, letter := range letters {
+			pairs = append(pairs, string(letter), ascii)
+		}
+	}
+	return strings.NewReplacer(pairs...)
+}()
+
+// StoryBranchName formats the branch name for a story using the configured
+// template. The story ID must match branch.id_pattern; a leading branch prefix
+// is accepted and dropped, so "W-123" and "123" give the same branch. The
+// description is reduced to lowercase ASCII words joined by dashes and cut at
+// a word boundary to keep the name within branch.max_length.
+func (wm *WorkflowManager) StoryBranchName(storyID string, description string) (string, error) {
+	storyID = strings.TrimPrefix(strings.TrimSpace(storyID), wm.config.Branch.Prefix)
+	idPattern := wm.config.Branch.IDPattern
+	if idPattern == "" {
+		idPattern = DefaultStoryIDPattern
+	}
+	validID, err := regexp.Compile(`^(?:` + idPattern + `)$`)
+	if err != nil {
+		return "", fmt.Errorf("branch.id_pattern %q is not a valid regular expression: %w", idPattern, err)
+	}
+	if !validID.MatchString(storyID) {
+		return "", fmt.Errorf("story ID %q must match %s", storyID, idPattern)
+	}
+
+	maxLength := wm.config.Branch.MaxLength
+	if maxLength == 0 {
+		maxLength = DefaultBranchMaxLength
+	}
+	short := wm.formatBranchName(storyID, "")
+	if len(short) > maxLength {
+		return "", fmt.Errorf("branch name %s is longer than the %d characters allowed by branch.max_length", short, maxLength)
+	}
+
+	slug := slugify(description)
+	if slug != "" {
+		// Whatever the template adds besides the description is fixed
+		room := maxLength - (len(wm.formatBranchName(storyID, slug)) - len(slug))
+		slug = truncateSlug(slug, room)
+	}
+	name := wm.formatBranchName(storyID, slug)
+	if err := checkRefFormat(name); err != nil {
+		return "", fmt.Errorf("branch name %s is not valid: %w", name, err)
+	}
+	return name, nil
+}
+
+// formatBranchName fills the branch template. Separators next to an empty
+// description are dropped.
+func (wm *WorkflowManager) formatBranchName(storyID string, description string) string {
+	name := strings.ReplaceAll(wm.config.Branch.Template, placeholderPrefix, wm.config.Branch.Prefix)
+	name = strings.ReplaceAll(name, placeholderID, storyID)
+	if description == "" {
+		for _, sep := range []string{"-", "_", "/", "."} {
+			name = strings.ReplaceAll(name, sep+placeholderDescription, "")
+			name = strings.ReplaceAll(name, placeholderDescription+sep, "")
+		}
+	}
+	return strings.ReplaceAll(name, placeholderDescription, description)
+}
+
+// slugify lowercases text, folds accented letters to ASCII and joins the
+// remaining runs of letters and digits with single dashes
+func slugify(text string) string {
+	text = transliterations.Replace(strings.ToLower(text))
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r))
+	})
+	return strings.Join(words, "-")
+}
+
+// truncateSlug shortens slug to at most length characters, cutting after a
+// whole word when there is one that fits
+func truncateSlug(slug string, length int) string {
+	if len(slug) <= length {
+		return slug
+	}
+	if length <= 0 {
+		return ""
+	}
+	cut := slug[:length]
+	if slug[length] != '-' {
+		if i := strings.LastIndex(cut, "-"); i > 0 {
+			cut = cut[:i]
+		}
+	}
+	return strings.TrimRight(cut, "-")
+}
+
+// checkRefFormat applies the rules of git check-ref-format --branch to name
+func checkRefFormat(name string) error {
+	switch {
+	case name == "" || name == "@":
+		return fmt.Errorf("%q is not a branch name", name)
+	case strings.HasPrefix(name, "-"):
+		return fmt.Errorf("it must not start with a dash")
+	case strings.HasSuffix(name, "/") || strings.HasSuffix(name, "."):
+		return fmt.Errorf("it must not end with a slash or a dot")
+	case strings.Contains(name, ".."), strings.Contains(name, "@{"), strings.Contains(name, "//"):
+		return fmt.Errorf("it must not contain '..', '@{' or '//'")
+	}
+	for _, r := range name {
+		if r < ' ' || r == 0x7f || strings.ContainsRune(" ~^:?*[\\", r) {
+			return fmt.Errorf("it must not contain %q", r)
+		}
+	}
+	for _, component := range strings.Split(name, "/") {
+		if strings.HasPrefix(component, ".") || strings.HasSuffix(component, ".lock") {
+			return fmt.Errorf("no part of it may start with a dot or end with .lock")
+		}
+	}
+	return nil
+}
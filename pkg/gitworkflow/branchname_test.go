@@ -0,0 +1,96 @@
+package gitworkflow
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStoryBranchNameSanitizes(t *testing.T) {
+	tests := []struct {
+		name        string
+		storyID     string
+		description string
+		want        string
+	}{
+		{name: "punctuation", storyID: "7", description: `Fix: "login" / logout...`, want: "W-7-fix-login-logout"},
+		{name: "unicode", storyID: "7", description: "Crème brûlée für Straße", want: "W-7-creme-brulee-fur-strasse"},
+		{name: "git metacharacters", storyID: "7", description: "a~b^c:d?e*f[g\\h @{i} ..j.lock", want: "W-7-a-b-c-d-e-f-g-h-i-j-lock"},
+		{name: "only symbols", storyID: "7", description: "!!! ???", want: "W-7"},
+		{name: "non-latin script", storyID: "7", description: "登录 page", want: "W-7-page"},
+		{name: "prefixed story ID", storyID: " W-7 ", description: "search", want: "W-7-search"},
+		{name: "long description", storyID: "123", description: strings.Repeat("word ", 20), want: "W-123-" + strings.TrimSuffix(strings.Repeat("word-", 11), "-")},
+		{name: "long word", storyID: "123", description: strings.Repeat("x", 80), want: "W-123-" + strings.Repeat("x", 54)},
+	}
+
+	wm := NewWorkflowManagerWithExecutor(NewRecordingExecutor())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := wm.StoryBranchName(tt.storyID, tt.description)
+			if err != nil {
+				t.Fatalf("StoryBranchName() unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("StoryBranchName() = %q, want %q", got, tt.want)
+			}
+			if len(got) > DefaultBranchMaxLength {
+				t.Errorf("StoryBranchName() = %q is longer than %d characters", got, DefaultBranchMaxLength)
+			}
+		})
+	}
+}
+
+func TestStoryBranchNameRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		storyID string
+		config  func(*Config)
+		wantErr string
+	}{
+		{name: "empty story ID", storyID: "", wantErr: `story ID "" must match`},
+		{name: "story ID with spaces", storyID: "12 3", wantErr: `story ID "12 3" must match`},
+		{name: "story ID with a slash", storyID: "1/2", wantErr: "must match [A-Za-z0-9]+"},
+		{name: "custom pattern", storyID: "abc", config: func(c *Config) { c.Branch.IDPattern = `[0-9]+` }, wantErr: "must match [0-9]+"},
+		{name: "story ID too long", storyID: "1234567890", config: func(c *Config) { c.Branch.MaxLength = 8 }, wantErr: "longer than the 8 characters"},
+		{name: "invalid template", storyID: "1", config: func(c *Config) { c.Branch.Template = "{prefix}{id}.lock" }, wantErr: "end with .lock"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			if tt.config != nil {
+				tt.config(&config)
+			}
+			wm := NewWorkflowManagerWithConfig(config, NewRecordingExecutor())
+			if _, err := wm.StoryBranchName(tt.storyID, "description"); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("StoryBranchName() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStoryBranchNameMaxLengthWithTemplate(t *testing.T) {
+	config := DefaultConfig()
+	config.Branch.Template = "feature/{prefix}{id}/{description}"
+	config.Branch.MaxLength = 30
+	wm := NewWorkflowManagerWithConfig(config, NewRecordingExecutor())
+
+	got, err := wm.StoryBranchName("42", "Show unread counts in the sidebar")
+	if err != nil {
+		t.Fatalf("StoryBranchName() unexpected error: %v", err)
+	}
+	if got != "feature/W-42/show-unread" {
+		t.Errorf("StoryBranchName() = %q, want feature/W-42/show-unread", got)
+	}
+}
+
+func TestStartStoryRejectsInvalidStoryID(t *testing.T) {
+	executor := NewRecordingExecutor()
+	wm := NewWorkflowManagerWithExecutor(executor)
+
+	if _, err := wm.StartStory("", "chat", StartOptions{}); err == nil {
+		t.Fatal("Expected an empty story ID to be rejected")
+	}
+	if len(executor.Commands) != 0 {
+		t.Errorf("Expected no commands, got %q", executor.Commands)
+	}
+}
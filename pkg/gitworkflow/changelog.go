@@ -0,0 +1,298 @@
+package gitworkflow
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// changelogHeading is the title expected at the top of a changelog file
+const changelogHeading = "# Changelog"
+
+// otherChangesType groups commits that do not follow Conventional Commits
+const otherChangesType = "other"
+
+// commitTypeTitles maps commit types to changelog section titles
+var commitTypeTitles = map[string]string{
+	"feat":           "Features",
+	"fix":            "Bug Fixes",
+	"perf":           "Performance Improvements",
+	"refactor":       "Code Refactoring",
+	"docs":           "Documentation",
+	"style":          "Styles",
+	"test":           "Tests",
+	"build":          "Build System",
+	"ci":             "Continuous Integration",
+	"chore":          "Chores",
+	"revert":         "Reverts",
+	otherChangesType: "Other Changes",
+}
+
+// Log record and field separators used when reading commits with git log
+const (
+	logRecordSeparator = "\x1e"
+	logFieldSeparator  = "\x1f"
+)
+
+// Commit is a commit read from history together with its parsed message
+type Commit struct {
+	Hash    string        `json:"hash"`
+	Subject string        `json:"subject"`
+	Message CommitMessage `json:"-"`
+	// Body is everything after the subject line as written, whether or not the
+	// message follows Conventional Commits
+	Body string `json:"-"`
+	// Conventional is false when the message does not follow Conventional Commits
+	Conventional bool `json:"conventional"`
+}
+
+// Changelog is the set of changes between two revisions grouped by commit type
+type Changelog struct {
+	Version  string             `json:"version"`
+	From     string             `json:"from,omitempty"`
+	To       string             `json:"to"`
+	Date     string             `json:"date"`
+	Summary  string             `json:"summary,omitempty"`
+	Breaking []ChangelogEntry   `json:"breaking,omitempty"`
+	Sections []ChangelogSection `json:"sections"`
+}
+
+// ChangelogSection holds the entries for one commit type
+type ChangelogSection struct {
+	Type    string           `json:"type"`
+	Title   string           `json:"title"`
+	Entries []ChangelogEntry `json:"entries"`
+}
+
+// ChangelogEntry is a single change in the changelog
+type ChangelogEntry struct {
+	Scope          string   `json:"scope,omitempty"`
+	Description    string   `json:"description"`
+	Hash           string   `json:"hash"`
+	StoryIDs       []string `json:"story_ids,omitempty"`
+	BreakingChange string   `json:"breaking_change,omitempty"`
+}
+
+// CommitsBetween returns the commits reachable from to but not from, newest first.
+// An empty from includes the whole history of to.
+func (wm *WorkflowManager) CommitsBetween(from, to string) ([]Commit, error) {
+	revisionRange := to
+	if from != "" {
+		revisionRange = from + ".." + to
+	}
+
+	format := "--format=%H" + logFieldSeparator + "%B" + logRecordSeparator
+	output, err := wm.gitOutput("log", format, revisionRange)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commits in %s: %w", revisionRange, err)
+	}
+
+	var commits []Commit
+	for _, record := range strings.Split(output, logRecordSeparator) {
+		hash, body, ok := strings.Cut(strings.TrimSpace(record), logFieldSeparator)
+		if !ok {
+			continue
+		}
+		subject, rest, _ := strings.Cut(strings.TrimSpace(body), "\n")
+		commit := Commit{Hash: hash, Subject: subject, Body: strings.TrimSpace(rest)}
+		if message, err := ParseCommitMessage(body); err == nil {
+			commit.Message = message
+			commit.Conventional = true
+		}
+		commits = append(commits, commit)
+	}
+	return commits, nil
+}
+
+// LatestTag returns the most recent tag reachable from revision, or an empty string if there is none
+func (wm *WorkflowManager) LatestTag(revision string) (string, error) {
+	tag, err := wm.gitOutput("describe", "--tags", "--abbrev=0", revision)
+	if err != nil {
+		// No tags yet, or revision is the parent of the root commit
+		var exitErr *ExitError
+		if errors.As(err, &exitErr) && (strings.Contains(exitErr.Stderr, "No names found") ||
+			strings.Contains(exitErr.Stderr, "No tags can describe") ||
+			strings.Contains(exitErr.Stderr, "Not a valid object name")) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to find latest tag: %w", err)
+	}
+	return tag, nil
+}
+
+// GenerateChangelog collects the commits between two revisions into a changelog.
+// An empty to means HEAD. An empty from means the latest tag when to is HEAD,
+// and the tag before to otherwise.
+func (wm *WorkflowManager) GenerateChangelog(from, to string) (*Changelog, error) {
+	if to == "" {
+		to = "HEAD"
+	}
+	if from == "" {
+		since := to + "^"
+		if to == "HEAD" {
+			since = to
+		}
+		previous, err := wm.LatestTag(since)
+		if err != nil {
+			return nil, err
+		}
+		from = previous
+	}
+
+	commits, err := wm.CommitsBetween(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	date, err := wm.gitOutput("log", "-1", "--format=%as", to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read date of %s: %w", to, err)
+	}
+
+	changelog := &Changelog{
+		Version: to,
+		From:    from,
+		To:      to,
+		Date:    date,
+	}
+	if to == "HEAD" {
+		changelog.Version = "Unreleased"
+	} else if summary, err := wm.gitOutput("tag", "--list", "--format=%(contents:subject)", to); err == nil {
+		changelog.Summary = summary
+	}
+
+	sections := make(map[string]*ChangelogSection)
+	for _, commit := range commits {
+		entry := ChangelogEntry{Hash: commit.Hash, Description: commit.Subject}
+		commitType := otherChangesType
+		if commit.Conventional {
+			commitType = commit.Message.Type
+			entry.Scope = commit.Message.Scope
+			entry.Description = commit.Message.Description
+			entry.StoryIDs = commit.Message.Refs
+			entry.BreakingChange = commit.Message.BreakingChange
+			if commit.Message.IsBreaking() {
+				changelog.Breaking = append(changelog.Breaking, entry)
+			}
+		}
+
+		section, ok := sections[commitType]
+		if !ok {
+			section = &ChangelogSection{Type: commitType, Title: commitTypeTitle(commitType)}
+			sections[commitType] = section
+		}
+		section.Entries = append(section.Entries, entry)
+	}
+
+	// Sections follow the configured type order, then any other types alphabetically
+	order := append([]string(nil), wm.config.Commit.Types...)
+	var extra []string
+	for commitType := range sections {
+		if !contains(order, commitType) && commitType != otherChangesType {
+			extra = append(extra, commitType)
+		}
+	}
+	sort.Strings(extra)
+	order = append(append(order, extra...), otherChangesType)
+
+	for _, commitType := range order {
+		if section, ok := sections[commitType]; ok {
+			sort.SliceStable(section.Entries, func(i, j int) bool {
+				return section.Entries[i].Scope < section.Entries[j].Scope
+			})
+			changelog.Sections = append(changelog.Sections, *section)
+		}
+	}
+
+	return changelog, nil
+}
+
+// Markdown renders the changelog as a markdown section. Story IDs are linked
+// when storyURL is set; "{id}" in it is replaced with the story ID.
+func (c *Changelog) Markdown(storyURL string) string {
+	var md strings.Builder
+	fmt.Fprintf(&md, "## %s (%s)\n", c.Version, c.Date)
+	if c.Summary != "" {
+		fmt.Fprintf(&md, "\n%s\n", c.Summary)
+	}
+
+	if len(c.Breaking) > 0 {
+		md.WriteString("\n### ⚠ BREAKING CHANGES\n\n")
+		for _, entry := range c.Breaking {
+			description := entry.Description
+			if entry.BreakingChange != "" {
+				description = entry.BreakingChange
+			}
+			md.WriteString(formatChangelogEntry(entry, description, storyURL))
+		}
+	}
+
+	for _, section := range c.Sections {
+		fmt.Fprintf(&md, "\n### %s\n\n", section.Title)
+		for _, entry := range section.Entries {
+			md.WriteString(formatChangelogEntry(entry, entry.Description, storyURL))
+		}
+	}
+
+	if len(c.Sections) == 0 {
+		md.WriteString("\nNo changes.\n")
+	}
+	return md.String()
+}
+
+// formatChangelogEntry renders one markdown bullet
+func formatChangelogEntry(entry ChangelogEntry, description, storyURL string) string {
+	var line strings.Builder
+	line.WriteString("- ")
+	if entry.Scope != "" {
+		fmt.Fprintf(&line, "**%s:** ", entry.Scope)
+	}
+	line.WriteString(description)
+
+	if len(entry.StoryIDs) > 0 {
+		stories := make([]string, 0, len(entry.StoryIDs))
+		for _, id := range entry.StoryIDs {
+			if storyURL != "" {
+				stories = append(stories, fmt.Sprintf("[%s](%s)", id, strings.ReplaceAll(storyURL, placeholderID, id)))
+			} else {
+				stories = append(stories, id)
+			}
+		}
+		fmt.Fprintf(&line, " (%s)", strings.Join(stories, ", "))
+	}
+
+	fmt.Fprintf(&line, " (%s)\n", ShortHash(entry.Hash))
+	return line.String()
+}
+
+// PrependChangelog inserts section at the top of the changelog file at path,
+// below its "# Changelog" heading, creating the file if it does not exist
+func PrependChangelog(path, section string) error {
+	existing, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	body := strings.TrimLeft(string(existing), "\n")
+	body = strings.TrimLeft(strings.TrimPrefix(body, changelogHeading), "\n")
+
+	content := changelogHeading + "\n\n" + strings.TrimRight(section, "\n") + "\n"
+	if body != "" {
+		content += "\n" + body
+	}
+
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// commitTypeTitle returns the changelog section title for a commit type
+func commitTypeTitle(commitType string) string {
+	if title, ok := commitTypeTitles[commitType]; ok {
+		return title
+	}
+	return strings.ToUpper(commitType[:1]) + commitType[1:]
+}
@@ -0,0 +1,120 @@
+package gitworkflow
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// logRecord formats a commit the way CommitsBetween asks git log to print it
+func logRecord(hash, message string) string {
+	return hash + logFieldSeparator + message + "\n" + logRecordSeparator + "\n"
+}
+
+const changelogLogCommand = "git log --format=%H\x1f%B\x1e v1.0.0..v1.1.0"
+
+func TestGenerateChangelog(t *testing.T) {
+	executor := NewRecordingExecutor().
+		OnOutput(changelogLogCommand,
+			logRecord("aaaaaaaaaa", "feat(chat): add message bubble\n\nRefs: W-12")+
+				logRecord("bbbbbbbbbb", "fix(auth): handle expired tokens\n\nRefs: W-13")+
+				logRecord("cccccccccc", "feat(api)!: drop v1 endpoints\n\nBREAKING CHANGE: /v1 is gone\nRefs: W-14")+
+				logRecord("dddddddddd", "Merge branch 'W-12'")+
+				logRecord("eeeeeeeeee", "feat(auth): add logout")).
+		OnOutput("git log -1 --format=%as v1.1.0", "2026-10-16\n").
+		OnOutput("git tag --list --format=%(contents:subject) v1.1.0", "Chat release\n")
+	wm := NewWorkflowManagerWithExecutor(executor)
+
+	changelog, err := wm.GenerateChangelog("v1.0.0", "v1.1.0")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if changelog.Version != "v1.1.0" || changelog.Date != "2026-10-16" || changelog.Summary != "Chat release" {
+		t.Errorf("Unexpected changelog metadata: %+v", changelog)
+	}
+	var titles []string
+	for _, section := range changelog.Sections {
+		titles = append(titles, section.Title)
+	}
+	if strings.Join(titles, ",") != "Features,Bug Fixes,Other Changes" {
+		t.Fatalf("Unexpected sections %v", titles)
+	}
+
+	want := `## v1.1.0 (2026-10-16)
+
+Chat release
+
+### ⚠ BREAKING CHANGES
+
+- **api:** /v1 is gone ([W-14](https://tracker.example.com/W-14)) (ccccccc)
+
+### Features
+
+- **api:** drop v1 endpoints ([W-14](https://tracker.example.com/W-14)) (ccccccc)
+- **auth:** add logout (eeeeeee)
+- **chat:** add message bubble ([W-12](https://tracker.example.com/W-12)) (aaaaaaa)
+
+### Bug Fixes
+
+- **auth:** handle expired tokens ([W-13](https://tracker.example.com/W-13)) (bbbbbbb)
+
+### Other Changes
+
+- Merge branch 'W-12' (ddddddd)
+`
+	if got := changelog.Markdown("https://tracker.example.com/{id}"); got != want {
+		t.Errorf("Markdown() mismatch:\n got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGenerateChangelogDefaultsToPreviousTag(t *testing.T) {
+	executor := NewRecordingExecutor().
+		OnOutput("git describe --tags --abbrev=0 HEAD", "v2.0.0\n").
+		OnOutput("git log --format=%H\x1f%B\x1e v2.0.0..HEAD", logRecord("ffffffffff", "docs: update readme")).
+		OnOutput("git log -1 --format=%as HEAD", "2026-10-16\n")
+	wm := NewWorkflowManagerWithExecutor(executor)
+
+	changelog, err := wm.GenerateChangelog("", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if changelog.Version != "Unreleased" || changelog.From != "v2.0.0" {
+		t.Errorf("Unexpected changelog range: %+v", changelog)
+	}
+	if !strings.Contains(changelog.Markdown(""), "### Documentation\n\n- update readme (fffffff)") {
+		t.Errorf("Unexpected markdown:\n%s", changelog.Markdown(""))
+	}
+}
+
+func TestLatestTagWithoutTags(t *testing.T) {
+	executor := NewRecordingExecutor().
+		OnFailure("git describe --tags --abbrev=0 HEAD", 128, "fatal: No names found, cannot describe anything.")
+	wm := NewWorkflowManagerWithExecutor(executor)
+
+	tag, err := wm.LatestTag("HEAD")
+	if err != nil || tag != "" {
+		t.Errorf("LatestTag() = %q, %v; want empty tag and no error", tag, err)
+	}
+}
+
+func TestPrependChangelog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "CHANGELOG.md")
+
+	if err := PrependChangelog(path, "## v1.0.0 (2026-01-01)\n\n- first\n"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := PrependChangelog(path, "## v1.1.0 (2026-02-01)\n\n- second\n"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read changelog: %v", err)
+	}
+	want := "# Changelog\n\n## v1.1.0 (2026-02-01)\n\n- second\n\n## v1.0.0 (2026-01-01)\n\n- first\n"
+	if string(data) != want {
+		t.Errorf("Unexpected changelog:\n%q\nwant:\n%q", string(data), want)
+	}
+}
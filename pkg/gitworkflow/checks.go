@@ -0,0 +1,342 @@
+package gitworkflow
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Built-in checks that can be listed in push.checks. Any other entry is run
+// as a shell command, e.g. "make lint".
+const (
+	CheckBranch  = "branch"
+	CheckCommits = "commits"
+	CheckFmt     = "fmt"
+	CheckVet     = "vet"
+	CheckTest    = "test"
+)
+
+// ErrChecksFailed is returned when a pre-push check fails
+var ErrChecksFailed = errors.New("pre-push checks failed")
+
+// CheckStatus is the outcome of one pre-push check
+type CheckStatus string
+
+// Statuses reported in CheckResult.Status
+const (
+	CheckPassed  CheckStatus = "ok"
+	CheckFailed  CheckStatus = "FAIL"
+	CheckSkipped CheckStatus = "skip"
+)
+
+// CheckResult is the outcome of one pre-push check
+type CheckResult struct {
+	Name   string      `json:"name"`
+	Status CheckStatus `json:"status"`
+	// Output explains a failure or why the check was skipped
+	Output string `json:"output,omitempty"`
+}
+
+// ChecksError reports the pre-push checks of which at least one failed
+type ChecksError struct {
+	Results []CheckResult
+}
+
+// Error names the failed checks
+func (e *ChecksError) Error() string {
+	var failed []string
+	for _, result := range e.Results {
+		if result.Status == CheckFailed {
+			failed = append(failed, result.Name)
+		}
+	}
+	return fmt.Sprintf("%d of %d pre-push check(s) failed: %s", len(failed), len(e.Results), strings.Join(failed, ", "))
+}
+
+// Is makes errors.Is(err, ErrChecksFailed) true
+func (e *ChecksError) Is(target error) bool {
+	return target == ErrChecksFailed
+}
+
+// Report lists every check with its status and the output of those that did not pass
+func (e *ChecksError) Report() string {
+	var report strings.Builder
+	for _, result := range e.Results {
+		fmt.Fprintf(&report, "  %-4s  %s\n", result.Status, result.Name)
+		if output := strings.TrimSpace(result.Output); output != "" {
+			report.WriteString(indent(output, "        ") + "\n")
+		}
+	}
+	return report.String()
+}
+
+// PushOptions controls PushStory
+type PushOptions struct {
+	// SkipChecks pushes without running the push.checks from the config
+	SkipChecks bool
+}
+
+// PushStory runs the configured pre-push checks and, if they all pass, pushes
+// the current story branch to the remote. Failed checks are returned as a
+// *ChecksError and nothing is pushed.
+func (wm *WorkflowManager) PushStory(options PushOptions) error {
+	branchName, err := wm.GetCurrentBranch()
+	if err != nil {
+		return fmt.Errorf("failed to get current branch: %w", err)
+	}
+
+	if !options.SkipChecks && len(wm.config.Push.Checks) > 0 {
+		if err := wm.RunChecks(wm.config.Push.Checks); err != nil {
+			return err
+		}
+	}
+
+	if _, err := wm.git("push", "-u", wm.config.Remote, branchName); err != nil {
+		return fmt.Errorf("failed to push branch: %w", err)
+	}
+	return nil
+}
+
+// RunChecks runs checks in order against the current branch and returns a
+// *ChecksError if any of them fails. Every check but branch and commits runs
+// from the root of the repository. The fmt, vet and test checks only look at
+// the Go files changed since the branch left the remote base branch.
+func (wm *WorkflowManager) RunChecks(checks []string) error {
+	var results []CheckResult
+	var changed []string
+	var root string
+	changedRead := false
+	failed := false
+
+	for _, check := range checks {
+		if root == "" && check != CheckBranch && check != CheckCommits {
+			output, err := wm.gitOutput("rev-parse", "--show-toplevel")
+			if err != nil {
+				return fmt.Errorf("failed to find repository root: %w", err)
+			}
+			root = output
+		}
+		if !changedRead && (check == CheckFmt || check == CheckVet || check == CheckTest) {
+			files, err := wm.changedGoFiles()
+			if err != nil {
+				return err
+			}
+			changed, changedRead = files, true
+		}
+
+		wm.logf("running the %s check", check)
+		var result CheckResult
+		switch check {
+		case CheckBranch:
+			result = wm.checkBranch()
+		case CheckCommits:
+			result = wm.checkCommits()
+		case CheckFmt:
+			result = wm.checkFmt(root, changed)
+		case CheckVet:
+			result = wm.checkGo(root, "vet", changed)
+		case CheckTest:
+			result = wm.checkGo(root, "test", changed)
+		default:
+			result = wm.runCheckCommand("sh", "-c", "cd "+shellQuote(root)+" && "+check)
+		}
+		result.Name = check
+		failed = failed || result.Status == CheckFailed
+		results = append(results, result)
+	}
+
+	if failed {
+		return &ChecksError{Results: results}
+	}
+	return nil
+}
+
+// checkBranch checks that the current branch follows the naming convention
+func (wm *WorkflowManager) checkBranch() CheckResult {
+	branch, err := wm.GetCurrentBranch()
+	if err == nil {
+		err = wm.validateBranchName(branch)
+	}
+	if err != nil {
+		return CheckResult{Status: CheckFailed, Output: err.Error()}
+	}
+	return CheckResult{Status: CheckPassed}
+}
+
+// checkCommits checks the headers of the commits that are not on the remote base branch yet
+func (wm *WorkflowManager) checkCommits() CheckResult {
+	baseBranch, err := wm.getDefaultBranch()
+	if err != nil {
+		return CheckResult{Status: CheckFailed, Output: err.Error()}
+	}
+	commits, err := wm.CommitsBetween(wm.remoteRef(baseBranch), "HEAD")
+	if err != nil {
+		return CheckResult{Status: CheckFailed, Output: err.Error()}
+	}
+
+	var problems []string
+	for _, commit := range commits {
+		if strings.HasPrefix(commit.Subject, "Merge ") {
+			continue
+		}
+		if strings.HasPrefix(commit.Subject, "fixup! ") || strings.HasPrefix(commit.Subject, "squash! ") {
+			problems = append(problems, fmt.Sprintf("%s %s: fold it in with 'git rebase -i --autosquash' first", commit.ShortHash(), commit.Subject))
+			continue
+		}
+		if err := ValidateCommitHeader(commit.Subject, wm.config.Commit); err != nil {
+			problems = append(problems, fmt.Sprintf("%s %s: %v", commit.ShortHash(), commit.Subject, err))
+		}
+	}
+	if len(problems) > 0 {
+		return CheckResult{Status: CheckFailed, Output: strings.Join(problems, "\n")}
+	}
+	return CheckResult{Status: CheckPassed}
+}
+
+// checkFmt lists the changed Go files gofmt would reformat, relative to root
+func (wm *WorkflowManager) checkFmt(root string, files []string) CheckResult {
+	if len(files) == 0 {
+		return CheckResult{Status: CheckSkipped, Output: "no changed Go files"}
+	}
+	args := []string{"-l"}
+	for _, file := range files {
+		args = append(args, filepath.Join(root, file))
+	}
+	result := wm.runCheckCommand("gofmt", args...)
+	result.Output = strings.ReplaceAll(result.Output, root+string(filepath.Separator), "")
+	if result.Status == CheckPassed && strings.TrimSpace(result.Output) != "" {
+		return CheckResult{Status: CheckFailed, Output: "not formatted, run 'gofmt -w' on:\n" + result.Output}
+	}
+	return result
+}
+
+// checkGo runs a go subcommand such as vet or test on the packages of the
+// changed files, from the directory of the module each of them belongs to
+func (wm *WorkflowManager) checkGo(root, subcommand string, files []string) CheckResult {
+	modules := goModules(root, files)
+	var dirs []string
+	for dir := range modules {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	result := CheckResult{Status: CheckSkipped, Output: "no changed Go packages"}
+	var failures []string
+	for _, dir := range dirs {
+		packages := goPackages(modules[dir])
+		if len(packages) == 0 {
+			continue
+		}
+		moduleResult := wm.runCheckCommand("go", append([]string{"-C", filepath.Join(root, dir), subcommand}, packages...)...)
+		if moduleResult.Status == CheckFailed {
+			failures = append(failures, moduleResult.Output)
+		}
+		result = CheckResult{Status: CheckPassed}
+	}
+	// Only a failure's output is worth showing
+	if len(failures) > 0 {
+		return CheckResult{Status: CheckFailed, Output: strings.Join(failures, "\n")}
+	}
+	return result
+}
+
+// runCheckCommand runs a command and turns its exit status and output into a CheckResult
+func (wm *WorkflowManager) runCheckCommand(name string, args ...string) CheckResult {
+	result, err := wm.executor.Execute(name, args...)
+	output := strings.TrimSpace(strings.TrimSpace(result.Stdout) + "\n" + strings.TrimSpace(result.Stderr))
+	if err != nil {
+		var exitErr *ExitError
+		if !errors.As(err, &exitErr) {
+			return CheckResult{Status: CheckFailed, Output: fmt.Sprintf("%s could not be run: %v", name, err)}
+		}
+		if output == "" {
+			output = fmt.Sprintf("%s exited with status %d", formatCommand(name, args), exitErr.ExitCode)
+		}
+		return CheckResult{Status: CheckFailed, Output: output}
+	}
+	return CheckResult{Status: CheckPassed, Output: output}
+}
+
+// changedGoFiles returns the Go files added or modified since the current
+// branch left the remote base branch, relative to the repository root
+func (wm *WorkflowManager) changedGoFiles() ([]string, error) {
+	baseBranch, err := wm.getDefaultBranch()
+	if err != nil {
+		return nil, err
+	}
+	output, err := wm.gitOutput("diff", "--name-only", "--diff-filter=d", wm.remoteRef(baseBranch)+"...HEAD", "--", ":(top)*.go")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list changed files: %w", err)
+	}
+	var files []string
+	for _, file := range strings.Split(output, "\n") {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// goModules groups files, given relative to root, by the directory of the
+// nearest go.mod above them. The directories are relative to root and the
+// files relative to their module. Files without a go.mod above them are
+// grouped under root.
+func goModules(root string, files []string) map[string][]string {
+	moduleOf := map[string]string{}
+	var find func(dir string) string
+	find = func(dir string) string {
+		if module, ok := moduleOf[dir]; ok {
+			return module
+		}
+		module := "."
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(dir), "go.mod")); err == nil {
+			module = dir
+		} else if dir != "." {
+			module = find(path.Dir(dir))
+		}
+		moduleOf[dir] = module
+		return module
+	}
+
+	modules := map[string][]string{}
+	for _, file := range files {
+		module := find(path.Dir(file))
+		if module != "." {
+			file = strings.TrimPrefix(file, module+"/")
+		}
+		modules[module] = append(modules[module], file)
+	}
+	return modules
+}
+
+// goPackages returns the package directories of files as relative import
+// paths, e.g. "./pkg/gitworkflow", skipping testdata and vendor directories
+func goPackages(files []string) []string {
+	seen := map[string]bool{}
+	var packages []string
+	for _, file := range files {
+		dir := path.Dir(file)
+		if strings.Contains("/"+dir+"/", "/testdata/") || strings.Contains("/"+dir+"/", "/vendor/") {
+			continue
+		}
+		pkg := "./" + dir
+		if dir == "." {
+			pkg = "."
+		}
+		if !seen[pkg] {
+			seen[pkg] = true
+			packages = append(packages, pkg)
+		}
+	}
+	sort.Strings(packages)
+	return packages
+}
+
+// shellQuote quotes s as a single word for sh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
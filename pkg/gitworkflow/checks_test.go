@@ -0,0 +1,169 @@
+package gitworkflow
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const changedGoFilesCommand = "git diff --name-only --diff-filter=d origin/main...HEAD -- :(top)*.go"
+
+// checksRepo returns a manager on W-1-login with the given push checks whose
+// branch changed main.go and two files in pkg/chat
+func checksRepo(checks ...string) (*WorkflowManager, *RecordingExecutor) {
+	executor := NewRecordingExecutor().
+		OnOutput("git rev-parse --abbrev-ref HEAD", "W-1-login\n").
+		OnOutput("git rev-parse --show-toplevel", "/src/chat\n").
+		OnOutput(changedGoFilesCommand, "main.go\npkg/chat/bubble.go\npkg/chat/bubble_test.go\n").
+		OnOutput("git log --format=%H\x1f%B\x1e origin/main..HEAD", logRecord("2222222bbbb", "fix(chat): wrap long lines")+logRecord("1111111aaaa", "feat(chat): add bubble"))
+	wm := testManager(executor, func(config *Config) { config.Push.Checks = checks })
+	return wm, executor
+}
+
+func TestPushStoryRunsChecks(t *testing.T) {
+	wm, executor := checksRepo(CheckBranch, CheckCommits, CheckFmt, CheckVet, CheckTest, "make lint")
+	if err := wm.PushStory(PushOptions{}); err != nil {
+		t.Fatalf("PushStory() unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		"gofmt -l /src/chat/main.go /src/chat/pkg/chat/bubble.go /src/chat/pkg/chat/bubble_test.go",
+		"go -C /src/chat vet . ./pkg/chat",
+		"go -C /src/chat test . ./pkg/chat",
+		"sh -c cd '/src/chat' && make lint",
+	} {
+		if !contains(executor.Commands, want) {
+			t.Errorf("Expected %q to run, got %q", want, executor.Commands)
+		}
+	}
+	if last := executor.Commands[len(executor.Commands)-1]; last != "git push -u origin W-1-login" {
+		t.Errorf("Expected the branch to be pushed last, got %q", last)
+	}
+}
+
+func TestPushStoryChecksFail(t *testing.T) {
+	wm, executor := checksRepo(CheckBranch, CheckCommits, CheckFmt, CheckTest)
+	executor.
+		OnOutput("gofmt -l /src/chat/main.go /src/chat/pkg/chat/bubble.go /src/chat/pkg/chat/bubble_test.go", "/src/chat/pkg/chat/bubble.go\n").
+		On("go -C /src/chat test . ./pkg/chat", CommandResult{Stdout: "--- FAIL: TestBubble\nFAIL\tpkg/chat\n", ExitCode: 1})
+
+	err := wm.PushStory(PushOptions{})
+	var checksErr *ChecksError
+	if !errors.As(err, &checksErr) || !errors.Is(err, ErrChecksFailed) {
+		t.Fatalf("PushStory() error = %v, want a *ChecksError", err)
+	}
+	if err.Error() != "2 of 4 pre-push check(s) failed: fmt, test" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	report := checksErr.Report()
+	for _, want := range []string{"ok    branch", "FAIL  fmt", "        pkg/chat/bubble.go", "FAIL  test", "        --- FAIL: TestBubble"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("Expected the report to contain %q, got:\n%s", want, report)
+		}
+	}
+	for _, command := range executor.Commands {
+		if strings.HasPrefix(command, "git push") {
+			t.Errorf("Expected nothing to be pushed, got %q", command)
+		}
+	}
+
+	// --skip-checks pushes anyway
+	executor.Commands = nil
+	if err := wm.PushStory(PushOptions{SkipChecks: true}); err != nil {
+		t.Fatalf("PushStory() unexpected error: %v", err)
+	}
+	assertCommands(t, executor, []string{"git rev-parse --abbrev-ref HEAD", "git push -u origin W-1-login"})
+}
+
+func TestRunChecksCommitsAndBranch(t *testing.T) {
+	executor := NewRecordingExecutor().
+		OnOutput("git rev-parse --abbrev-ref HEAD", "login\n").
+		OnOutput("git log --format=%H\x1f%B\x1e origin/main..HEAD",
+			logRecord("3333333cccc", "fixup! feat(chat): add bubble")+
+				logRecord("2222222bbbb", "Merge branch 'main' into login")+
+				logRecord("1111111aaaa", "added the bubble"))
+	wm := testManager(executor)
+
+	err := wm.RunChecks([]string{CheckBranch, CheckCommits})
+	var checksErr *ChecksError
+	if !errors.As(err, &checksErr) || len(checksErr.Results) != 2 {
+		t.Fatalf("RunChecks() error = %v, want both checks to fail", err)
+	}
+	if branch := checksErr.Results[0]; branch.Status != CheckFailed || !strings.Contains(branch.Output, "W-STORY_ID") {
+		t.Errorf("Unexpected branch result: %+v", branch)
+	}
+	commits := checksErr.Results[1].Output
+	if !strings.Contains(commits, "3333333 fixup! feat(chat): add bubble: fold it in") || !strings.Contains(commits, "1111111 added the bubble") {
+		t.Errorf("Expected the fixup and the unconventional commit to be reported, got:\n%s", commits)
+	}
+	if strings.Contains(commits, "Merge branch") {
+		t.Errorf("Expected merge commits to be ignored, got:\n%s", commits)
+	}
+}
+
+func TestRunChecksWithoutGoChanges(t *testing.T) {
+	executor := NewRecordingExecutor().OnOutput("git rev-parse --show-toplevel", "/src/chat\n")
+	wm := testManager(executor)
+
+	if err := wm.RunChecks([]string{CheckFmt, CheckVet, CheckTest}); err != nil {
+		t.Fatalf("RunChecks() unexpected error: %v", err)
+	}
+	assertCommands(t, executor, []string{"git rev-parse --show-toplevel", changedGoFilesCommand})
+}
+
+func TestGoPackages(t *testing.T) {
+	got := goPackages([]string{"pkg/a/a.go", "main.go", "pkg/a/a_test.go", "pkg/a/testdata/x.go", "vendor/b/b.go", "cmd/c/main.go"})
+	want := []string{".", "./cmd/c", "./pkg/a"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("goPackages() = %v, want %v", got, want)
+	}
+}
+
+func TestRunChecksPerModule(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"services/api", "tools"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+		if err := os.WriteFile(filepath.Join(root, dir, "go.mod"), []byte("module example.com/"+dir+"\n"), 0o644); err != nil {
+			t.Fatalf("Failed to write go.mod: %v", err)
+		}
+	}
+	executor := NewRecordingExecutor().
+		OnOutput("git rev-parse --show-toplevel", root+"\n").
+		OnOutput(changedGoFilesCommand, "main.go\nservices/api/handlers/user.go\nservices/api/main.go\ntools/gen.go\n").
+		OnFailure("go -C "+filepath.Join(root, "tools")+" vet .", 1, "gen.go:3: unreachable code")
+	wm := testManager(executor)
+
+	err := wm.RunChecks([]string{CheckVet})
+	var checksErr *ChecksError
+	if !errors.As(err, &checksErr) || !strings.Contains(checksErr.Results[0].Output, "unreachable code") {
+		t.Fatalf("RunChecks() error = %v, want the tools module to fail", err)
+	}
+	assertCommands(t, executor, []string{
+		"git rev-parse --show-toplevel",
+		changedGoFilesCommand,
+		"go -C " + root + " vet .",
+		"go -C " + filepath.Join(root, "services/api") + " vet . ./handlers",
+		"go -C " + filepath.Join(root, "tools") + " vet .",
+	})
+}
+
+func TestGoModules(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "api"), 0o755); err != nil {
+		t.Fatalf("Failed to create api: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "api", "go.mod"), []byte("module example.com/api\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write go.mod: %v", err)
+	}
+
+	got := goModules(root, []string{"main.go", "api/v1/user.go", "api/main.go", "web/app.go"})
+	if strings.Join(got["."], " ") != "main.go web/app.go" {
+		t.Errorf("Root files = %v, want main.go web/app.go", got["."])
+	}
+	if strings.Join(got["api"], " ") != "v1/user.go main.go" {
+		t.Errorf("api files = %v, want v1/user.go main.go", got["api"])
+	}
+}
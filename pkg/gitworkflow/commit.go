@@ -0,0 +1,227 @@
+package gitworkflow
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// maxHeaderLength is the longest commit header accepted by ValidateCommitHeader
+const maxHeaderLength = 100
+
+// breakingChangeToken is the footer token that marks a breaking change
+const breakingChangeToken = "BREAKING CHANGE"
+
+// refsToken is the footer token used for issue references
+const refsToken = "Refs"
+
+var (
+	headerPattern = regexp.MustCompile(`^([a-z][a-z0-9_-]*)(?:\(([^()\s]+)\))?(!)?: (\S.*)$`)
+	footerPattern = regexp.MustCompile(`^(BREAKING CHANGE|BREAKING-CHANGE|[A-Za-z][A-Za-z0-9-]*)(?:: | #)(.+)$`)
+)
+
+// CommitMessage is a commit message following the Conventional Commits specification
+type CommitMessage struct {
+	Type        string
+	Scope       string
+	Description string
+	Body        string
+	// Breaking marks the header with "!"
+	Breaking bool
+	// BreakingChange describes the breaking change in a BREAKING CHANGE footer; it implies Breaking
+	BreakingChange string
+	// Refs lists issue references rendered as a "Refs:" footer, e.g. W-123
+	Refs []string
+	// Footers holds any other footers verbatim, e.g. "Reviewed-by: Jane"
+	Footers []string
+}
+
+// Header returns the first line of the message, e.g. "feat(auth)!: drop v1 login"
+func (m CommitMessage) Header() string {
+	var header strings.Builder
+	header.WriteString(m.Type)
+	if m.Scope != "" {
+		header.WriteString("(" + m.Scope + ")")
+	}
+	if m.Breaking || m.BreakingChange != "" {
+		header.WriteString("!")
+	}
+	header.WriteString(": " + m.Description)
+	return header.String()
+}
+
+// String renders the full message: header, optional body and footers separated by blank lines
+func (m CommitMessage) String() string {
+	paragraphs := []string{m.Header()}
+	if body := strings.TrimSpace(m.Body); body != "" {
+		paragraphs = append(paragraphs, body)
+	}
+
+	var footers []string
+	if m.BreakingChange != "" {
+		footers = append(footers, fmt.Sprintf("%s: %s", breakingChangeToken, m.BreakingChange))
+	}
+	if len(m.Refs) > 0 {
+		footers = append(footers, fmt.Sprintf("%s: %s", refsToken, strings.Join(m.Refs, ", ")))
+	}
+	footers = append(footers, m.Footers...)
+	if len(footers) > 0 {
+		paragraphs = append(paragraphs, strings.Join(footers, "\n"))
+	}
+
+	return strings.Join(paragraphs, "\n\n")
+}
+
+// IsBreaking reports whether the commit introduces a breaking change
+func (m CommitMessage) IsBreaking() bool {
+	return m.Breaking || m.BreakingChange != ""
+}
+
+// ParseCommitHeader parses a Conventional Commits header line
+func ParseCommitHeader(header string) (CommitMessage, error) {
+	matches := headerPattern.FindStringSubmatch(header)
+	if matches == nil {
+		return CommitMessage{}, fmt.Errorf("malformed commit header %q: expected \"type(scope): description\"", header)
+	}
+	return CommitMessage{
+		Type:        matches[1],
+		Scope:       matches[2],
+		Breaking:    matches[3] == "!",
+		Description: matches[4],
+	}, nil
+}
+
+// ParseCommitMessage parses a full Conventional Commits message including body and footers
+func ParseCommitMessage(message string) (CommitMessage, error) {
+	message = strings.TrimSpace(strings.ReplaceAll(message, "\r\n", "\n"))
+	lines := strings.SplitN(message, "\n", 2)
+
+	parsed, err := ParseCommitHeader(strings.TrimSpace(lines[0]))
+	if err != nil {
+		return CommitMessage{}, err
+	}
+	if len(lines) == 1 {
+		return parsed, nil
+	}
+
+	// The footer block is the last paragraph if every line in it is a footer
+	paragraphs := strings.Split(strings.TrimSpace(lines[1]), "\n\n")
+	last := paragraphs[len(paragraphs)-1]
+	if isFooterBlock(last) {
+		paragraphs = paragraphs[:len(paragraphs)-1]
+		for _, line := range strings.Split(last, "\n") {
+			token, value := splitFooter(line)
+			switch token {
+			case breakingChangeToken, "BREAKING-CHANGE":
+				parsed.BreakingChange = value
+			case refsToken:
+				for _, ref := range strings.Split(value, ",") {
+					parsed.Refs = append(parsed.Refs, strings.TrimSpace(ref))
+				}
+			default:
+				parsed.Footers = append(parsed.Footers, line)
+			}
+		}
+	}
+	parsed.Body = strings.TrimSpace(strings.Join(paragraphs, "\n\n"))
+
+	return parsed, nil
+}
+
+// ValidateCommitHeader rejects headers that are malformed or use a type or
+// scope the configuration does not allow
+func ValidateCommitHeader(header string, config CommitConfig) error {
+	if len(header) > maxHeaderLength {
+		return fmt.Errorf("commit header is %d characters; the limit is %d", len(header), maxHeaderLength)
+	}
+
+	parsed, err := ParseCommitHeader(header)
+	if err != nil {
+		return err
+	}
+
+	if len(config.Types) > 0 && !contains(config.Types, parsed.Type) {
+		return fmt.Errorf("commit type %q is not allowed; expected one of: %s", parsed.Type, strings.Join(config.Types, ", "))
+	}
+	if parsed.Scope != "" && len(config.Scopes) > 0 && !contains(config.Scopes, parsed.Scope) {
+		return fmt.Errorf("scope %q is not allowed; expected one of: %s", parsed.Scope, strings.Join(config.Scopes, ", "))
+	}
+	if strings.HasSuffix(parsed.Description, ".") {
+		return fmt.Errorf("commit description must not end with a period")
+	}
+
+	return nil
+}
+
+// isFooterBlock reports whether every line of paragraph is a git trailer style footer
+func isFooterBlock(paragraph string) bool {
+	for _, line := range strings.Split(paragraph, "\n") {
+		if !footerPattern.MatchString(line) {
+			return false
+		}
+	}
+	return true
+}
+
+// splitFooter splits a footer line into its token and value
+func splitFooter(line string) (string, string) {
+	matches := footerPattern.FindStringSubmatch(line)
+	if matches == nil {
+		return "", line
+	}
+	return matches[1], strings.TrimSpace(matches[2])
+}
+
+// StoryID returns the story ID encoded in branchName, e.g. "W-123" for
+// "W-123-add-login", and false if the branch is not a story branch
+func (wm *WorkflowManager) StoryID(branchName string) (string, bool) {
+	prefix := wm.branchIDPrefix()
+	if !strings.HasPrefix(branchName, prefix) {
+		return "", false
+	}
+
+	id := branchName[len(prefix):]
+	if end := strings.IndexAny(id, "-_/."); end >= 0 {
+		id = id[:end]
+	}
+	if id == "" {
+		return "", false
+	}
+	return wm.config.Branch.Prefix + id, true
+}
+
+// Commit validates message and creates a commit from the already staged
+// changes. The final message, including any story reference, is returned.
+func (wm *WorkflowManager) Commit(message CommitMessage) (CommitMessage, error) {
+	message, err := wm.prepareCommitMessage(message)
+	if err != nil {
+		return message, err
+	}
+	return message, wm.commit(message)
+}
+
+// prepareCommitMessage fills in the default type, validates the header and
+// adds the story ID of the current branch to the message's Refs
+func (wm *WorkflowManager) prepareCommitMessage(message CommitMessage) (CommitMessage, error) {
+	if message.Type == "" {
+		message.Type = wm.config.Commit.DefaultType
+	}
+	if err := ValidateCommitHeader(message.Header(), wm.config.Commit); err != nil {
+		return message, err
+	}
+
+	if branch, err := wm.GetCurrentBranch(); err == nil {
+		if storyID, ok := wm.StoryID(branch); ok && !contains(message.Refs, storyID) {
+			message.Refs = append(message.Refs, storyID)
+		}
+	}
+	return message, nil
+}
+
+// commit records the staged changes with message
+func (wm *WorkflowManager) commit(message CommitMessage) error {
+	if _, err := wm.git("commit", "-m", message.String()); err != nil {
+		return fmt.Errorf("failed to create commit: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package gitworkflow
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCommitMessageString(t *testing.T) {
+	tests := []struct {
+		name    string
+		message CommitMessage
+		want    string
+	}{
+		{
+			name:    "header only",
+			message: CommitMessage{Type: "fix", Scope: "auth", Description: "handle expired tokens"},
+			want:    "fix(auth): handle expired tokens",
+		},
+		{
+			name:    "no scope",
+			message: CommitMessage{Type: "chore", Description: "bump dependencies"},
+			want:    "chore: bump dependencies",
+		},
+		{
+			name: "body, breaking change and refs",
+			message: CommitMessage{
+				Type:           "feat",
+				Scope:          "api",
+				Description:    "drop v1 endpoints",
+				Body:           "Clients must migrate to /v2.",
+				BreakingChange: "the /v1 routes are gone",
+				Refs:           []string{"W-42"},
+			},
+			want: "feat(api)!: drop v1 endpoints\n\nClients must migrate to /v2.\n\nBREAKING CHANGE: the /v1 routes are gone\nRefs: W-42",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.message.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCommitMessageRoundTrip(t *testing.T) {
+	original := CommitMessage{
+		Type:           "refactor",
+		Scope:          "sync",
+		Description:    "split divergence handling",
+		Body:           "First paragraph.\n\nSecond paragraph.",
+		BreakingChange: "SyncWithRemote returns a result",
+		Refs:           []string{"W-7", "W-8"},
+		Footers:        []string{"Reviewed-by: Sam"},
+	}
+
+	parsed, err := ParseCommitMessage(original.String())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if parsed.String() != original.String() {
+		t.Errorf("Round trip mismatch:\n got: %q\nwant: %q", parsed.String(), original.String())
+	}
+	if !parsed.IsBreaking() {
+		t.Error("Expected parsed message to be breaking")
+	}
+}
+
+func TestParseCommitMessageBodyWithoutFooters(t *testing.T) {
+	parsed, err := ParseCommitMessage("docs: explain sync\n\nThis is prose, not a footer.")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if parsed.Body != "This is prose, not a footer." || len(parsed.Footers) != 0 {
+		t.Errorf("Unexpected parse result: %+v", parsed)
+	}
+}
+
+func TestValidateCommitHeader(t *testing.T) {
+	config := CommitConfig{Types: []string{"feat", "fix"}, Scopes: []string{"auth"}}
+
+	tests := []struct {
+		name    string
+		header  string
+		wantErr string
+	}{
+		{name: "valid", header: "feat(auth): add login"},
+		{name: "valid without scope", header: "fix: handle nil user"},
+		{name: "valid breaking", header: "feat(auth)!: require MFA"},
+		{name: "missing space", header: "feat(auth):add login", wantErr: "malformed"},
+		{name: "capitalized type", header: "Feat(auth): add login", wantErr: "malformed"},
+		{name: "empty scope", header: "feat(): add login", wantErr: "malformed"},
+		{name: "empty description", header: "feat(auth): ", wantErr: "malformed"},
+		{name: "unknown type", header: "chore: tidy", wantErr: "commit type"},
+		{name: "unknown scope", header: "feat(billing): add invoices", wantErr: "scope"},
+		{name: "trailing period", header: "fix(auth): handle nil user.", wantErr: "period"},
+		{name: "too long", header: "fix: " + strings.Repeat("x", maxHeaderLength), wantErr: "limit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCommitHeader(tt.header, config)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("ValidateCommitHeader() unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("ValidateCommitHeader() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStoryID(t *testing.T) {
+	wm := NewWorkflowManagerWithExecutor(NewRecordingExecutor())
+
+	tests := []struct {
+		branch string
+		want   string
+		ok     bool
+	}{
+		{branch: "W-123", want: "W-123", ok: true},
+		{branch: "W-123-add-login", want: "W-123", ok: true},
+		{branch: "W-", ok: false},
+		{branch: "main", ok: false},
+	}
+
+	for _, tt := range tests {
+		got, ok := wm.StoryID(tt.branch)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("StoryID(%q) = %q, %v; want %q, %v", tt.branch, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCommitAddsStoryRef(t *testing.T) {
+	executor := NewRecordingExecutor().
+		OnOutput("git rev-parse --abbrev-ref HEAD", "W-55-search\n")
+	wm := NewWorkflowManagerWithExecutor(executor)
+
+	message, err := wm.Commit(CommitMessage{Type: "fix", Scope: "search", Description: "escape queries", Body: "Quotes broke the parser."})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := "fix(search): escape queries\n\nQuotes broke the parser.\n\nRefs: W-55"
+	if message.String() != want {
+		t.Errorf("Commit() message = %q, want %q", message.String(), want)
+	}
+	assertCommands(t, executor, []string{
+		"git rev-parse --abbrev-ref HEAD",
+		"git commit -m " + want,
+	})
+}
+
+func TestCommitStoryRejectsMalformedHeader(t *testing.T) {
+	executor := NewRecordingExecutor()
+	wm := NewWorkflowManagerWithExecutor(executor)
+
+	if _, err := wm.CommitStory(CommitMessage{Type: "wip", Description: "stuff"}, StageOptions{}); err == nil {
+		t.Fatal("Expected disallowed type to be rejected")
+	}
+	if len(executor.Commands) != 0 {
+		t.Errorf("Expected nothing staged or committed, got %v", executor.Commands)
+	}
+}
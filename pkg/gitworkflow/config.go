@@ -0,0 +1,328 @@
+package gitworkflow
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultRemote is the remote used when none is configured
+const DefaultRemote = "origin"
+
+// ConfigFileName is the name of the per-repository workflow config file
+const ConfigFileName = ".vamos.yaml"
+
+// Template placeholders understood in BranchConfig.Template
+const (
+	placeholderPrefix      = "{prefix}"
+	placeholderID          = "{id}"
+	placeholderDescription = "{description}"
+)
+
+// DefaultProtectedBranches are the branch patterns prune never deletes when none are configured
+var DefaultProtectedBranches = []string{"main", "master", "develop", "release/*"}
+
+// DefaultCommitTypes are the Conventional Commits types allowed when none are configured
+var DefaultCommitTypes = []string{"feat", "fix", "docs", "style", "refactor", "perf", "test", "build", "ci", "chore", "revert"}
+
+var identifierPattern = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// Config holds the repository conventions a WorkflowManager follows
+type Config struct {
+	// Remote is the name of the remote to fetch from and push to
+	Remote string `yaml:"remote"`
+	// BaseBranch is the branch stories start from and sync against.
+	// When empty it is detected from <Remote>/HEAD, falling back to main or master.
+	BaseBranch  string            `yaml:"base_branch,omitempty"`
+	Branch      BranchConfig      `yaml:"branch"`
+	Commit      CommitConfig      `yaml:"commit"`
+	Changelog   ChangelogConfig   `yaml:"changelog,omitempty"`
+	Sync        SyncConfig        `yaml:"sync"`
+	GitHub      GitHubConfig      `yaml:"github,omitempty"`
+	PullRequest PullRequestConfig `yaml:"pull_request,omitempty"`
+	Prune       PruneConfig       `yaml:"prune"`
+	Push        PushConfig        `yaml:"push,omitempty"`
+}
+
+// BranchConfig holds the story branch naming conventions
+type BranchConfig struct {
+	// Prefix is prepended to every story ID, e.g. "W-"
+	Prefix string `yaml:"prefix"`
+	// Template builds story branch names from {prefix}, {id} and {description}.
+	// Separators next to an empty {description} are dropped.
+	Template string `yaml:"template"`
+	// IDPattern is the regular expression story IDs must match in full
+	IDPattern string `yaml:"id_pattern"`
+	// MaxLength limits story branch names; longer descriptions are cut at a word boundary
+	MaxLength int `yaml:"max_length"`
+}
+
+// CommitConfig holds the commit message conventions
+type CommitConfig struct {
+	// Types lists the allowed Conventional Commits types
+	Types []string `yaml:"types"`
+	// DefaultType is used when no type is given explicitly
+	DefaultType string `yaml:"default_type"`
+	// Scopes lists the allowed scopes; empty allows any scope
+	Scopes []string `yaml:"scopes,omitempty"`
+	// Deny lists file patterns that must never be committed, e.g. ".env" or "*.pem"
+	Deny []string `yaml:"deny"`
+}
+
+// ChangelogConfig holds the changelog generation settings
+type ChangelogConfig struct {
+	// StoryURL links story IDs in the changelog; {id} is replaced with the story ID,
+	// e.g. "https://tracker.example.com/browse/{id}"
+	StoryURL string `yaml:"story_url,omitempty"`
+}
+
+// SyncConfig holds how sync brings in remote changes
+type SyncConfig struct {
+	// Strategy is how a branch that has diverged from its remote is reconciled: rebase or merge
+	Strategy string `yaml:"strategy"`
+}
+
+// GitHubConfig holds how the GitHub REST API is reached
+type GitHubConfig struct {
+	// APIURL is the API root, e.g. "https://github.example.com/api/v3" for GitHub Enterprise.
+	// When empty the public API is used.
+	APIURL string `yaml:"api_url,omitempty"`
+	// Token authenticates API requests when neither GITHUB_TOKEN nor GH_TOKEN is set.
+	// Prefer the environment over committing a token.
+	Token string `yaml:"token,omitempty"`
+}
+
+// PullRequestConfig holds the defaults for pull requests opened by story-pr
+type PullRequestConfig struct {
+	// BaseRemote is the remote of the repository pull requests are opened in,
+	// e.g. "upstream" when Remote is a fork. When empty it is Remote.
+	BaseRemote string   `yaml:"base_remote,omitempty"`
+	Draft      bool     `yaml:"draft,omitempty"`
+	Reviewers  []string `yaml:"reviewers,omitempty"`
+	Labels     []string `yaml:"labels,omitempty"`
+	// BodyTemplate is a text/template for the pull request body; see PullRequestDetails
+	// for the fields it can use. When empty DefaultPullRequestBody is used.
+	BodyTemplate string `yaml:"body_template,omitempty"`
+}
+
+// PruneConfig holds which branches prune may delete
+type PruneConfig struct {
+	// Protected lists branch name patterns prune never deletes, e.g. "release/*".
+	// The base branch and the current branch are always protected.
+	Protected []string `yaml:"protected"`
+	// StaleDays also offers branches without commits for this many days; 0 only offers merged branches
+	StaleDays int `yaml:"stale_days,omitempty"`
+}
+
+// PushConfig holds what story-push does before pushing
+type PushConfig struct {
+	// Checks lists the checks run before pushing, in order: the built-in
+	// branch, commits, fmt, vet and test, or any shell command. Empty runs none.
+	Checks []string `yaml:"checks,omitempty"`
+}
+
+// DefaultConfig returns the configuration used when nothing is overridden
+func DefaultConfig() Config {
+	return Config{
+		Remote: DefaultRemote,
+		Branch: BranchConfig{
+			Prefix:    "W-",
+			Template:  "{prefix}{id}-{description}",
+			IDPattern: DefaultStoryIDPattern,
+			MaxLength: DefaultBranchMaxLength,
+		},
+		Commit: CommitConfig{
+			Types:       append([]string(nil), DefaultCommitTypes...),
+			DefaultType: "feat",
+			Deny:        append([]string(nil), DefaultDenyPatterns...),
+		},
+		Sync: SyncConfig{
+			Strategy: SyncRebase,
+		},
+		Prune: PruneConfig{
+			Protected: append([]string(nil), DefaultProtectedBranches...),
+		},
+	}
+}
+
+// withDefaults fills any unset fields with their default values
+func (c Config) withDefaults() Config {
+	defaults := DefaultConfig()
+	if c.Remote == "" {
+		c.Remote = defaults.Remote
+	}
+	if c.Branch.Prefix == "" {
+		c.Branch.Prefix = defaults.Branch.Prefix
+	}
+	if c.Branch.Template == "" {
+		c.Branch.Template = defaults.Branch.Template
+	}
+	if c.Branch.IDPattern == "" {
+		c.Branch.IDPattern = defaults.Branch.IDPattern
+	}
+	if c.Branch.MaxLength == 0 {
+		c.Branch.MaxLength = defaults.Branch.MaxLength
+	}
+	if len(c.Commit.Types) == 0 {
+		c.Commit.Types = defaults.Commit.Types
+	}
+	if c.Commit.DefaultType == "" {
+		c.Commit.DefaultType = defaults.Commit.DefaultType
+	}
+	// An explicitly empty deny list is respected
+	if c.Commit.Deny == nil {
+		c.Commit.Deny = defaults.Commit.Deny
+	}
+	if c.Sync.Strategy == "" {
+		c.Sync.Strategy = defaults.Sync.Strategy
+	}
+	// As with the deny list, an explicitly empty protected list is respected
+	if c.Prune.Protected == nil {
+		c.Prune.Protected = defaults.Prune.Protected
+	}
+	return c
+}
+
+// Validate checks that the configuration is internally consistent
+func (c Config) Validate() error {
+	var problems []string
+
+	if c.Remote == "" || strings.ContainsAny(c.Remote, " \t/") {
+		problems = append(problems, fmt.Sprintf("remote %q must be a non-empty name without spaces or slashes", c.Remote))
+	}
+	if strings.ContainsAny(c.BaseBranch, " \t~^:?*[\\") {
+		problems = append(problems, fmt.Sprintf("base_branch %q is not a valid branch name", c.BaseBranch))
+	}
+	if c.Branch.Prefix == "" || strings.ContainsAny(c.Branch.Prefix, " \t~^:?*[\\") {
+		problems = append(problems, fmt.Sprintf("branch.prefix %q must be non-empty and valid in a branch name", c.Branch.Prefix))
+	}
+	if strings.Count(c.Branch.Template, placeholderID) != 1 {
+		problems = append(problems, fmt.Sprintf("branch.template %q must contain %s exactly once", c.Branch.Template, placeholderID))
+	}
+	if _, err := regexp.Compile(c.Branch.IDPattern); err != nil {
+		problems = append(problems, fmt.Sprintf("branch.id_pattern %q is not a valid regular expression", c.Branch.IDPattern))
+	}
+	if c.Branch.MaxLength < 0 {
+		problems = append(problems, fmt.Sprintf("branch.max_length %d must not be negative", c.Branch.MaxLength))
+	}
+	for _, commitType := range c.Commit.Types {
+		if !identifierPattern.MatchString(commitType) {
+			problems = append(problems, fmt.Sprintf("commit.types entry %q must be a lowercase word", commitType))
+		}
+	}
+	if !contains(c.Commit.Types, c.Commit.DefaultType) {
+		problems = append(problems, fmt.Sprintf("commit.default_type %q must be one of commit.types", c.Commit.DefaultType))
+	}
+	for _, pattern := range c.Commit.Deny {
+		if strings.TrimSpace(pattern) == "" {
+			problems = append(problems, "commit.deny entries must not be empty")
+		}
+	}
+	if c.Changelog.StoryURL != "" && !strings.Contains(c.Changelog.StoryURL, placeholderID) {
+		problems = append(problems, fmt.Sprintf("changelog.story_url %q must contain %s", c.Changelog.StoryURL, placeholderID))
+	}
+	for _, scope := range c.Commit.Scopes {
+		if !identifierPattern.MatchString(scope) {
+			problems = append(problems, fmt.Sprintf("commit.scopes entry %q must be a lowercase word", scope))
+		}
+	}
+	if c.Sync.Strategy != SyncRebase && c.Sync.Strategy != SyncMerge {
+		problems = append(problems, fmt.Sprintf("sync.strategy %q must be %s or %s", c.Sync.Strategy, SyncRebase, SyncMerge))
+	}
+	if c.GitHub.APIURL != "" && !strings.HasPrefix(c.GitHub.APIURL, "https://") && !strings.HasPrefix(c.GitHub.APIURL, "http://") {
+		problems = append(problems, fmt.Sprintf("github.api_url %q must be an http or https URL", c.GitHub.APIURL))
+	}
+	if c.PullRequest.BodyTemplate != "" {
+		if _, err := parseBodyTemplate(c.PullRequest.BodyTemplate); err != nil {
+			problems = append(problems, fmt.Sprintf("pull_request.body_template is not a valid template: %v", err))
+		}
+	}
+	for _, pattern := range c.Prune.Protected {
+		if strings.TrimSpace(pattern) == "" {
+			problems = append(problems, "prune.protected entries must not be empty")
+		}
+	}
+	if c.Prune.StaleDays < 0 {
+		problems = append(problems, fmt.Sprintf("prune.stale_days %d must not be negative", c.Prune.StaleDays))
+	}
+	for _, check := range c.Push.Checks {
+		if strings.TrimSpace(check) == "" {
+			problems = append(problems, "push.checks entries must not be empty")
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid workflow config:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+// LoadConfigFile reads a workflow config file, filling unset fields with defaults
+func LoadConfigFile(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return Config{}, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	config = config.withDefaults()
+	if err := config.Validate(); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, nil
+}
+
+// LoadRepoConfig loads the ConfigFileName at the root of the repository
+// executor runs in. It returns the default config and an empty path when the
+// repository has no config file.
+func LoadRepoConfig(executor CommandExecutorInterface) (Config, string, error) {
+	result, err := executor.Execute("git", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return Config{}, "", fmt.Errorf("failed to find repository root: %w", newGitError(err))
+	}
+
+	path := filepath.Join(strings.TrimSpace(result.Stdout), ConfigFileName)
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return DefaultConfig(), "", nil
+	}
+
+	config, err := LoadConfigFile(path)
+	if err != nil {
+		return Config{}, "", err
+	}
+	return config, path, nil
+}
+
+// String renders the configuration as YAML, hiding any token
+func (c Config) String() string {
+	if c.GitHub.Token != "" {
+		c.GitHub.Token = "<redacted>"
+	}
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c); err != nil {
+		return fmt.Sprintf("error rendering config: %v", err)
+	}
+	return buf.String()
+}
+
+// contains reports whether values includes value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package gitworkflow
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, dir, contents string) string {
+	t.Helper()
+	path := filepath.Join(dir, ConfigFileName)
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	path := writeConfigFile(t, t.TempDir(), `
+remote: upstream
+base_branch: develop
+branch:
+  prefix: STORY-
+commit:
+  types: [feat, fix, chore]
+  default_type: fix
+  scopes: [auth, chat]
+`)
+
+	config, err := LoadConfigFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if config.Remote != "upstream" || config.BaseBranch != "develop" {
+		t.Errorf("Unexpected remote/base branch: %s/%s", config.Remote, config.BaseBranch)
+	}
+	if config.Branch.Prefix != "STORY-" {
+		t.Errorf("Expected prefix STORY-, got %q", config.Branch.Prefix)
+	}
+	if config.Branch.Template != DefaultConfig().Branch.Template {
+		t.Errorf("Expected default template to fill in, got %q", config.Branch.Template)
+	}
+	if config.Commit.DefaultType != "fix" || len(config.Commit.Types) != 3 || len(config.Commit.Scopes) != 2 {
+		t.Errorf("Unexpected commit config: %+v", config.Commit)
+	}
+}
+
+func TestLoadConfigFileValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		wantErr  string
+	}{
+		{
+			name:     "malformed yaml",
+			contents: "remote: [",
+			wantErr:  "failed to parse",
+		},
+		{
+			name:     "default type not allowed",
+			contents: "commit:\n  types: [fix]\n  default_type: feat\n",
+			wantErr:  "commit.default_type",
+		},
+		{
+			name:     "template without id",
+			contents: "branch:\n  template: \"{prefix}{description}\"\n",
+			wantErr:  "branch.template",
+		},
+		{
+			name:     "invalid scope",
+			contents: "commit:\n  scopes: [\"Has Space\"]\n",
+			wantErr:  "commit.scopes",
+		},
+		{
+			name:     "unknown sync strategy",
+			contents: "sync:\n  strategy: squash\n",
+			wantErr:  "sync.strategy",
+		},
+		{
+			name:     "broken pull request template",
+			contents: "pull_request:\n  body_template: \"{{range .Commits}}\"\n",
+			wantErr:  "pull_request.body_template",
+		},
+		{
+			name:     "negative stale days",
+			contents: "prune:\n  stale_days: -30\n",
+			wantErr:  "prune.stale_days",
+		},
+		{
+			name:     "invalid story ID pattern",
+			contents: "branch:\n  id_pattern: \"[0-9\"\n",
+			wantErr:  "branch.id_pattern",
+		},
+		{
+			name:     "empty push check",
+			contents: "push:\n  checks: [vet, \"\"]\n",
+			wantErr:  "push.checks",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfigFile(t, t.TempDir(), tt.contents)
+			_, err := LoadConfigFile(path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadConfigFile() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadRepoConfig(t *testing.T) {
+	dir := t.TempDir()
+	executor := NewRecordingExecutor().OnOutput("git rev-parse --show-toplevel", dir+"\n")
+
+	// No config file falls back to defaults
+	config, path, err := LoadRepoConfig(executor)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if path != "" || config.Remote != DefaultRemote {
+		t.Errorf("Expected defaults without a config file, got %q %+v", path, config)
+	}
+
+	writeConfigFile(t, dir, "remote: fork\n")
+	config, path, err = LoadRepoConfig(executor)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if path != filepath.Join(dir, ConfigFileName) || config.Remote != "fork" {
+		t.Errorf("Expected config loaded from repo root, got %q %+v", path, config)
+	}
+}
+
+func TestConfigString(t *testing.T) {
+	rendered := DefaultConfig().String()
+	for _, want := range []string{"remote: origin", "prefix: W-", "default_type: feat", "strategy: rebase"} {
+		if !strings.Contains(rendered, want) {
+			t.Errorf("Expected rendered config to contain %q:\n%s", want, rendered)
+		}
+	}
+
+	config := DefaultConfig()
+	config.GitHub.Token = "ghp_secret"
+	if rendered := config.String(); strings.Contains(rendered, "ghp_secret") || !strings.Contains(rendered, "token: <redacted>") {
+		t.Errorf("Expected the token to be redacted:\n%s", rendered)
+	}
+}
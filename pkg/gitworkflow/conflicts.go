@@ -0,0 +1,394 @@
+package gitworkflow
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// conflictMarker starts the "ours" side of every conflict hunk git writes into a file
+const conflictMarker = "<<<<<<<"
+
+// GitOperation is a multi-step git command that can stop part way for conflicts
+type GitOperation string
+
+// Operations that can be in progress in a repository
+const (
+	OperationNone       GitOperation = ""
+	OperationRebase     GitOperation = "rebase"
+	OperationMerge      GitOperation = "merge"
+	OperationCherryPick GitOperation = "cherry-pick"
+	OperationRevert     GitOperation = "revert"
+)
+
+// operationMarkers lists the files in the git directory that mark each operation as in progress
+var operationMarkers = []struct {
+	path      string
+	operation GitOperation
+}{
+	{"rebase-merge", OperationRebase},
+	{"rebase-apply", OperationRebase},
+	{"MERGE_HEAD", OperationMerge},
+	{"CHERRY_PICK_HEAD", OperationCherryPick},
+	{"REVERT_HEAD", OperationRevert},
+}
+
+// ConflictChoice is how a single conflicted file is resolved
+type ConflictChoice string
+
+// Ways to resolve a conflicted file. Ours and theirs follow git's meaning: during
+// a rebase "ours" is the branch being rebased onto and "theirs" is your commit.
+const (
+	ChoiceOurs   ConflictChoice = "ours"
+	ChoiceTheirs ConflictChoice = "theirs"
+	// ChoiceEdited stages a file whose conflict markers were removed by hand
+	ChoiceEdited ConflictChoice = "edited"
+)
+
+// ConflictedFile is a file git could not merge automatically
+type ConflictedFile struct {
+	FileChange
+	// Hunks counts the conflict markers left in the working tree copy of the file
+	Hunks int
+}
+
+// Kind describes the conflict the way git status does, e.g. "both modified"
+func (f ConflictedFile) Kind() string {
+	switch string([]byte{f.Index, f.Worktree}) {
+	case "DD":
+		return "both deleted"
+	case "AU":
+		return "added by us"
+	case "UD":
+		return "deleted by them"
+	case "UA":
+		return "added by them"
+	case "DU":
+		return "deleted by us"
+	case "AA":
+		return "both added"
+	default:
+		return "both modified"
+	}
+}
+
+// MarshalJSON writes the path, the kind of conflict and the number of hunks
+func (f ConflictedFile) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Path  string `json:"path"`
+		Kind  string `json:"kind"`
+		Hunks int    `json:"hunks"`
+	}{f.Path, f.Kind(), f.Hunks})
+}
+
+// isUnmerged reports whether a porcelain status describes a merge conflict
+func isUnmerged(change FileChange) bool {
+	return change.Index == 'U' || change.Worktree == 'U' ||
+		(change.Index == 'A' && change.Worktree == 'A') ||
+		(change.Index == 'D' && change.Worktree == 'D')
+}
+
+// ConflictState describes an operation that stopped part way and what is left to resolve
+type ConflictState struct {
+	Operation GitOperation     `json:"operation"`
+	Files     []ConflictedFile `json:"files"`
+}
+
+// Describe explains the state of the repository and the commands that move it forward
+func (s *ConflictState) Describe() string {
+	var state strings.Builder
+	if s.Operation == OperationNone {
+		state.WriteString("No rebase, merge, cherry-pick or revert is in progress.\n")
+		return state.String()
+	}
+
+	fmt.Fprintf(&state, "A %s is in progress.\n", s.Operation)
+	if len(s.Files) == 0 {
+		state.WriteString("All conflicts are resolved.\n\n")
+		state.WriteString("  vamosGitWF resolve --continue   finish the " + string(s.Operation) + "\n")
+		state.WriteString("  vamosGitWF resolve --abort      give up and restore the branch\n")
+		return state.String()
+	}
+
+	fmt.Fprintf(&state, "%d file(s) have conflicts:\n", len(s.Files))
+	for _, file := range s.Files {
+		fmt.Fprintf(&state, "  %-16s %s", file.Kind(), file.Path)
+		if file.Hunks > 0 {
+			fmt.Fprintf(&state, " (%d conflict hunk(s))", file.Hunks)
+		}
+		state.WriteString("\n")
+	}
+	if s.Operation == OperationRebase {
+		state.WriteString("\nDuring a rebase \"ours\" is the branch being rebased onto and \"theirs\" is your commit.\n")
+	}
+	state.WriteString("\nResolve each file, then continue:\n")
+	state.WriteString("  vamosGitWF resolve --ours FILE     keep our side\n")
+	state.WriteString("  vamosGitWF resolve --theirs FILE   keep their side\n")
+	state.WriteString("  vamosGitWF resolve --mark FILE     stage a file you fixed by hand\n")
+	state.WriteString("  vamosGitWF resolve --interactive   choose for each file\n")
+	state.WriteString("  vamosGitWF resolve --continue      continue once every file is resolved\n")
+	state.WriteString("  vamosGitWF resolve --abort         give up and restore the branch\n")
+	return state.String()
+}
+
+// ConflictError is returned when a rebase, merge or continue stops because of conflicts.
+// The repository is left mid-operation; State describes what is left to do.
+type ConflictError struct {
+	State *ConflictState
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s stopped with conflicts in %d file(s)", e.State.Operation, len(e.State.Files))
+}
+
+// Is makes errors.Is(err, ErrConflict) match a ConflictError
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// Hint suggests how to get past the conflicts State describes
+func (e *ConflictError) Hint() string {
+	return "Resolve the conflicted files with 'vamosGitWF resolve', then run 'vamosGitWF resolve --continue', or 'vamosGitWF resolve --abort' to give up."
+}
+
+// InProgressOperation returns the rebase, merge, cherry-pick or revert that is
+// waiting to be continued or aborted, or OperationNone
+func (wm *WorkflowManager) InProgressOperation() (GitOperation, error) {
+	gitDir, err := wm.gitOutput("rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return OperationNone, fmt.Errorf("failed to find git directory: %w", err)
+	}
+	for _, marker := range operationMarkers {
+		if _, err := os.Stat(filepath.Join(gitDir, marker.path)); err == nil {
+			return marker.operation, nil
+		}
+	}
+	return OperationNone, nil
+}
+
+// ConflictState reports the operation in progress and the files that still have conflicts
+func (wm *WorkflowManager) ConflictState() (*ConflictState, error) {
+	operation, err := wm.InProgressOperation()
+	if err != nil {
+		return nil, err
+	}
+	files, err := wm.ConflictedFiles()
+	if err != nil {
+		return nil, err
+	}
+	return &ConflictState{Operation: operation, Files: files}, nil
+}
+
+// ConflictedFiles lists the unmerged files with the number of conflict hunks left in each
+func (wm *WorkflowManager) ConflictedFiles() ([]ConflictedFile, error) {
+	changes, err := wm.ChangedFiles()
+	if err != nil {
+		return nil, err
+	}
+	unmerged := filterChanges(changes, isUnmerged)
+	if len(unmerged) == 0 {
+		return nil, nil
+	}
+
+	root, err := wm.gitOutput("rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("failed to find repository root: %w", err)
+	}
+
+	files := make([]ConflictedFile, 0, len(unmerged))
+	for _, change := range unmerged {
+		file := ConflictedFile{FileChange: change}
+		// Files deleted on one side have no markers to count
+		if content, err := os.ReadFile(filepath.Join(root, change.Path)); err == nil {
+			file.Hunks = countConflictHunks(string(content))
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// ResolveFile resolves one conflicted file with choice and stages the result
+func (wm *WorkflowManager) ResolveFile(path string, choice ConflictChoice) error {
+	files, err := wm.ConflictedFiles()
+	if err != nil {
+		return err
+	}
+	var file *ConflictedFile
+	for i := range files {
+		if files[i].Path == path {
+			file = &files[i]
+		}
+	}
+	if file == nil {
+		return fmt.Errorf("%s has no conflicts to resolve", path)
+	}
+	return wm.resolveFile(*file, choice)
+}
+
+// resolveFile applies choice to a conflicted file
+func (wm *WorkflowManager) resolveFile(file ConflictedFile, choice ConflictChoice) error {
+	spec := ":/" + file.Path
+
+	// Keeping the side that deleted the file means deleting it
+	var deleted bool
+	switch choice {
+	case ChoiceOurs:
+		deleted = file.Index == 'D'
+	case ChoiceTheirs:
+		deleted = file.Worktree == 'D'
+	case ChoiceEdited:
+		if file.Hunks > 0 {
+			return fmt.Errorf("%s still has %d conflict hunk(s)", file.Path, file.Hunks)
+		}
+	default:
+		return fmt.Errorf("unknown conflict choice %q: expected ours, theirs or edited", choice)
+	}
+
+	if deleted {
+		if _, err := wm.git("rm", "--quiet", "--", spec); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", file.Path, err)
+		}
+		return nil
+	}
+
+	if choice != ChoiceEdited {
+		if _, err := wm.git("checkout", "--"+string(choice), "--", spec); err != nil {
+			return fmt.Errorf("failed to take %s side of %s: %w", choice, file.Path, err)
+		}
+	}
+	if _, err := wm.git("add", "--", spec); err != nil {
+		return fmt.Errorf("failed to stage %s: %w", file.Path, err)
+	}
+	return nil
+}
+
+// ResolveAll resolves every conflicted file with choice
+func (wm *WorkflowManager) ResolveAll(choice ConflictChoice) error {
+	files, err := wm.ConflictedFiles()
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("there are no conflicts to resolve")
+	}
+	for _, file := range files {
+		if err := wm.resolveFile(file, choice); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResolveInteractive asks for each conflicted file whether to keep ours, theirs,
+// stage a hand edited copy or skip it
+func (wm *WorkflowManager) ResolveInteractive(in io.Reader, out io.Writer) error {
+	files, err := wm.ConflictedFiles()
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("there are no conflicts to resolve")
+	}
+
+	reader := bufio.NewReader(in)
+	for _, file := range files {
+		fmt.Fprintf(out, "\n%s: %s", file.Path, file.Kind())
+		if file.Hunks > 0 {
+			fmt.Fprintf(out, ", %d conflict hunk(s)", file.Hunks)
+		}
+		fmt.Fprintln(out)
+
+		for {
+			fmt.Fprintf(out, "Resolve %s [o,t,e,s,q,?]? ", file.Path)
+			answer, err := reader.ReadString('\n')
+			if err != nil && answer == "" {
+				return fmt.Errorf("interactive resolve aborted: %w", err)
+			}
+
+			var choice ConflictChoice
+			switch strings.ToLower(strings.TrimSpace(answer)) {
+			case "o", "ours":
+				choice = ChoiceOurs
+			case "t", "theirs":
+				choice = ChoiceTheirs
+			case "e", "edited":
+				choice = ChoiceEdited
+			case "s", "skip":
+			case "q", "quit":
+				return nil
+			default:
+				fmt.Fprintln(out, "o - keep our side\nt - keep their side\ne - stage the file as edited by hand\ns - leave this file for later\nq - stop resolving")
+				continue
+			}
+
+			if choice != "" {
+				if err := wm.resolveFile(file, choice); err != nil {
+					// Let the user fix the file and pick again
+					fmt.Fprintf(out, "%v\n", err)
+					continue
+				}
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// ContinueResolve continues the operation in progress once every conflict is resolved
+func (wm *WorkflowManager) ContinueResolve() error {
+	state, err := wm.ConflictState()
+	if err != nil {
+		return err
+	}
+	if state.Operation == OperationNone {
+		return fmt.Errorf("nothing to continue: no rebase, merge, cherry-pick or revert is in progress")
+	}
+	if len(state.Files) > 0 {
+		return &ConflictError{State: state}
+	}
+
+	// Keep the prepared commit messages instead of opening an editor
+	if _, err := wm.git("-c", "core.editor=true", string(state.Operation), "--continue"); err != nil {
+		return wm.conflictOrError(err, fmt.Sprintf("failed to continue %s", state.Operation))
+	}
+	return nil
+}
+
+// AbortResolve aborts the operation in progress and restores the branch to where it was
+func (wm *WorkflowManager) AbortResolve() error {
+	operation, err := wm.InProgressOperation()
+	if err != nil {
+		return err
+	}
+	if operation == OperationNone {
+		return fmt.Errorf("nothing to abort: no rebase, merge, cherry-pick or revert is in progress")
+	}
+	if _, err := wm.git(string(operation), "--abort"); err != nil {
+		return fmt.Errorf("failed to abort %s: %w", operation, err)
+	}
+	return nil
+}
+
+// conflictOrError returns a ConflictError if a failed command left conflicts
+// behind, and wraps err with message otherwise
+func (wm *WorkflowManager) conflictOrError(err error, message string) error {
+	if state, stateErr := wm.ConflictState(); stateErr == nil && state.Operation != OperationNone && len(state.Files) > 0 {
+		return &ConflictError{State: state}
+	}
+	return fmt.Errorf("%s: %w", message, err)
+}
+
+// countConflictHunks counts the conflict hunks git wrote into content
+func countConflictHunks(content string) int {
+	hunks := 0
+	for _, line := range strings.Split(content, "\n") {
+		if line == conflictMarker || strings.HasPrefix(line, conflictMarker+" ") {
+			hunks++
+		}
+	}
+	return hunks
+}
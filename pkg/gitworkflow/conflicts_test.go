@@ -0,0 +1,266 @@
+package gitworkflow
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const conflictedStatus = "UU app.go\x00DU removed.go\x00M  clean.go\x00AA added.go\x00"
+
+const twoHunks = `package app
+<<<<<<< HEAD
+var a = 1
+=======
+var a = 2
+>>>>>>> feature
+func f() {}
+<<<<<<< HEAD
+var b = 1
+=======
+var b = 2
+>>>>>>> feature
+`
+
+// conflictRepo creates a repository root and git directory with operationMarker in
+// it and returns an executor that reports status for the working tree
+func conflictRepo(t *testing.T, operationMarker, status string) *RecordingExecutor {
+	t.Helper()
+	root := t.TempDir()
+	gitDir := filepath.Join(root, ".git")
+	if err := os.MkdirAll(gitDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if operationMarker != "" {
+		if err := os.MkdirAll(filepath.Join(gitDir, operationMarker), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{"app.go": twoHunks, "added.go": "<<<<<<< HEAD\nx\n=======\ny\n>>>>>>> feature\n"}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return NewRecordingExecutor().
+		OnOutput("git rev-parse --absolute-git-dir", gitDir).
+		OnOutput("git rev-parse --show-toplevel", root).
+		OnOutput(statusCommand, status)
+}
+
+func TestConflictState(t *testing.T) {
+	executor := conflictRepo(t, "rebase-merge", conflictedStatus)
+	wm := NewWorkflowManagerWithExecutor(executor)
+
+	state, err := wm.ConflictState()
+	if err != nil {
+		t.Fatalf("ConflictState() unexpected error: %v", err)
+	}
+	if state.Operation != OperationRebase {
+		t.Errorf("Expected rebase in progress, got %q", state.Operation)
+	}
+
+	want := []struct {
+		path  string
+		kind  string
+		hunks int
+	}{
+		{"app.go", "both modified", 2},
+		{"removed.go", "deleted by us", 0},
+		{"added.go", "both added", 1},
+	}
+	if len(state.Files) != len(want) {
+		t.Fatalf("Expected %d conflicted files, got %+v", len(want), state.Files)
+	}
+	for i, w := range want {
+		file := state.Files[i]
+		if file.Path != w.path || file.Kind() != w.kind || file.Hunks != w.hunks {
+			t.Errorf("File %d = %s (%s, %d hunks), want %s (%s, %d hunks)",
+				i, file.Path, file.Kind(), file.Hunks, w.path, w.kind, w.hunks)
+		}
+	}
+
+	description := state.Describe()
+	for _, expected := range []string{"A rebase is in progress", "app.go (2 conflict hunk(s))", "resolve --continue", "resolve --abort"} {
+		if !strings.Contains(description, expected) {
+			t.Errorf("Expected description to contain %q, got:\n%s", expected, description)
+		}
+	}
+}
+
+func TestInProgressOperation(t *testing.T) {
+	tests := []struct {
+		marker string
+		want   GitOperation
+	}{
+		{"", OperationNone},
+		{"rebase-apply", OperationRebase},
+		{"MERGE_HEAD", OperationMerge},
+		{"CHERRY_PICK_HEAD", OperationCherryPick},
+	}
+
+	for _, tt := range tests {
+		got, err := NewWorkflowManagerWithExecutor(conflictRepo(t, tt.marker, conflictedStatus)).InProgressOperation()
+		if err != nil {
+			t.Fatalf("InProgressOperation() unexpected error: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("InProgressOperation() with %q = %q, want %q", tt.marker, got, tt.want)
+		}
+	}
+}
+
+func TestResolveConflictsRebaseStopsOnConflicts(t *testing.T) {
+	executor := conflictRepo(t, "rebase-merge", conflictedStatus).
+		OnFailure("git rebase origin/main", 1, "CONFLICT (content): Merge conflict in app.go")
+	wm := NewWorkflowManagerWithConfig(Config{BaseBranch: "main"}, executor)
+
+	err := wm.ResolveConflictsRebase()
+	var conflictErr *ConflictError
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("Expected a ConflictError, got %v", err)
+	}
+	if conflictErr.State.Operation != OperationRebase || len(conflictErr.State.Files) != 3 {
+		t.Errorf("Unexpected conflict state: %+v", conflictErr.State)
+	}
+}
+
+func TestResolveConflictsRebaseOtherFailure(t *testing.T) {
+	executor := conflictRepo(t, "", " M dirty.go\x00").
+		OnFailure("git rebase origin/main", 1, "cannot rebase: You have unstaged changes.")
+	wm := NewWorkflowManagerWithConfig(Config{BaseBranch: "main"}, executor)
+
+	err := wm.ResolveConflictsRebase()
+	var conflictErr *ConflictError
+	if err == nil || errors.As(err, &conflictErr) {
+		t.Fatalf("Expected a plain rebase error, got %v", err)
+	}
+}
+
+func TestResolveFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		choice  ConflictChoice
+		want    []string
+		wantErr string
+	}{
+		{
+			name:   "ours",
+			path:   "app.go",
+			choice: ChoiceOurs,
+			want:   []string{"git checkout --ours -- :/app.go", "git add -- :/app.go"},
+		},
+		{
+			name:   "theirs",
+			path:   "app.go",
+			choice: ChoiceTheirs,
+			want:   []string{"git checkout --theirs -- :/app.go", "git add -- :/app.go"},
+		},
+		{
+			name:   "ours deleted the file",
+			path:   "removed.go",
+			choice: ChoiceOurs,
+			want:   []string{"git rm --quiet -- :/removed.go"},
+		},
+		{
+			name:    "edited with markers left",
+			path:    "app.go",
+			choice:  ChoiceEdited,
+			wantErr: "still has 2 conflict hunk(s)",
+		},
+		{
+			name:    "not conflicted",
+			path:    "clean.go",
+			choice:  ChoiceOurs,
+			wantErr: "no conflicts",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := conflictRepo(t, "rebase-merge", conflictedStatus)
+			err := NewWorkflowManagerWithExecutor(executor).ResolveFile(tt.path, tt.choice)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ResolveFile() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveFile() unexpected error: %v", err)
+			}
+			// The first commands read the conflict state
+			assertCommands(t, executor, append([]string{statusCommand, "git rev-parse --show-toplevel"}, tt.want...))
+		})
+	}
+}
+
+func TestResolveInteractive(t *testing.T) {
+	executor := conflictRepo(t, "rebase-merge", conflictedStatus)
+	var out bytes.Buffer
+
+	// theirs for app.go, an invalid answer then skip for removed.go, quit before added.go
+	err := NewWorkflowManagerWithExecutor(executor).ResolveInteractive(strings.NewReader("t\nx\ns\nq\n"), &out)
+	if err != nil {
+		t.Fatalf("ResolveInteractive() unexpected error: %v", err)
+	}
+	assertCommands(t, executor, []string{
+		statusCommand,
+		"git rev-parse --show-toplevel",
+		"git checkout --theirs -- :/app.go",
+		"git add -- :/app.go",
+	})
+	if !strings.Contains(out.String(), "o - keep our side") {
+		t.Errorf("Expected help after an invalid answer, got:\n%s", out.String())
+	}
+}
+
+func TestContinueResolve(t *testing.T) {
+	t.Run("refuses while conflicts remain", func(t *testing.T) {
+		executor := conflictRepo(t, "rebase-merge", conflictedStatus)
+		err := NewWorkflowManagerWithExecutor(executor).ContinueResolve()
+		var conflictErr *ConflictError
+		if !errors.As(err, &conflictErr) {
+			t.Fatalf("Expected a ConflictError, got %v", err)
+		}
+		for _, command := range executor.Commands {
+			if strings.Contains(command, "--continue") {
+				t.Errorf("Expected no continue while conflicts remain, got %q", command)
+			}
+		}
+	})
+
+	t.Run("continues without an editor", func(t *testing.T) {
+		executor := conflictRepo(t, "MERGE_HEAD", "M  app.go\x00")
+		if err := NewWorkflowManagerWithExecutor(executor).ContinueResolve(); err != nil {
+			t.Fatalf("ContinueResolve() unexpected error: %v", err)
+		}
+		last := executor.Commands[len(executor.Commands)-1]
+		if last != "git -c core.editor=true merge --continue" {
+			t.Errorf("Expected merge --continue, got %q", last)
+		}
+	})
+
+	t.Run("nothing in progress", func(t *testing.T) {
+		err := NewWorkflowManagerWithExecutor(conflictRepo(t, "", conflictedStatus)).ContinueResolve()
+		if err == nil || !strings.Contains(err.Error(), "nothing to continue") {
+			t.Fatalf("Expected nothing to continue error, got %v", err)
+		}
+	})
+}
+
+func TestAbortResolve(t *testing.T) {
+	executor := conflictRepo(t, "rebase-merge", conflictedStatus)
+	if err := NewWorkflowManagerWithExecutor(executor).AbortResolve(); err != nil {
+		t.Fatalf("AbortResolve() unexpected error: %v", err)
+	}
+	assertCommands(t, executor, []string{
+		"git rev-parse --absolute-git-dir",
+		"git rebase --abort",
+	})
+}
@@ -0,0 +1,164 @@
+package gitworkflow
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// readOnlyGitCommands are git subcommands that never change refs, the index or
+// the working tree. commit-tree and "stash create" only write unreferenced objects.
+var readOnlyGitCommands = map[string]bool{
+	"blame":        true,
+	"cat-file":     true,
+	"check-ignore": true,
+	"cherry":       true,
+	"commit-tree":  true,
+	"describe":     true,
+	"diff":         true,
+	"diff-files":   true,
+	"diff-index":   true,
+	"diff-tree":    true,
+	"for-each-ref": true,
+	"grep":         true,
+	"log":          true,
+	"ls-files":     true,
+	"ls-remote":    true,
+	"merge-base":   true,
+	"name-rev":     true,
+	"rev-list":     true,
+	"rev-parse":    true,
+	"shortlog":     true,
+	"show":         true,
+	"show-ref":     true,
+	"status":       true,
+	"var":          true,
+}
+
+// DryRunExecutor runs read-only git commands through another executor and
+// only reports the commands that would change the repository
+type DryRunExecutor struct {
+	executor CommandExecutorInterface
+	out      io.Writer
+	// Skipped holds each command that was reported instead of run, e.g. "git push origin W-1"
+	Skipped []string
+}
+
+// NewDryRunExecutor creates a DryRunExecutor that runs read-only commands through
+// executor and writes every skipped command to out
+func NewDryRunExecutor(executor CommandExecutorInterface, out io.Writer) *DryRunExecutor {
+	return &DryRunExecutor{executor: executor, out: out}
+}
+
+// Execute runs read-only commands and reports the rest, which succeed with empty output
+func (d *DryRunExecutor) Execute(name string, args ...string) (CommandResult, error) {
+	if name == "git" && isReadOnlyGit(args) {
+		return d.executor.Execute(name, args...)
+	}
+	d.Skipped = append(d.Skipped, formatCommand(name, args))
+	fmt.Fprintf(d.out, "would run: %s\n", displayCommand(name, args))
+	return CommandResult{}, nil
+}
+
+// isReadOnlyGit reports whether the git command with args leaves the repository untouched
+func isReadOnlyGit(args []string) bool {
+	// Skip global options such as "-c core.editor=true" and "-C dir"
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		if args[0] == "-c" || args[0] == "-C" {
+			args = args[1:]
+		}
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return true
+	}
+
+	subcommand, rest := args[0], args[1:]
+	switch subcommand {
+	case "stash":
+		return len(rest) > 0 && (rest[0] == "create" || rest[0] == "list" || rest[0] == "show")
+	case "tag":
+		return hasAnyArg(rest, "--list", "-l")
+	case "branch":
+		return hasAnyArg(rest, "--list", "-l", "--show-current")
+	case "add", "clean":
+		return hasAnyArg(rest, "--dry-run", "-n")
+	case "config":
+		return hasAnyArg(rest, "--get", "--get-all", "--get-regexp", "--list", "-l")
+	case "symbolic-ref":
+		// Reading takes a single ref; writing takes a ref and a target
+		return len(positionalArgs(rest)) <= 1
+	case "worktree", "remote":
+		return len(rest) == 0 || rest[0] == "list" || rest[0] == "-v" || rest[0] == "get-url" || rest[0] == "show"
+	}
+	return readOnlyGitCommands[subcommand]
+}
+
+// hasAnyArg reports whether args contains any of flags
+func hasAnyArg(args []string, flags ...string) bool {
+	for _, arg := range args {
+		if contains(flags, arg) {
+			return true
+		}
+	}
+	return false
+}
+
+// positionalArgs returns the arguments that are not flags
+func positionalArgs(args []string) []string {
+	var positional []string
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			positional = append(positional, arg)
+		}
+	}
+	return positional
+}
+
+// displayCommand renders a command so it can be copied into a shell, quoting
+// arguments that contain spaces or special characters
+func displayCommand(name string, args []string) string {
+	parts := []string{name}
+	for _, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n\"'$`\\*?{}()<>|&;") {
+			arg = strconv.Quote(arg)
+		}
+		parts = append(parts, arg)
+	}
+	return strings.Join(parts, " ")
+}
+
+// EnableDryRun makes the WorkflowManager report the commands that would change
+// the repository instead of running them, and log the decisions it makes to out
+func (wm *WorkflowManager) EnableDryRun(out io.Writer) {
+	wm.executor = NewDryRunExecutor(wm.executor, out)
+	wm.logger = out
+	wm.dryRun = true
+}
+
+// DryRun reports whether the WorkflowManager only reports changes
+func (wm *WorkflowManager) DryRun() bool {
+	return wm.dryRun
+}
+
+// SetLogger sets where the WorkflowManager explains the decisions it makes; nil disables logging
+func (wm *WorkflowManager) SetLogger(out io.Writer) {
+	wm.logger = out
+}
+
+// logf explains a decision, e.g. how far a branch is behind its remote
+func (wm *WorkflowManager) logf(format string, args ...interface{}) {
+	if wm.logger != nil {
+		fmt.Fprintf(wm.logger, "# "+format+"\n", args...)
+	}
+}
+
+// SkippedCommands returns the commands a dry run reported instead of running,
+// or nil when dry run is not enabled
+func (wm *WorkflowManager) SkippedCommands() []string {
+	if dryRun, ok := wm.executor.(*DryRunExecutor); ok {
+		return dryRun.Skipped
+	}
+	return nil
+}
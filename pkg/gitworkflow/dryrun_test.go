@@ -0,0 +1,108 @@
+package gitworkflow
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestIsReadOnlyGit(t *testing.T) {
+	tests := []struct {
+		command  string
+		readOnly bool
+	}{
+		{"rev-parse --abbrev-ref HEAD", true},
+		{"status --porcelain -z", true},
+		{"rev-list --left-right --count origin/W-1...W-1", true},
+		{"symbolic-ref --quiet --short refs/remotes/origin/HEAD", true},
+		{"symbolic-ref HEAD refs/heads/main", false},
+		{"tag --list --merged HEAD", true},
+		{"tag -a v1.0.0 -m release", false},
+		{"branch --show-current", true},
+		{"branch -D W-1", false},
+		{"add --dry-run --all -- cmd", true},
+		{"add --all -- :/main.go", false},
+		{"stash create", true},
+		{"stash store -m backup abc", false},
+		{"-c core.editor=true rebase --continue", false},
+		{"-c color.ui=never log --oneline", true},
+		{"fetch origin", false},
+		{"checkout -b W-1", false},
+		{"push -u origin W-1", false},
+		{"reset --hard HEAD~1", false},
+	}
+
+	for _, tt := range tests {
+		if got := isReadOnlyGit(strings.Fields(tt.command)); got != tt.readOnly {
+			t.Errorf("isReadOnlyGit(%q) = %v, want %v", tt.command, got, tt.readOnly)
+		}
+	}
+}
+
+func TestDryRunExecutor(t *testing.T) {
+	inner := NewRecordingExecutor().OnOutput("git rev-parse --abbrev-ref HEAD", "W-1\n")
+	var out bytes.Buffer
+	executor := NewDryRunExecutor(inner, &out)
+
+	result, err := executor.Execute("git", "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil || result.Stdout != "W-1\n" {
+		t.Fatalf("Expected read-only command to run, got %q, %v", result.Stdout, err)
+	}
+	if _, err := executor.Execute("git", "commit", "-m", "feat: add login"); err != nil {
+		t.Fatalf("Expected skipped command to succeed, got %v", err)
+	}
+
+	assertCommands(t, inner, []string{"git rev-parse --abbrev-ref HEAD"})
+	if len(executor.Skipped) != 1 || executor.Skipped[0] != "git commit -m feat: add login" {
+		t.Errorf("Unexpected skipped commands: %v", executor.Skipped)
+	}
+	if got := out.String(); got != "would run: git commit -m \"feat: add login\"\n" {
+		t.Errorf("Unexpected dry-run output: %q", got)
+	}
+}
+
+func TestSyncWithRemoteDryRun(t *testing.T) {
+	inner := NewRecordingExecutor().
+		OnOutput("git rev-parse --abbrev-ref HEAD", "W-1\n").
+		OnOutput("git rev-list --left-right --count origin/W-1...W-1", "2\t0\n")
+	var out bytes.Buffer
+	wm := NewWorkflowManagerWithExecutor(inner)
+	wm.EnableDryRun(&out)
+
+	if err := wm.SyncWithRemote(); err != nil {
+		t.Fatalf("SyncWithRemote() unexpected error: %v", err)
+	}
+	for _, command := range inner.Commands {
+		if !isReadOnlyGit(strings.Fields(strings.TrimPrefix(command, "git "))) {
+			t.Errorf("Expected only read-only commands to run, got %q", command)
+		}
+	}
+	for _, expected := range []string{
+		"would run: git fetch origin",
+		"# W-1 is 0 commit(s) ahead of and 2 behind origin/W-1",
+		"would run: git merge --ff-only origin/W-1",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected dry-run output to contain %q, got:\n%s", expected, out.String())
+		}
+	}
+}
+
+func TestCommitStoryDryRunSkipsEmptyCheck(t *testing.T) {
+	inner := NewRecordingExecutor().
+		OnOutput(statusCommand, " M main.go\x00").
+		// Nothing is staged because git add did not run
+		On("git diff --cached --quiet", CommandResult{})
+	var out bytes.Buffer
+	wm := NewWorkflowManagerWithExecutor(inner)
+	wm.EnableDryRun(&out)
+
+	if _, err := wm.CommitStory(CommitMessage{Description: "add login"}, StageOptions{}); err != nil {
+		t.Fatalf("CommitStory() unexpected error: %v", err)
+	}
+	for _, expected := range []string{"would run: git add --all -- :/main.go", "would run: git commit -m \"feat: add login\""} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected dry-run output to contain %q, got:\n%s", expected, out.String())
+		}
+	}
+}
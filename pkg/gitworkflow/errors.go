@@ -0,0 +1,196 @@
+package gitworkflow
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Kinds of failure callers can check for with errors.Is. The *GitError carrying
+// them has the command, exit code and stderr and can be retrieved with errors.As.
+var (
+	ErrDirtyWorktree          = errors.New("the working tree has uncommitted changes")
+	ErrDiverged               = errors.New("the branch and its remote have diverged")
+	ErrAheadOfRemote          = errors.New("the branch has commits that are not on its remote")
+	ErrConflict               = errors.New("there are merge conflicts")
+	ErrAuthFailed             = errors.New("authentication with the remote failed")
+	ErrRejectedNonFastForward = errors.New("the remote rejected a non-fast-forward update")
+	ErrStashConflict          = errors.New("the stashed changes do not apply cleanly")
+	ErrNotMerged              = errors.New("the branch is not merged into the base branch")
+)
+
+// stderrPatterns maps git's error output to the kind of failure it describes.
+// The first matching kind wins.
+var stderrPatterns = []struct {
+	kind     error
+	patterns []string
+}{
+	{ErrAuthFailed, []string{
+		"Authentication failed",
+		"Permission denied (publickey",
+		"could not read Username",
+		"terminal prompts disabled",
+		"The requested URL returned error: 403",
+		"Invalid username or password",
+	}},
+	{ErrRejectedNonFastForward, []string{
+		"non-fast-forward",
+		"(fetch first)",
+		"Updates were rejected",
+	}},
+	{ErrConflict, []string{
+		"CONFLICT (",
+		"Automatic merge failed",
+		"could not apply",
+		"you need to resolve your current index first",
+	}},
+	{ErrDirtyWorktree, []string{
+		"Your local changes to the following files would be overwritten",
+		"You have unstaged changes",
+		"Your index contains uncommitted changes",
+		"untracked working tree files would be overwritten",
+		"Please commit or stash them",
+		"contains modified or untracked files",
+	}},
+	{ErrDiverged, []string{
+		"Not possible to fast-forward",
+		"have diverged",
+		"divergent branches",
+	}},
+}
+
+// GitError is a git command that failed, or a workflow step that was refused
+// because of the state of the repository
+type GitError struct {
+	// Kind is one of the Err values above, or nil if the failure is not recognized
+	Kind error
+	// Command, ExitCode and Stderr describe the failed git command; Command is
+	// empty when the step was refused without running git
+	Command  string
+	ExitCode int
+	Stderr   string
+	// Message explains a refused step
+	Message string
+	// Err is the underlying *ExitError, if any
+	Err error
+}
+
+// Error summarizes the failure on one line
+func (e *GitError) Error() string {
+	if e.Command == "" {
+		if e.Message != "" {
+			return e.Message
+		}
+		return fmt.Sprint(e.Kind)
+	}
+
+	command := e.Command
+	if first, _, multiline := strings.Cut(command, "\n"); multiline {
+		command = first + " ..."
+	}
+	text := fmt.Sprintf("%s: exit status %d", command, e.ExitCode)
+	if summary := summarizeStderr(e.Stderr); summary != "" {
+		text += ": " + summary
+	}
+	return text
+}
+
+// Unwrap exposes the kind for errors.Is and the underlying error for errors.As
+func (e *GitError) Unwrap() []error {
+	var wrapped []error
+	if e.Kind != nil {
+		wrapped = append(wrapped, e.Kind)
+	}
+	if e.Err != nil {
+		wrapped = append(wrapped, e.Err)
+	}
+	return wrapped
+}
+
+// Hint suggests how to get past the failure, or returns an empty string
+func (e *GitError) Hint() string {
+	switch e.Kind {
+	case ErrDirtyWorktree:
+		return "Commit your changes with 'vamosGitWF story-commit', stash them with 'git stash', or rerun sync or story-start with --autostash."
+	case ErrDiverged:
+		return "Both your branch and its remote have new commits. Run 'vamosGitWF sync' to rebase or merge them, then push."
+	case ErrAheadOfRemote:
+		return "Push your commits with 'vamosGitWF sync --push' or 'vamosGitWF story-push'."
+	case ErrConflict:
+		return "Run 'vamosGitWF resolve' to see the conflicted files and how to continue or abort."
+	case ErrAuthFailed:
+		return "Check your credentials: refresh the token or credential helper for HTTPS remotes, or your SSH key for SSH remotes."
+	case ErrRejectedNonFastForward:
+		return "The remote has commits you don't have. Run 'vamosGitWF sync' to bring them in, then push again."
+	case ErrStashConflict:
+		return "Fix the conflict markers, run 'git reset' to mark the files resolved and 'git stash drop' to discard the kept stash. To start over instead, run 'git reset --hard' and then 'git stash pop'."
+	case ErrNotMerged:
+		return "Merge the story's pull request first. If it was squash merged, check nothing is missing and delete the branch with 'git branch -D'."
+	default:
+		return ""
+	}
+}
+
+// newGitError converts a failed command into a *GitError, recognizing the kind
+// of failure from stderr. Errors that are not command failures are returned as is.
+func newGitError(err error) error {
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) {
+		return err
+	}
+	return &GitError{
+		Kind:     classifyStderr(exitErr.Stderr),
+		Command:  exitErr.Command,
+		ExitCode: exitErr.ExitCode,
+		Stderr:   exitErr.Stderr,
+		Err:      exitErr,
+	}
+}
+
+// refused returns a *GitError for a step the workflow refuses to take
+func refused(kind error, format string, args ...interface{}) *GitError {
+	return &GitError{Kind: kind, Message: fmt.Sprintf(format, args...)}
+}
+
+// classifyStderr returns the kind of failure git's error output describes, or nil
+func classifyStderr(stderr string) error {
+	for _, entry := range stderrPatterns {
+		for _, pattern := range entry.patterns {
+			if strings.Contains(stderr, pattern) {
+				return entry.kind
+			}
+		}
+	}
+	return nil
+}
+
+// stderrOf returns the stderr captured with err, or an empty string
+func stderrOf(err error) string {
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Stderr
+	}
+	return ""
+}
+
+// summarizeStderr picks the lines of git's error output worth showing on one line:
+// the "fatal:", "error:" and rejected lines, or the last line if there are none
+func summarizeStderr(stderr string) string {
+	var important []string
+	var last string
+	for _, line := range strings.Split(stderr, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		last = line
+		if strings.HasPrefix(line, "fatal:") || strings.HasPrefix(line, "error:") ||
+			strings.HasPrefix(line, "! [") || strings.HasPrefix(line, "CONFLICT") {
+			important = append(important, line)
+		}
+	}
+	if len(important) == 0 {
+		return last
+	}
+	return strings.Join(important, "; ")
+}
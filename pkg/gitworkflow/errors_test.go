@@ -0,0 +1,134 @@
+package gitworkflow
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestClassifyStderr(t *testing.T) {
+	tests := []struct {
+		stderr string
+		want   error
+	}{
+		{"remote: Invalid username or password.\nfatal: Authentication failed for 'https://github.com/o/r.git/'", ErrAuthFailed},
+		{"git@github.com: Permission denied (publickey).\nfatal: Could not read from remote repository.", ErrAuthFailed},
+		{" ! [rejected]        W-1 -> W-1 (fetch first)\nerror: failed to push some refs", ErrRejectedNonFastForward},
+		{" ! [rejected]        W-1 -> W-1 (non-fast-forward)", ErrRejectedNonFastForward},
+		{"CONFLICT (content): Merge conflict in app.go\nAutomatic merge failed; fix conflicts and then commit the result.", ErrConflict},
+		{"error: cannot pull with rebase: You have unstaged changes.", ErrDirtyWorktree},
+		{"error: Your local changes to the following files would be overwritten by checkout:\n\tapp.go", ErrDirtyWorktree},
+		{"fatal: Not possible to fast-forward, aborting.", ErrDiverged},
+		{"fatal: not a git repository (or any of the parent directories): .git", nil},
+	}
+
+	for _, tt := range tests {
+		if got := classifyStderr(tt.stderr); got != tt.want {
+			t.Errorf("classifyStderr(%q) = %v, want %v", tt.stderr, got, tt.want)
+		}
+	}
+}
+
+func TestPushStoryBranchRejected(t *testing.T) {
+	stderr := "To github.com:o/r.git\n ! [rejected]        W-1 -> W-1 (fetch first)\n" +
+		"error: failed to push some refs to 'github.com:o/r.git'\nhint: Updates were rejected because the remote contains work that you do\n"
+	executor := NewRecordingExecutor().
+		OnOutput("git rev-parse --abbrev-ref HEAD", "W-1\n").
+		OnFailure("git push -u origin W-1", 1, stderr)
+
+	err := NewWorkflowManagerWithExecutor(executor).PushStoryBranch()
+	if !errors.Is(err, ErrRejectedNonFastForward) {
+		t.Fatalf("Expected ErrRejectedNonFastForward, got %v", err)
+	}
+
+	var gitErr *GitError
+	if !errors.As(err, &gitErr) {
+		t.Fatalf("Expected a *GitError, got %T", err)
+	}
+	if gitErr.Command != "git push -u origin W-1" || gitErr.ExitCode != 1 || gitErr.Stderr != stderr {
+		t.Errorf("Unexpected GitError fields: %+v", gitErr)
+	}
+	if gitErr.Hint() == "" {
+		t.Error("Expected a hint for a rejected push")
+	}
+
+	want := "failed to push branch: git push -u origin W-1: exit status 1: " +
+		"! [rejected]        W-1 -> W-1 (fetch first); error: failed to push some refs to 'github.com:o/r.git'"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+
+	// The underlying ExitError is still reachable
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode != 1 {
+		t.Errorf("Expected the ExitError to be wrapped, got %v", exitErr)
+	}
+}
+
+func TestSyncErrorKinds(t *testing.T) {
+	tests := []struct {
+		name     string
+		executor *RecordingExecutor
+		want     error
+	}{
+		{
+			name: "dirty worktree",
+			executor: NewRecordingExecutor().
+				OnOutput("git rev-parse --abbrev-ref HEAD", "W-1\n").
+				OnOutput("git rev-list --left-right --count origin/W-1...W-1", "2\t0\n").
+				OnOutput(statusCommand, " M main.go\x00"),
+			want: ErrDirtyWorktree,
+		},
+		{
+			name: "conflict",
+			executor: NewRecordingExecutor().
+				OnOutput("git rev-parse --abbrev-ref HEAD", "W-1\n").
+				OnOutput("git rev-list --left-right --count origin/W-1...W-1", "2\t3\n").
+				OnFailure("git merge --no-edit origin/W-1", 1, "CONFLICT (content): Merge conflict in main.go\nAutomatic merge failed; fix conflicts and then commit the result."),
+			want: ErrConflict,
+		},
+		{
+			name: "rejected push",
+			executor: NewRecordingExecutor().
+				OnOutput("git rev-parse --abbrev-ref HEAD", "W-1\n").
+				OnOutput("git rev-list --left-right --count origin/W-1...W-1", "0\t2\n").
+				OnFailure("git push origin W-1", 1, " ! [rejected]        W-1 -> W-1 (fetch first)\nerror: failed to push some refs"),
+			want: ErrRejectedNonFastForward,
+		},
+		{
+			name: "authentication",
+			executor: NewRecordingExecutor().
+				OnFailure("git fetch origin", 128, "fatal: Authentication failed for 'https://github.com/o/r.git/'"),
+			want: ErrAuthFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.Sync.Strategy = SyncMerge
+			_, err := NewWorkflowManagerWithConfig(config, tt.executor).Sync(SyncOptions{Push: true})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Sync() error = %v, want %v", err, tt.want)
+			}
+			var gitErr *GitError
+			if !errors.As(err, &gitErr) || gitErr.Hint() == "" {
+				t.Errorf("Expected a *GitError with a hint, got %#v", err)
+			}
+		})
+	}
+}
+
+func TestConflictErrorIsErrConflict(t *testing.T) {
+	err := error(&ConflictError{State: &ConflictState{Operation: OperationRebase}})
+	if !errors.Is(err, ErrConflict) {
+		t.Error("Expected ConflictError to match ErrConflict")
+	}
+}
+
+func TestGitErrorSummarizesMultilineCommand(t *testing.T) {
+	err := &GitError{Command: "git commit -m feat: add login\n\nRefs: W-1", ExitCode: 1, Stderr: "nothing to commit"}
+	if got := err.Error(); got != "git commit -m feat: add login ...: exit status 1: nothing to commit" || strings.Contains(got, "\n") {
+		t.Errorf("Error() = %q", got)
+	}
+}
@@ -0,0 +1,77 @@
+package gitworkflow
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// CommandExecutorInterface defines the interface for running external commands
+type CommandExecutorInterface interface {
+	Execute(name string, args ...string) (CommandResult, error)
+}
+
+// CommandResult holds the captured output of an executed command
+type CommandResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// ExitError is returned when a command runs but exits with a non-zero status
+type ExitError struct {
+	Command  string
+	ExitCode int
+	Stderr   string
+}
+
+// Error implements the error interface
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.ExitCode)
+}
+
+// ExecExecutor runs commands on the local machine using os/exec
+type ExecExecutor struct {
+	// Dir is the working directory for commands; empty means the current directory
+	Dir string
+}
+
+// NewExecExecutor creates a new ExecExecutor rooted at dir
+func NewExecExecutor(dir string) *ExecExecutor {
+	return &ExecExecutor{Dir: dir}
+}
+
+// Execute runs the command and captures its stdout, stderr and exit code
+func (e *ExecExecutor) Execute(name string, args ...string) (CommandResult, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Dir = e.Dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	result := CommandResult{
+		Stdout: stdout.String(),
+		Stderr: stderr.String(),
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			result.ExitCode = exitErr.ExitCode()
+			return result, &ExitError{
+				Command:  formatCommand(name, args),
+				ExitCode: result.ExitCode,
+				Stderr:   result.Stderr,
+			}
+		}
+		return result, err
+	}
+	return result, nil
+}
+
+// formatCommand renders a command and its arguments as a single line
+func formatCommand(name string, args []string) string {
+	return strings.TrimSpace(name + " " + strings.Join(args, " "))
+}
@@ -0,0 +1,63 @@
+package gitworkflow
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestExecExecutorCapturesOutput(t *testing.T) {
+	executor := NewExecExecutor(t.TempDir())
+
+	result, err := executor.Execute("git", "--version")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(result.Stdout, "git version") {
+		t.Errorf("Expected git version on stdout, got %q", result.Stdout)
+	}
+	if result.ExitCode != 0 {
+		t.Errorf("Expected exit code 0, got %d", result.ExitCode)
+	}
+}
+
+func TestExecExecutorCapturesFailure(t *testing.T) {
+	executor := NewExecExecutor(t.TempDir())
+
+	result, err := executor.Execute("git", "rev-parse", "HEAD")
+	if err == nil {
+		t.Fatal("Expected error running git outside a repository")
+	}
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("Expected ExitError, got %T", err)
+	}
+	if exitErr.ExitCode == 0 || exitErr.ExitCode != result.ExitCode {
+		t.Errorf("Expected matching non-zero exit codes, got %d and %d", exitErr.ExitCode, result.ExitCode)
+	}
+	if exitErr.Stderr == "" {
+		t.Error("Expected stderr to be captured")
+	}
+	if exitErr.Command != "git rev-parse HEAD" {
+		t.Errorf("Unexpected command %q", exitErr.Command)
+	}
+}
+
+func TestRecordingExecutorQueuesResults(t *testing.T) {
+	executor := NewRecordingExecutor().
+		OnFailure("git pull", 1, "conflict").
+		OnOutput("git pull", "ok")
+
+	if _, err := executor.Execute("git", "pull"); err == nil {
+		t.Error("Expected first call to fail")
+	}
+	for i := 0; i < 2; i++ {
+		result, err := executor.Execute("git", "pull")
+		if err != nil || result.Stdout != "ok" {
+			t.Errorf("Call %d: got %q, %v", i+2, result.Stdout, err)
+		}
+	}
+	if len(executor.Commands) != 3 {
+		t.Errorf("Expected 3 recorded commands, got %d", len(executor.Commands))
+	}
+}
@@ -0,0 +1,58 @@
+package gitworkflow
+
+import (
+	"context"
+
+	"github.com/thomaschangsf/vamos/internal/github"
+)
+
+// gitHubClient opens pull requests through the GitHub REST API
+type gitHubClient struct {
+	client *github.Client
+}
+
+// NewGitHubClient returns a pull request client for the GitHub REST API. An
+// empty apiURL uses the public API; GitHub Enterprise serves it under
+// https://<host>/api/v3.
+func NewGitHubClient(apiURL, token string) PullRequestClientInterface {
+	return &gitHubClient{client: github.NewClient(apiURL, token)}
+}
+
+// CreatePullRequest opens a pull request in owner/repo
+func (c *gitHubClient) CreatePullRequest(ctx context.Context, owner, repo string, pr NewPullRequest) (*PullRequest, error) {
+	created, err := c.client.CreatePullRequest(ctx, owner, repo, github.NewPullRequest{
+		Title: pr.Title,
+		Head:  pr.Head,
+		Base:  pr.Base,
+		Body:  pr.Body,
+		Draft: pr.Draft,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fromGitHub(created), nil
+}
+
+// FindPullRequest returns the open pull request from head, or nil if there is none
+func (c *gitHubClient) FindPullRequest(ctx context.Context, owner, repo, head string) (*PullRequest, error) {
+	found, err := c.client.FindPullRequest(ctx, owner, repo, head)
+	if err != nil || found == nil {
+		return nil, err
+	}
+	return fromGitHub(found), nil
+}
+
+// RequestReviewers asks users, or teams given as "org/team", to review a pull request
+func (c *gitHubClient) RequestReviewers(ctx context.Context, owner, repo string, number int, reviewers []string) error {
+	return c.client.RequestReviewers(ctx, owner, repo, number, reviewers)
+}
+
+// AddLabels adds labels to a pull request
+func (c *gitHubClient) AddLabels(ctx context.Context, owner, repo string, number int, labels []string) error {
+	return c.client.AddLabels(ctx, owner, repo, number, labels)
+}
+
+// fromGitHub converts a pull request returned by the API
+func fromGitHub(pr *github.PullRequest) *PullRequest {
+	return &PullRequest{Number: pr.Number, URL: pr.HTMLURL, Title: pr.Title, Draft: pr.Draft}
+}
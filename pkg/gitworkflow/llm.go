@@ -0,0 +1,99 @@
+package gitworkflow
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// DefaultDiffTokenBudget is roughly how many tokens of diff are sent to the text generator
+const DefaultDiffTokenBudget = 4000
+
+// charsPerToken approximates how many characters of source code make up one token
+const charsPerToken = 4
+
+// minFileDiffChars is the least of each file's diff kept when a diff is cut to fit
+const minFileDiffChars = 400
+
+// TextGeneratorInterface defines the interface for generating text with a language model
+type TextGeneratorInterface interface {
+	GenerateText(ctx context.Context, prompt string) (string, error)
+}
+
+// SetTextGenerator sets the language model used to suggest commit messages and
+// pull request descriptions
+func (wm *WorkflowManager) SetTextGenerator(generator TextGeneratorInterface) {
+	wm.generator = generator
+}
+
+// generateText sends prompt to the text generator and returns its trimmed reply
+// with any surrounding code fence removed
+func (wm *WorkflowManager) generateText(prompt string) (string, error) {
+	if wm.generator == nil {
+		return "", fmt.Errorf("no language model configured: set LLM_API_KEY")
+	}
+	wm.logf("asking the language model (%d characters of prompt)", len(prompt))
+
+	reply, err := wm.generator.GenerateText(context.Background(), prompt)
+	if err != nil {
+		return "", fmt.Errorf("language model request failed: %w", err)
+	}
+	reply = strings.TrimSpace(reply)
+	if strings.HasPrefix(reply, "```") {
+		// Drop the opening fence line, e.g. "```text", and the closing fence
+		if newline := strings.Index(reply, "\n"); newline >= 0 {
+			reply = reply[newline+1:]
+		}
+		reply = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(reply), "```"))
+	}
+	if reply == "" {
+		return "", fmt.Errorf("the language model returned an empty reply")
+	}
+	return reply, nil
+}
+
+// fitDiff shortens a unified diff to about maxChars. Every file keeps its
+// header and a share of its lines; files that no longer fit are only counted.
+func fitDiff(diff string, maxChars int) string {
+	if len(diff) <= maxChars {
+		return diff
+	}
+
+	files := splitDiffFiles(diff)
+	share := maxChars / len(files)
+	if share < minFileDiffChars {
+		share = minFileDiffChars
+	}
+
+	var fitted strings.Builder
+	for i, file := range files {
+		if fitted.Len()+minFileDiffChars > maxChars {
+			fmt.Fprintf(&fitted, "[... diffs of %d more file(s) omitted; see the list of changed files]\n", len(files)-i)
+			break
+		}
+		if len(file) <= share {
+			fitted.WriteString(file)
+			continue
+		}
+
+		// Cut at a line boundary and say how much was left out
+		cut := strings.LastIndex(file[:share], "\n") + 1
+		omitted := strings.Count(file[cut:], "\n")
+		fitted.WriteString(file[:cut])
+		fmt.Fprintf(&fitted, "[... %d more line(s) of this file omitted]\n", omitted)
+	}
+	return fitted.String()
+}
+
+// splitDiffFiles splits a unified diff into one section per file
+func splitDiffFiles(diff string) []string {
+	var files []string
+	for {
+		next := strings.Index(diff[1:], "\ndiff --git ")
+		if next < 0 {
+			return append(files, diff)
+		}
+		files = append(files, diff[:next+2])
+		diff = diff[next+2:]
+	}
+}
@@ -0,0 +1,106 @@
+package gitworkflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// fakeGenerator returns canned replies in order and records the prompts it was sent
+type fakeGenerator struct {
+	replies []string
+	err     error
+	prompts []string
+}
+
+func (f *fakeGenerator) GenerateText(ctx context.Context, prompt string) (string, error) {
+	f.prompts = append(f.prompts, prompt)
+	if f.err != nil {
+		return "", f.err
+	}
+	if len(f.replies) == 0 {
+		return "", nil
+	}
+	reply := f.replies[0]
+	if len(f.replies) > 1 {
+		f.replies = f.replies[1:]
+	}
+	return reply, nil
+}
+
+func TestGenerateText(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   string
+		err     error
+		want    string
+		wantErr string
+	}{
+		{name: "plain", reply: "  feat: add login\n", want: "feat: add login"},
+		{name: "code fence", reply: "```text\nfeat: add login\n\nBody\n```", want: "feat: add login\n\nBody"},
+		{name: "empty", reply: "  ", wantErr: "empty reply"},
+		{name: "request fails", err: errors.New("rate limited"), wantErr: "rate limited"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wm := NewWorkflowManagerWithExecutor(NewRecordingExecutor())
+			wm.SetTextGenerator(&fakeGenerator{replies: []string{tt.reply}, err: tt.err})
+			got, err := wm.generateText("prompt")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("generateText() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("generateText() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateTextWithoutGenerator(t *testing.T) {
+	_, err := NewWorkflowManagerWithExecutor(NewRecordingExecutor()).generateText("prompt")
+	if err == nil || !strings.Contains(err.Error(), "LLM_API_KEY") {
+		t.Fatalf("generateText() error = %v, want missing LLM_API_KEY", err)
+	}
+}
+
+// fileDiff returns a unified diff of path adding lines lines
+func fileDiff(path string, lines int) string {
+	var diff strings.Builder
+	fmt.Fprintf(&diff, "diff --git a/%s b/%s\n--- a/%s\n+++ b/%s\n@@ -0,0 +1,%d @@\n", path, path, path, path, lines)
+	for i := 0; i < lines; i++ {
+		fmt.Fprintf(&diff, "+line %d of %s\n", i, path)
+	}
+	return diff.String()
+}
+
+func TestFitDiff(t *testing.T) {
+	small := fileDiff("a.go", 3)
+	if got := fitDiff(small, 1000); got != small {
+		t.Errorf("Expected a small diff to be unchanged, got %q", got)
+	}
+
+	diff := fileDiff("a.go", 200) + fileDiff("b.go", 5) + fileDiff("c.go", 200)
+	fitted := fitDiff(diff, 2000)
+	if len(fitted) > 2200 {
+		t.Errorf("Expected the diff to be cut to about 2000 characters, got %d", len(fitted))
+	}
+	for _, want := range []string{"diff --git a/a.go", "diff --git a/b.go", "+line 4 of b.go", "diff --git a/c.go", "more line(s) of this file omitted"} {
+		if !strings.Contains(fitted, want) {
+			t.Errorf("Expected the fitted diff to contain %q:\n%s", want, fitted)
+		}
+	}
+
+	many := ""
+	for i := 0; i < 20; i++ {
+		many += fileDiff(fmt.Sprintf("f%d.go", i), 50)
+	}
+	if fitted := fitDiff(many, 2000); !strings.Contains(fitted, "more file(s) omitted") {
+		t.Errorf("Expected files that do not fit to be counted:\n%s", fitted)
+	}
+}
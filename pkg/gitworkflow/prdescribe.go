@@ -0,0 +1,110 @@
+package gitworkflow
+
+import (
+	"fmt"
+	"strings"
+)
+
+// describePromptTemplate asks for a pull request description. It is filled
+// with the story line, the commits, the diff stat and the diff.
+const describePromptTemplate = `Write the description of a pull request from the branch %s into %s.
+
+Rules:
+- Use exactly these three markdown sections, in this order: "## Summary", "## Testing" and "## Risks"
+- Summary: what changed and why, as a short paragraph or a few bullets
+- Testing: how the change was or should be tested, based on the tests and code in the diff
+- Risks: what could break, migrations or configuration to watch out for, or "None known"
+- Do not add a title and do not repeat the list of commits
+- Reply with the description only, without code fences or commentary
+%s
+Commits:
+%s
+Files changed:
+%s
+Diff:
+%s`
+
+// describeSections are the headings a pull request description must contain
+var describeSections = []string{"## Summary", "## Testing", "## Risks"}
+
+// DescribePullRequest asks the text generator for a description of a pull
+// request from branch into the base branch, with summary, testing and risks
+// sections. The story link is added in front of the reply when branch is a
+// story branch.
+func (wm *WorkflowManager) DescribePullRequest(branch string) (string, error) {
+	baseBranch, err := wm.getDefaultBranch()
+	if err != nil {
+		return "", err
+	}
+	details, err := wm.PullRequestDetails(branch, baseBranch)
+	if err != nil {
+		return "", err
+	}
+	if len(details.Commits) == 0 {
+		return "", fmt.Errorf("%s has no commits that are not on %s", branch, wm.remoteRef(baseBranch))
+	}
+
+	// Three dots compare against the merge base, like the pull request will
+	revisionRange := wm.remoteRef(baseBranch) + "..." + branch
+	stat, err := wm.gitOutput("diff", "--stat", revisionRange)
+	if err != nil {
+		return "", fmt.Errorf("failed to read changes in %s: %w", revisionRange, err)
+	}
+	diff, err := wm.git("diff", "--no-color", "--no-ext-diff", revisionRange)
+	if err != nil {
+		return "", fmt.Errorf("failed to read changes in %s: %w", revisionRange, err)
+	}
+
+	var commits strings.Builder
+	for _, commit := range details.Commits {
+		fmt.Fprintf(&commits, "- %s\n", commit.Subject)
+	}
+	story := ""
+	if details.StoryID != "" {
+		story = fmt.Sprintf("\nThe branch implements story %s.\n", details.StoryID)
+	}
+
+	prompt := fmt.Sprintf(describePromptTemplate, branch, baseBranch, story, commits.String(), stat,
+		fitDiff(diff.Stdout, DefaultDiffTokenBudget*charsPerToken))
+	reply, err := wm.generateText(prompt)
+	if err != nil {
+		return "", err
+	}
+	if missing := missingSections(reply); len(missing) > 0 {
+		wm.logf("rejected description without %s", strings.Join(missing, ", "))
+		retry := fmt.Sprintf("%s\n\nYour previous reply was rejected because it has no %s section. It was:\n%s\n\nReply again following the rules.",
+			prompt, strings.Join(missing, ", "), reply)
+		if reply, err = wm.generateText(retry); err != nil {
+			return "", err
+		}
+		if missing := missingSections(reply); len(missing) > 0 {
+			return "", fmt.Errorf("the language model did not write a valid description: no %s section", strings.Join(missing, ", "))
+		}
+	}
+
+	switch {
+	case details.StoryURL != "":
+		reply = fmt.Sprintf("Story: [%s](%s)\n\n%s", details.StoryID, details.StoryURL, reply)
+	case details.StoryID != "":
+		reply = fmt.Sprintf("Story: %s\n\n%s", details.StoryID, reply)
+	}
+	return reply + "\n", nil
+}
+
+// missingSections returns the describeSections headings description lacks
+func missingSections(description string) []string {
+	var missing []string
+	for _, section := range describeSections {
+		found := false
+		for _, line := range strings.Split(description, "\n") {
+			if strings.EqualFold(strings.TrimSpace(line), section) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, section)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,78 @@
+package gitworkflow
+
+import (
+	"strings"
+	"testing"
+)
+
+const describeReply = "## Summary\n\nAdd a login form.\n\n## Testing\n\nUnit tests for the handler.\n\n## Risks\n\nNone known."
+
+// describeRepo returns a manager for story branch W-123-add-login with two commits on top of main
+func describeRepo(generator *fakeGenerator) (*WorkflowManager, *RecordingExecutor) {
+	executor := pullRequestRepo().
+		OnOutput("git diff --stat origin/main...W-123-add-login", " login.go | 3 +++\n 1 file changed, 3 insertions(+)\n").
+		OnOutput("git diff --no-color --no-ext-diff origin/main...W-123-add-login", fileDiff("login.go", 3))
+	wm := testManager(executor, func(config *Config) {
+		config.Changelog.StoryURL = "https://tracker.example.com/browse/{id}"
+	})
+	wm.SetTextGenerator(generator)
+	return wm, executor
+}
+
+func TestDescribePullRequest(t *testing.T) {
+	generator := &fakeGenerator{replies: []string{describeReply}}
+	wm, executor := describeRepo(generator)
+
+	description, err := wm.DescribePullRequest("W-123-add-login")
+	if err != nil {
+		t.Fatalf("DescribePullRequest() unexpected error: %v", err)
+	}
+	want := "Story: [W-123](https://tracker.example.com/browse/W-123)\n\n" + describeReply + "\n"
+	if description != want {
+		t.Errorf("DescribePullRequest() = %q, want %q", description, want)
+	}
+
+	assertCommands(t, executor, []string{
+		pullRequestLogCommand,
+		"git diff --stat origin/main...W-123-add-login",
+		"git diff --no-color --no-ext-diff origin/main...W-123-add-login",
+	})
+	prompt := generator.prompts[0]
+	for _, want := range []string{"story W-123", "- feat(auth): add login form\n- fix(auth): reject empty passwords", "login.go | 3 +++", "+line 2 of login.go"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("Expected the prompt to contain %q:\n%s", want, prompt)
+		}
+	}
+}
+
+func TestDescribePullRequestRetriesMissingSections(t *testing.T) {
+	generator := &fakeGenerator{replies: []string{"## Summary\n\nAdd a login form.", describeReply}}
+	wm, _ := describeRepo(generator)
+
+	if _, err := wm.DescribePullRequest("W-123-add-login"); err != nil {
+		t.Fatalf("DescribePullRequest() unexpected error: %v", err)
+	}
+	if len(generator.prompts) != 2 || !strings.Contains(generator.prompts[1], "no ## Testing, ## Risks section") {
+		t.Errorf("Expected one retry naming the missing sections, got %d prompt(s)", len(generator.prompts))
+	}
+
+	generator = &fakeGenerator{replies: []string{"Adds a login form."}}
+	wm, _ = describeRepo(generator)
+	if _, err := wm.DescribePullRequest("W-123-add-login"); err == nil || !strings.Contains(err.Error(), "did not write a valid description") {
+		t.Fatalf("DescribePullRequest() error = %v, want invalid description", err)
+	}
+}
+
+func TestDescribePullRequestNoCommits(t *testing.T) {
+	wm := testManager(NewRecordingExecutor())
+	generator := &fakeGenerator{}
+	wm.SetTextGenerator(generator)
+
+	_, err := wm.DescribePullRequest("W-123-add-login")
+	if err == nil || !strings.Contains(err.Error(), "no commits that are not on origin/main") {
+		t.Fatalf("DescribePullRequest() error = %v, want no commits", err)
+	}
+	if len(generator.prompts) != 0 {
+		t.Error("Expected the language model not to be asked")
+	}
+}
@@ -0,0 +1,217 @@
+package gitworkflow
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PruneReason is why a branch is offered for deletion
+type PruneReason string
+
+// Reasons reported in PruneCandidate.Reason
+const (
+	PruneMerged PruneReason = "merged"
+	PruneStale  PruneReason = "stale"
+)
+
+// PruneOptions controls which branches PruneCandidates offers
+type PruneOptions struct {
+	// StaleDays also offers unmerged branches without commits for this many days;
+	// 0 uses prune.stale_days from the config
+	StaleDays int
+	// Remote also offers branches on the configured remote
+	Remote bool
+}
+
+// PruneCandidate is a branch that can be deleted
+type PruneCandidate struct {
+	// Branch is the branch name, without the remote for remote branches
+	Branch string `json:"branch"`
+	Remote bool   `json:"remote"`
+	// Commit is the branch tip, which is enough to recreate the branch
+	Commit     string      `json:"commit"`
+	LastCommit time.Time   `json:"last_commit"`
+	Reason     PruneReason `json:"reason"`
+}
+
+// Name returns the branch as git shows it, e.g. "W-1" or "origin/W-1"
+func (c PruneCandidate) Name(remote string) string {
+	if c.Remote {
+		return remote + "/" + c.Branch
+	}
+	return c.Branch
+}
+
+// PruneCandidates fetches the remote and returns the local branches, and with
+// options.Remote the remote ones, that are fully merged into the remote base
+// branch or have had no commits for the stale period, oldest first. The base
+// branch, the current branch and its remote branch, branches checked out in
+// another worktree, branches matching prune.protected and branches without
+// commits of their own, such as a story that was just started, are never offered.
+func (wm *WorkflowManager) PruneCandidates(options PruneOptions) ([]PruneCandidate, error) {
+	staleDays := options.StaleDays
+	if staleDays == 0 {
+		staleDays = wm.config.Prune.StaleDays
+	}
+	if staleDays < 0 {
+		return nil, fmt.Errorf("stale days must not be negative, got %d", staleDays)
+	}
+
+	if err := wm.fetchPrune(); err != nil {
+		return nil, err
+	}
+	baseBranch, err := wm.getDefaultBranch()
+	if err != nil {
+		return nil, err
+	}
+	current, err := wm.GetCurrentBranch()
+	if err != nil {
+		return nil, err
+	}
+	worktrees, err := wm.ListWorktrees()
+	if err != nil {
+		return nil, err
+	}
+	checkedOut := map[string]bool{}
+	for _, worktree := range worktrees {
+		checkedOut[worktree.Branch] = true
+	}
+
+	patterns := []string{"refs/heads"}
+	if options.Remote {
+		patterns = append(patterns, "refs/remotes/"+wm.config.Remote)
+	}
+	merged, err := wm.gitOutput(append([]string{"for-each-ref", "--merged=" + wm.remoteRef(baseBranch), "--format=%(refname)"}, patterns...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branches merged into %s: %w", wm.remoteRef(baseBranch), err)
+	}
+	isMerged := map[string]bool{}
+	for _, refname := range strings.Split(merged, "\n") {
+		isMerged[refname] = true
+	}
+
+	format := "--format=%(refname)" + logFieldSeparator + "%(objectname)" + logFieldSeparator + "%(committerdate:unix)"
+	output, err := wm.gitOutput(append([]string{"for-each-ref", format}, patterns...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branches: %w", err)
+	}
+
+	staleBefore := wm.now().AddDate(0, 0, -staleDays)
+	remotePrefix := "refs/remotes/" + wm.config.Remote + "/"
+	var history map[string]bool
+	var candidates []PruneCandidate
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, logFieldSeparator)
+		if len(fields) != 3 {
+			continue
+		}
+		candidate := PruneCandidate{Commit: fields[1]}
+		var local bool
+		if candidate.Branch, local = strings.CutPrefix(fields[0], "refs/heads/"); !local {
+			candidate.Branch, candidate.Remote = strings.TrimPrefix(fields[0], remotePrefix), true
+		}
+		if seconds, err := strconv.ParseInt(fields[2], 10, 64); err == nil {
+			candidate.LastCommit = time.Unix(seconds, 0)
+		}
+
+		switch {
+		case candidate.Branch == baseBranch || candidate.Branch == "HEAD" || wm.isProtected(candidate.Branch):
+			continue
+		case candidate.Branch == current:
+			continue
+		case !candidate.Remote && checkedOut[candidate.Branch]:
+			// git refuses to delete a branch checked out in another worktree
+			continue
+		case isMerged[fields[0]]:
+			if history == nil {
+				if history, err = wm.baseHistory(wm.remoteRef(baseBranch)); err != nil {
+					return nil, err
+				}
+			}
+			own, err := wm.hasOwnCommits(candidate.Branch, []string{candidate.Commit}, candidate.Remote, history)
+			if err != nil {
+				return nil, err
+			}
+			if !own {
+				continue
+			}
+			candidate.Reason = PruneMerged
+		case staleDays > 0 && candidate.LastCommit.Before(staleBefore):
+			candidate.Reason = PruneStale
+		default:
+			continue
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].LastCommit.Before(candidates[j].LastCommit)
+	})
+	return candidates, nil
+}
+
+// PruneFailure is a branch DeleteBranches could not delete
+type PruneFailure struct {
+	Candidate PruneCandidate
+	Err       error
+}
+
+// PruneError reports the branches DeleteBranches could not delete
+type PruneError struct {
+	Failures []PruneFailure
+	// Deleted counts the branches that were deleted
+	Deleted int
+}
+
+// Error names each branch that was not deleted and why
+func (e *PruneError) Error() string {
+	var failures []string
+	for _, failure := range e.Failures {
+		failures = append(failures, failure.Err.Error())
+	}
+	return fmt.Sprintf("%d of %d branch(es) not deleted: %s", len(e.Failures), len(e.Failures)+e.Deleted, strings.Join(failures, "; "))
+}
+
+// DeleteBranches deletes the local and remote branches of candidates and
+// returns those it deleted. Local branches are deleted even if git does not
+// consider them merged, so only pass branches PruneCandidates returned and the
+// user agreed to delete. Every branch is attempted; if any could not be
+// deleted a *PruneError is returned along with the deleted ones.
+func (wm *WorkflowManager) DeleteBranches(candidates []PruneCandidate) ([]PruneCandidate, error) {
+	var deleted []PruneCandidate
+	pruneErr := &PruneError{}
+	for _, candidate := range candidates {
+		var err error
+		if candidate.Remote {
+			if _, err = wm.git("push", wm.config.Remote, "--delete", candidate.Branch); err != nil {
+				err = fmt.Errorf("failed to delete %s: %w", candidate.Name(wm.config.Remote), err)
+			}
+		} else if _, err = wm.git("branch", "-D", candidate.Branch); err != nil {
+			err = fmt.Errorf("failed to delete %s: %w", candidate.Branch, err)
+		}
+		if err != nil {
+			pruneErr.Failures = append(pruneErr.Failures, PruneFailure{Candidate: candidate, Err: err})
+			continue
+		}
+		deleted = append(deleted, candidate)
+	}
+
+	if len(pruneErr.Failures) > 0 {
+		pruneErr.Deleted = len(deleted)
+		return deleted, pruneErr
+	}
+	return deleted, nil
+}
+
+// isProtected reports whether branch matches one of the prune.protected patterns
+func (wm *WorkflowManager) isProtected(branch string) bool {
+	for _, pattern := range wm.config.Prune.Protected {
+		if globPattern(pattern).MatchString(branch) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,195 @@
+package gitworkflow
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const (
+	mergedCommand      = "git for-each-ref --merged=origin/main --format=%(refname) refs/heads refs/remotes/origin"
+	pruneBranchCommand = "git for-each-ref --format=%(refname)\x1f%(objectname)\x1f%(committerdate:unix) refs/heads refs/remotes/origin"
+)
+
+// pruneRecord formats one branch as listed by the for-each-ref in PruneCandidates
+func pruneRecord(refname, commit string, daysAgo int) string {
+	return refname + "\x1f" + commit + "\x1f" + strconv.FormatInt(fixedNow().AddDate(0, 0, -daysAgo).Unix(), 10)
+}
+
+// pruneRepo returns a manager on W-5 with local branches main, W-1 (merged),
+// W-2 (100 days old), W-3 (10 days old), W-5 (merged, current), W-6 (just
+// started on main, without commits), W-7 (merged, checked out in another
+// worktree) and release/1.0 (merged), and remote branches origin/HEAD,
+// origin/main, origin/W-4 (merged) and origin/W-5 (merged)
+func pruneRepo() (*WorkflowManager, *RecordingExecutor) {
+	executor := NewRecordingExecutor().
+		OnOutput("git rev-parse --abbrev-ref HEAD", "W-5\n").
+		OnOutput(mergedCommand, strings.Join([]string{
+			"refs/heads/main", "refs/heads/W-1", "refs/heads/W-5", "refs/heads/W-6", "refs/heads/W-7", "refs/heads/release/1.0",
+			"refs/remotes/origin/HEAD", "refs/remotes/origin/main", "refs/remotes/origin/W-4", "refs/remotes/origin/W-5",
+		}, "\n")).
+		OnOutput(pruneBranchCommand, strings.Join([]string{
+			pruneRecord("refs/heads/main", "aaaa", 1),
+			pruneRecord("refs/heads/W-1", "bbbb", 20),
+			pruneRecord("refs/heads/W-2", "cccc", 100),
+			pruneRecord("refs/heads/W-3", "dddd", 10),
+			pruneRecord("refs/heads/W-5", "eeee", 50),
+			pruneRecord("refs/heads/W-6", "aaaa", 1),
+			pruneRecord("refs/heads/W-7", "7777", 30),
+			pruneRecord("refs/heads/release/1.0", "ffff", 200),
+			pruneRecord("refs/remotes/origin/HEAD", "aaaa", 1),
+			pruneRecord("refs/remotes/origin/main", "aaaa", 1),
+			pruneRecord("refs/remotes/origin/W-4", "9999", 5),
+			pruneRecord("refs/remotes/origin/W-5", "eeee", 50),
+		}, "\n")).
+		OnOutput("git rev-list --first-parent origin/main", "aaaa\n").
+		OnOutput("git config --get branch.W-6.vamosBase", "aaaa\n").
+		OnOutput(worktreeListCommand, "worktree /src/app\nHEAD eeee\nbranch refs/heads/W-5\n\n"+
+			"worktree /src/app-W-7\nHEAD 7777\nbranch refs/heads/W-7\n").
+		OnOutput("git rev-parse --show-toplevel", "/src/app\n")
+	return testManager(executor), executor
+}
+
+func TestPruneCandidates(t *testing.T) {
+	tests := []struct {
+		name    string
+		options PruneOptions
+		stale   int
+		want    []string
+	}{
+		{name: "merged", options: PruneOptions{Remote: true}, want: []string{"W-1 merged", "origin/W-4 merged"}},
+		{name: "stale", options: PruneOptions{Remote: true, StaleDays: 30}, want: []string{"W-2 stale", "W-1 merged", "origin/W-4 merged"}},
+		{name: "stale from config", options: PruneOptions{Remote: true}, stale: 5, want: []string{"W-2 stale", "W-1 merged", "W-3 stale", "origin/W-4 merged"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wm, _ := pruneRepo()
+			wm.config.Prune.StaleDays = tt.stale
+			candidates, err := wm.PruneCandidates(tt.options)
+			if err != nil {
+				t.Fatalf("PruneCandidates() unexpected error: %v", err)
+			}
+			var got []string
+			for _, candidate := range candidates {
+				got = append(got, candidate.Name("origin")+" "+string(candidate.Reason))
+			}
+			if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+				t.Errorf("PruneCandidates() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPruneCandidatesLocalOnly(t *testing.T) {
+	executor := NewRecordingExecutor().
+		OnOutput("git rev-parse --abbrev-ref HEAD", "main\n")
+	if _, err := testManager(executor).PruneCandidates(PruneOptions{}); err != nil {
+		t.Fatalf("PruneCandidates() unexpected error: %v", err)
+	}
+	assertCommands(t, executor, []string{
+		"git fetch --prune origin",
+		"git rev-parse --abbrev-ref HEAD",
+		worktreeListCommand,
+		"git rev-parse --show-toplevel",
+		"git for-each-ref --merged=origin/main --format=%(refname) refs/heads",
+		"git for-each-ref --format=%(refname)\x1f%(objectname)\x1f%(committerdate:unix) refs/heads",
+	})
+}
+
+func TestPruneCandidatesProtected(t *testing.T) {
+	wm, _ := pruneRepo()
+	wm.config.Prune.Protected = []string{"W-1", "release/*"}
+	candidates, err := wm.PruneCandidates(PruneOptions{Remote: true})
+	if err != nil {
+		t.Fatalf("PruneCandidates() unexpected error: %v", err)
+	}
+	if len(candidates) != 1 || candidates[0].Branch != "W-4" {
+		t.Errorf("Expected only W-4 to be offered, got %+v", candidates)
+	}
+
+	// Without any protected patterns the merged release branch is offered
+	wm, _ = pruneRepo()
+	wm.config.Prune.Protected = []string{}
+	candidates, _ = wm.PruneCandidates(PruneOptions{Remote: true})
+	if len(candidates) != 3 || candidates[0].Branch != "release/1.0" {
+		t.Errorf("Expected release/1.0, W-1 and W-4 to be offered, got %+v", candidates)
+	}
+}
+
+func TestDeleteBranches(t *testing.T) {
+	executor := NewRecordingExecutor()
+	wm := NewWorkflowManagerWithExecutor(executor)
+	deleted, err := wm.DeleteBranches([]PruneCandidate{
+		{Branch: "W-1"}, {Branch: "W-4", Remote: true}, {Branch: "W-2"},
+	})
+	if err != nil || len(deleted) != 3 {
+		t.Fatalf("DeleteBranches() = %v, %v, want all 3 deleted", deleted, err)
+	}
+	assertCommands(t, executor, []string{
+		"git branch -D W-1",
+		"git push origin --delete W-4",
+		"git branch -D W-2",
+	})
+}
+
+func TestDeleteBranchesReportsFailures(t *testing.T) {
+	executor := NewRecordingExecutor().
+		OnFailure("git branch -D W-1", 1, "error: Cannot delete branch 'W-1' checked out at '/src/app-W-1'")
+	wm := NewWorkflowManagerWithExecutor(executor)
+	deleted, err := wm.DeleteBranches([]PruneCandidate{{Branch: "W-1"}, {Branch: "W-2"}, {Branch: "W-4", Remote: true}})
+
+	var pruneErr *PruneError
+	if !errors.As(err, &pruneErr) || len(pruneErr.Failures) != 1 || pruneErr.Failures[0].Candidate.Branch != "W-1" {
+		t.Fatalf("DeleteBranches() error = %v, want W-1 to fail", err)
+	}
+	if !strings.Contains(err.Error(), "1 of 3 branch(es) not deleted") || !strings.Contains(err.Error(), "checked out at") {
+		t.Errorf("Unexpected error %q", err)
+	}
+	if len(deleted) != 2 {
+		t.Errorf("Expected W-2 and W-4 to be deleted, got %+v", deleted)
+	}
+	assertCommands(t, executor, []string{"git branch -D W-1", "git branch -D W-2", "git push origin --delete W-4"})
+}
+
+func TestPruneInRepo(t *testing.T) {
+	repo := newGitRepo(t)
+	wm := repo.manager()
+
+	// W-1 is merged, W-2 was just started, W-3 is merged and checked out in another worktree
+	for _, story := range []string{"1", "3"} {
+		if _, err := wm.StartStory(story, "", StartOptions{}); err != nil {
+			t.Fatalf("StartStory() unexpected error: %v", err)
+		}
+		repo.commit("W-"+story+".go", "feat: story "+story)
+		repo.git("push", "--quiet", "-u", "origin", "W-"+story)
+		repo.git("checkout", "--quiet", "main")
+		repo.git("merge", "--quiet", "--ff-only", "W-"+story)
+		repo.git("push", "--quiet", "origin", "main")
+	}
+	if _, err := wm.StartStory("2", "", StartOptions{}); err != nil {
+		t.Fatalf("StartStory() unexpected error: %v", err)
+	}
+	repo.git("checkout", "--quiet", "main")
+	repo.git("worktree", "add", "--quiet", repo.dir+"-W-3", "W-3")
+
+	candidates, err := wm.PruneCandidates(PruneOptions{Remote: true})
+	if err != nil {
+		t.Fatalf("PruneCandidates() unexpected error: %v", err)
+	}
+	var got []string
+	for _, candidate := range candidates {
+		got = append(got, candidate.Name("origin"))
+	}
+	if strings.Join(got, " ") != "W-1 origin/W-1 origin/W-3" {
+		t.Fatalf("PruneCandidates() = %v, want W-1, origin/W-1 and origin/W-3", got)
+	}
+
+	if _, err := wm.DeleteBranches(candidates); err != nil {
+		t.Fatalf("DeleteBranches() unexpected error: %v", err)
+	}
+	if branches := repo.git("branch", "--all", "--format=%(refname:short)"); branches != "W-2\nW-3\nmain\norigin/main" {
+		t.Errorf("Unexpected branches left:\n%s", branches)
+	}
+}
@@ -0,0 +1,313 @@
+package gitworkflow
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultPullRequestBody is the body template used when pull_request.body_template is not set
+const DefaultPullRequestBody = `{{if .StoryID}}Story: {{if .StoryURL}}[{{.StoryID}}]({{.StoryURL}}){{else}}{{.StoryID}}{{end}}
+
+{{end}}## Changes
+
+{{range .Commits}}- {{.Subject}} ({{.ShortHash}})
+{{end}}`
+
+// PullRequestClientInterface defines the interface for opening pull requests on GitHub
+type PullRequestClientInterface interface {
+	CreatePullRequest(ctx context.Context, owner, repo string, pr NewPullRequest) (*PullRequest, error)
+	// FindPullRequest returns the open pull request from head, given as
+	// "owner:branch", or nil if there is none
+	FindPullRequest(ctx context.Context, owner, repo, head string) (*PullRequest, error)
+	RequestReviewers(ctx context.Context, owner, repo string, number int, reviewers []string) error
+	AddLabels(ctx context.Context, owner, repo string, number int, labels []string) error
+}
+
+// PullRequestOptions controls the pull request CreatePullRequest opens. Unset
+// fields fall back to the pull_request settings in the config.
+type PullRequestOptions struct {
+	// Title defaults to the story ID and the description from the branch name
+	Title string
+	// Body defaults to the body template rendered with PullRequestDetails
+	Body string
+	// Draft opens the pull request as a draft; pull_request.draft in the config does the same
+	Draft     bool
+	Reviewers []string
+	Labels    []string
+}
+
+// PullRequestDetails is what the pull request body template is rendered with
+type PullRequestDetails struct {
+	StoryID  string
+	StoryURL string
+	Branch   string
+	Base     string
+	// Commits are the commits on Branch that are not on Base, oldest first
+	Commits []Commit
+}
+
+// NewPullRequest holds the fields of a pull request to open. Head is the
+// branch, or "owner:branch" when it lives in a fork.
+type NewPullRequest struct {
+	Title string
+	Head  string
+	Base  string
+	Body  string
+	Draft bool
+}
+
+// PullRequest is a pull request opened or found by CreatePullRequest
+type PullRequest struct {
+	Number int    `json:"number"`
+	URL    string `json:"url"`
+	Title  string `json:"title"`
+	Base   string `json:"base"`
+	Draft  bool   `json:"draft"`
+	// Existing is true when an open pull request for the branch was found instead of opened
+	Existing bool `json:"existing"`
+}
+
+// SetPullRequestClient sets the client CreatePullRequest opens pull requests with
+func (wm *WorkflowManager) SetPullRequestClient(client PullRequestClientInterface) {
+	wm.pullRequests = client
+}
+
+// ShortHash returns the abbreviated commit hash
+func (c Commit) ShortHash() string {
+	return ShortHash(c.Hash)
+}
+
+// CreatePullRequest pushes branchName and opens a pull request for it against
+// the base branch. If the branch already has an open pull request that one is
+// returned instead. The pull request is opened in the repository of
+// pull_request.base_remote, e.g. upstream when the remote is a fork.
+func (wm *WorkflowManager) CreatePullRequest(branchName string, options PullRequestOptions) (*PullRequest, error) {
+	if wm.pullRequests == nil && !wm.dryRun {
+		return nil, fmt.Errorf("no GitHub client configured: set GITHUB_TOKEN or github.token in %s", ConfigFileName)
+	}
+
+	// Ensure we're on the feature branch
+	if err := wm.checkoutBranch(branchName); err != nil {
+		return nil, fmt.Errorf("failed to checkout feature branch: %w", err)
+	}
+
+	// Push the branch to remote
+	if _, err := wm.git("push", "-u", wm.config.Remote, branchName); err != nil {
+		return nil, fmt.Errorf("failed to push branch: %w", err)
+	}
+
+	baseBranch, err := wm.getDefaultBranch()
+	if err != nil {
+		return nil, err
+	}
+	baseRemote := wm.config.PullRequest.BaseRemote
+	if baseRemote == "" {
+		baseRemote = wm.config.Remote
+	}
+	owner, repo, err := wm.remoteRepository(baseRemote)
+	if err != nil {
+		return nil, err
+	}
+	// The branch was pushed to the remote, which may be a fork of the base repository
+	headOwner, _, err := wm.remoteRepository(wm.config.Remote, "--push")
+	if err != nil {
+		return nil, err
+	}
+	head := branchName
+	if headOwner != owner {
+		head = headOwner + ":" + branchName
+	}
+
+	details, err := wm.PullRequestDetails(branchName, baseBranch)
+	if err != nil {
+		return nil, err
+	}
+	request := NewPullRequest{
+		Title: options.Title,
+		Head:  head,
+		Base:  baseBranch,
+		Body:  options.Body,
+		Draft: options.Draft || wm.config.PullRequest.Draft,
+	}
+	if request.Title == "" {
+		request.Title = wm.PullRequestTitle(details)
+	}
+	if request.Body == "" {
+		if request.Body, err = wm.renderPullRequestBody(details); err != nil {
+			return nil, err
+		}
+	}
+	reviewers := options.Reviewers
+	if len(reviewers) == 0 {
+		reviewers = wm.config.PullRequest.Reviewers
+	}
+	labels := options.Labels
+	if len(labels) == 0 {
+		labels = wm.config.PullRequest.Labels
+	}
+
+	result := &PullRequest{Title: request.Title, Base: baseBranch, Draft: request.Draft}
+	if wm.dryRun {
+		wm.logf("would open a pull request in %s/%s from %s into %s titled %q", owner, repo, head, baseBranch, request.Title)
+		return result, nil
+	}
+
+	ctx := context.Background()
+	existing, err := wm.pullRequests.FindPullRequest(ctx, owner, repo, headOwner+":"+branchName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look for an existing pull request: %w", err)
+	}
+	if existing != nil {
+		wm.logf("%s already has an open pull request", branchName)
+		existing.Base, existing.Existing = baseBranch, true
+		return existing, nil
+	}
+
+	created, err := wm.pullRequests.CreatePullRequest(ctx, owner, repo, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pull request: %w", err)
+	}
+	result.Number, result.URL = created.Number, created.URL
+
+	if len(reviewers) > 0 {
+		if err := wm.pullRequests.RequestReviewers(ctx, owner, repo, created.Number, reviewers); err != nil {
+			return result, fmt.Errorf("opened %s but failed to request reviewers: %w", created.URL, err)
+		}
+	}
+	if len(labels) > 0 {
+		if err := wm.pullRequests.AddLabels(ctx, owner, repo, created.Number, labels); err != nil {
+			return result, fmt.Errorf("opened %s but failed to add labels: %w", created.URL, err)
+		}
+	}
+	return result, nil
+}
+
+// PullRequestDetails collects what a pull request from branch into baseBranch is about
+func (wm *WorkflowManager) PullRequestDetails(branch, baseBranch string) (*PullRequestDetails, error) {
+	commits, err := wm.CommitsBetween(wm.remoteRef(baseBranch), branch)
+	if err != nil {
+		return nil, err
+	}
+	// CommitsBetween lists the newest first; a pull request reads better oldest first
+	for i, j := 0, len(commits)-1; i < j; i, j = i+1, j-1 {
+		commits[i], commits[j] = commits[j], commits[i]
+	}
+
+	details := &PullRequestDetails{Branch: branch, Base: baseBranch, Commits: commits}
+	if storyID, ok := wm.StoryID(branch); ok {
+		details.StoryID = storyID
+		if storyURL := wm.config.Changelog.StoryURL; storyURL != "" {
+			details.StoryURL = strings.ReplaceAll(storyURL, placeholderID, storyID)
+		}
+	}
+	return details, nil
+}
+
+// PullRequestTitle builds a title from the story ID and the description in the
+// branch name, e.g. "W-123: Add login" for W-123-add-login. When the branch
+// has no description the first commit's description is used.
+func (wm *WorkflowManager) PullRequestTitle(details *PullRequestDetails) string {
+	description := details.Branch
+	if details.StoryID != "" {
+		description = strings.TrimPrefix(description, wm.branchIDPrefix())
+		description = strings.TrimPrefix(description, strings.TrimPrefix(details.StoryID, wm.config.Branch.Prefix))
+	}
+	description = strings.Join(strings.FieldsFunc(description, func(r rune) bool {
+		return r == '-' || r == '_' || r == '/' || r == '.'
+	}), " ")
+
+	if description == "" && len(details.Commits) > 0 {
+		first := details.Commits[0]
+		description = first.Subject
+		if first.Conventional {
+			description = first.Message.Description
+		}
+	}
+	description = capitalize(description)
+
+	switch {
+	case details.StoryID == "":
+		return description
+	case description == "":
+		return details.StoryID
+	default:
+		return details.StoryID + ": " + description
+	}
+}
+
+// renderPullRequestBody renders the configured body template with details
+func (wm *WorkflowManager) renderPullRequestBody(details *PullRequestDetails) (string, error) {
+	text := wm.config.PullRequest.BodyTemplate
+	if text == "" {
+		text = DefaultPullRequestBody
+	}
+	tmpl, err := parseBodyTemplate(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid pull_request.body_template: %w", err)
+	}
+
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, details); err != nil {
+		return "", fmt.Errorf("failed to render pull request body: %w", err)
+	}
+	return strings.TrimSpace(body.String()) + "\n", nil
+}
+
+// parseBodyTemplate parses a pull request body template
+func parseBodyTemplate(text string) (*template.Template, error) {
+	return template.New("body").Parse(text)
+}
+
+// remoteRepository returns the GitHub owner and repository name of remote.
+// Extra flags are passed to git remote get-url, e.g. --push for the push URL.
+func (wm *WorkflowManager) remoteRepository(remote string, flags ...string) (string, string, error) {
+	remoteURL, err := wm.gitOutput(append(append([]string{"remote", "get-url"}, flags...), remote)...)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read the URL of %s: %w", remote, err)
+	}
+	owner, repo, ok := parseRemoteURL(remoteURL)
+	if !ok {
+		return "", "", fmt.Errorf("cannot tell the GitHub repository from %s URL %q", remote, remoteURL)
+	}
+	return owner, repo, nil
+}
+
+// parseRemoteURL extracts owner and repository from a remote URL such as
+// git@github.com:owner/repo.git, https://github.com/owner/repo or ssh://git@host/owner/repo.git
+func parseRemoteURL(remoteURL string) (string, string, bool) {
+	path := strings.TrimSuffix(strings.TrimSuffix(remoteURL, "/"), ".git")
+	if scheme := strings.Index(path, "://"); scheme >= 0 {
+		// Drop the scheme and host
+		rest := path[scheme+3:]
+		slash := strings.Index(rest, "/")
+		if slash < 0 {
+			return "", "", false
+		}
+		path = rest[slash+1:]
+	} else if colon := strings.Index(path, ":"); colon >= 0 {
+		// scp-like syntax: [user@]host:owner/repo
+		path = path[colon+1:]
+	} else {
+		return "", "", false
+	}
+
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 2 || parts[len(parts)-2] == "" || parts[len(parts)-1] == "" {
+		return "", "", false
+	}
+	return parts[len(parts)-2], parts[len(parts)-1], true
+}
+
+// capitalize upper-cases the first letter of s
+func capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if r == utf8.RuneError {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + s[size:]
+}
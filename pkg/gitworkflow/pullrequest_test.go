@@ -0,0 +1,207 @@
+package gitworkflow
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const pullRequestLogCommand = "git log --format=%H\x1f%B\x1e origin/main..W-123-add-login"
+
+// githubStandIn serves the pull request endpoints of the GitHub API. Requests
+// are recorded by method and path with their decoded JSON bodies.
+type githubStandIn struct {
+	server   *httptest.Server
+	existing string
+	// head is the head the last lookup of an existing pull request asked for
+	head     string
+	requests map[string]map[string]interface{}
+}
+
+func newGitHubStandIn(t *testing.T) *githubStandIn {
+	t.Helper()
+	standIn := &githubStandIn{existing: "[]", requests: map[string]map[string]interface{}{}}
+	standIn.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message":"Bad credentials"}`))
+			return
+		}
+
+		key := r.Method + " " + r.URL.Path
+		body := map[string]interface{}{}
+		if r.Method == http.MethodPost {
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("%s: invalid JSON body: %v", key, err)
+			}
+		}
+		standIn.requests[key] = body
+
+		switch key {
+		case "GET /repos/acme/widgets/pulls":
+			standIn.head = r.URL.Query().Get("head")
+			w.Write([]byte(standIn.existing))
+		case "POST /repos/acme/widgets/pulls":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"number":42,"html_url":"https://github.com/acme/widgets/pull/42","draft":true}`))
+		default:
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{}`))
+		}
+	}))
+	t.Cleanup(standIn.server.Close)
+	return standIn
+}
+
+// pullRequestRepo returns an executor for story branch W-123-add-login with two commits on top of main
+func pullRequestRepo() *RecordingExecutor {
+	return NewRecordingExecutor().
+		OnOutput("git remote get-url origin", "git@github.com:acme/widgets.git\n").
+		OnOutput("git remote get-url --push origin", "git@github.com:acme/widgets.git\n").
+		OnOutput(pullRequestLogCommand,
+			logRecord("bbbbbbb2222", "fix(auth): reject empty passwords")+
+				logRecord("aaaaaaa1111", "feat(auth): add login form"))
+}
+
+func TestCreatePullRequest(t *testing.T) {
+	standIn := newGitHubStandIn(t)
+	executor := pullRequestRepo()
+	wm := testManager(executor, func(config *Config) {
+		config.Changelog.StoryURL = "https://tracker.example.com/browse/{id}"
+		config.PullRequest.Labels = []string{"story"}
+	})
+	wm.SetPullRequestClient(NewGitHubClient(standIn.server.URL, "secret"))
+
+	pr, err := wm.CreatePullRequest("W-123-add-login", PullRequestOptions{Draft: true, Reviewers: []string{"alice", "acme/platform"}})
+	if err != nil {
+		t.Fatalf("CreatePullRequest() unexpected error: %v", err)
+	}
+	if pr.Number != 42 || pr.URL != "https://github.com/acme/widgets/pull/42" || pr.Existing {
+		t.Errorf("Unexpected pull request: %+v", pr)
+	}
+
+	assertCommands(t, executor, []string{
+		"git checkout W-123-add-login",
+		"git push -u origin W-123-add-login",
+		"git remote get-url origin",
+		"git remote get-url --push origin",
+		pullRequestLogCommand,
+	})
+
+	created := standIn.requests["POST /repos/acme/widgets/pulls"]
+	wantBody := "Story: [W-123](https://tracker.example.com/browse/W-123)\n\n## Changes\n\n" +
+		"- feat(auth): add login form (aaaaaaa)\n- fix(auth): reject empty passwords (bbbbbbb)\n"
+	if created["title"] != "W-123: Add login" || created["head"] != "W-123-add-login" ||
+		created["base"] != "main" || created["draft"] != true || created["body"] != wantBody {
+		t.Errorf("Unexpected pull request request: %#v", created)
+	}
+	if reviewers := standIn.requests["POST /repos/acme/widgets/pulls/42/requested_reviewers"]; len(reviewers) != 2 {
+		t.Errorf("Expected users and teams to be requested, got %#v", reviewers)
+	}
+	if labels, ok := standIn.requests["POST /repos/acme/widgets/issues/42/labels"]; !ok || len(labels["labels"].([]interface{})) != 1 {
+		t.Errorf("Expected the configured label to be added, got %#v", labels)
+	}
+}
+
+func TestCreatePullRequestExisting(t *testing.T) {
+	standIn := newGitHubStandIn(t)
+	standIn.existing = `[{"number":7,"title":"W-123: Add login","html_url":"https://github.com/acme/widgets/pull/7"}]`
+	wm := testManager(pullRequestRepo())
+	wm.SetPullRequestClient(NewGitHubClient(standIn.server.URL, "secret"))
+
+	pr, err := wm.CreatePullRequest("W-123-add-login", PullRequestOptions{})
+	if err != nil {
+		t.Fatalf("CreatePullRequest() unexpected error: %v", err)
+	}
+	if !pr.Existing || pr.URL != "https://github.com/acme/widgets/pull/7" {
+		t.Errorf("Expected the existing pull request, got %+v", pr)
+	}
+	if _, created := standIn.requests["POST /repos/acme/widgets/pulls"]; created {
+		t.Error("Expected no pull request to be opened")
+	}
+}
+
+func TestCreatePullRequestFromFork(t *testing.T) {
+	standIn := newGitHubStandIn(t)
+	executor := NewRecordingExecutor().
+		OnOutput("git remote get-url upstream", "git@github.com:acme/widgets.git\n").
+		OnOutput("git remote get-url --push origin", "https://github.com/alice/widgets.git\n")
+	wm := testManager(executor, func(config *Config) { config.PullRequest.BaseRemote = "upstream" })
+	wm.SetPullRequestClient(NewGitHubClient(standIn.server.URL, "secret"))
+
+	if _, err := wm.CreatePullRequest("W-123-add-login", PullRequestOptions{}); err != nil {
+		t.Fatalf("CreatePullRequest() unexpected error: %v", err)
+	}
+	if standIn.head != "alice:W-123-add-login" {
+		t.Errorf("Expected the fork's branch to be looked up, got head %q", standIn.head)
+	}
+	if created := standIn.requests["POST /repos/acme/widgets/pulls"]; created["head"] != "alice:W-123-add-login" {
+		t.Errorf("Expected the pull request to be opened from the fork, got %#v", created)
+	}
+}
+
+func TestCreatePullRequestAPIError(t *testing.T) {
+	standIn := newGitHubStandIn(t)
+	wm := testManager(pullRequestRepo())
+	wm.SetPullRequestClient(NewGitHubClient(standIn.server.URL, "wrong"))
+
+	_, err := wm.CreatePullRequest("W-123-add-login", PullRequestOptions{})
+	if err == nil || !strings.Contains(err.Error(), "401: Bad credentials") {
+		t.Fatalf("CreatePullRequest() error = %v, want bad credentials", err)
+	}
+}
+
+func TestCreatePullRequestWithoutClient(t *testing.T) {
+	executor := NewRecordingExecutor()
+	_, err := NewWorkflowManagerWithExecutor(executor).CreatePullRequest("W-123-add-login", PullRequestOptions{})
+	if err == nil || !strings.Contains(err.Error(), "GITHUB_TOKEN") {
+		t.Fatalf("CreatePullRequest() error = %v, want missing token", err)
+	}
+	assertCommands(t, executor, nil)
+}
+
+func TestPullRequestTitle(t *testing.T) {
+	wm := NewWorkflowManagerWithExecutor(NewRecordingExecutor())
+	first := Commit{Hash: "aaaaaaa1111", Subject: "feat(auth): add login form", Conventional: true,
+		Message: CommitMessage{Type: "feat", Scope: "auth", Description: "add login form"}}
+
+	tests := []struct {
+		details PullRequestDetails
+		want    string
+	}{
+		{PullRequestDetails{Branch: "W-123-add-login", StoryID: "W-123"}, "W-123: Add login"},
+		{PullRequestDetails{Branch: "W-123", StoryID: "W-123", Commits: []Commit{first}}, "W-123: Add login form"},
+		{PullRequestDetails{Branch: "W-123", StoryID: "W-123"}, "W-123"},
+		{PullRequestDetails{Branch: "hotfix/broken_build"}, "Hotfix broken build"},
+	}
+
+	for _, tt := range tests {
+		if got := wm.PullRequestTitle(&tt.details); got != tt.want {
+			t.Errorf("PullRequestTitle(%s) = %q, want %q", tt.details.Branch, got, tt.want)
+		}
+	}
+}
+
+func TestParseRemoteURL(t *testing.T) {
+	tests := []struct {
+		url         string
+		owner, repo string
+		ok          bool
+	}{
+		{"git@github.com:acme/widgets.git", "acme", "widgets", true},
+		{"https://github.com/acme/widgets", "acme", "widgets", true},
+		{"https://github.com/acme/widgets.git/", "acme", "widgets", true},
+		{"ssh://git@github.example.com:2222/acme/widgets.git", "acme", "widgets", true},
+		{"/srv/git/widgets.git", "", "", false},
+		{"https://github.com/widgets", "", "", false},
+	}
+
+	for _, tt := range tests {
+		owner, repo, ok := parseRemoteURL(tt.url)
+		if owner != tt.owner || repo != tt.repo || ok != tt.ok {
+			t.Errorf("parseRemoteURL(%q) = %q, %q, %v, want %q, %q, %v", tt.url, owner, repo, ok, tt.owner, tt.repo, tt.ok)
+		}
+	}
+}
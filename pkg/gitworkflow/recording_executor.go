@@ -0,0 +1,57 @@
+package gitworkflow
+
+// RecordingExecutor is a fake CommandExecutorInterface that records every
+// command it is asked to run and replays canned results instead of running them
+type RecordingExecutor struct {
+	// Commands holds each executed command line in call order, e.g. "git fetch origin"
+	Commands  []string
+	responses map[string][]CommandResult
+}
+
+// NewRecordingExecutor creates a new RecordingExecutor with no canned results
+func NewRecordingExecutor() *RecordingExecutor {
+	return &RecordingExecutor{
+		responses: make(map[string][]CommandResult),
+	}
+}
+
+// On registers the result returned for an exact command line. Registering the
+// same command more than once queues the results; the last one keeps repeating.
+// Commands without a registered result succeed with empty output.
+func (r *RecordingExecutor) On(command string, result CommandResult) *RecordingExecutor {
+	r.responses[command] = append(r.responses[command], result)
+	return r
+}
+
+// OnOutput registers a successful result with the given stdout
+func (r *RecordingExecutor) OnOutput(command, stdout string) *RecordingExecutor {
+	return r.On(command, CommandResult{Stdout: stdout})
+}
+
+// OnFailure registers a failing result with the given exit code and stderr
+func (r *RecordingExecutor) OnFailure(command string, exitCode int, stderr string) *RecordingExecutor {
+	return r.On(command, CommandResult{Stderr: stderr, ExitCode: exitCode})
+}
+
+// Execute records the command and returns its canned result
+func (r *RecordingExecutor) Execute(name string, args ...string) (CommandResult, error) {
+	command := formatCommand(name, args)
+	r.Commands = append(r.Commands, command)
+
+	var result CommandResult
+	if queue := r.responses[command]; len(queue) > 0 {
+		result = queue[0]
+		if len(queue) > 1 {
+			r.responses[command] = queue[1:]
+		}
+	}
+
+	if result.ExitCode != 0 {
+		return result, &ExitError{
+			Command:  command,
+			ExitCode: result.ExitCode,
+			Stderr:   result.Stderr,
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,335 @@
+package gitworkflow
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	versionPattern    = regexp.MustCompile(`^(v?)(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(?:-([0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*))?(?:\+([0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*))?$`)
+	identifierSegment = regexp.MustCompile(`^[0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*$`)
+)
+
+// Version is a semantic version as used in release tags, e.g. v1.4.0-rc.1+build.7
+type Version struct {
+	// Prefix is "v" or empty, preserved from the tag the version was parsed from
+	Prefix     string
+	Major      int
+	Minor      int
+	Patch      int
+	PreRelease string
+	Build      string
+}
+
+// ParseVersion parses a semantic version with an optional "v" prefix
+func ParseVersion(value string) (Version, error) {
+	matches := versionPattern.FindStringSubmatch(value)
+	if matches == nil {
+		return Version{}, fmt.Errorf("%q is not a semantic version", value)
+	}
+	major, _ := strconv.Atoi(matches[2])
+	minor, _ := strconv.Atoi(matches[3])
+	patch, _ := strconv.Atoi(matches[4])
+	return Version{
+		Prefix:     matches[1],
+		Major:      major,
+		Minor:      minor,
+		Patch:      patch,
+		PreRelease: matches[5],
+		Build:      matches[6],
+	}, nil
+}
+
+// String renders the version including its prefix, pre-release and build metadata
+func (v Version) String() string {
+	version := fmt.Sprintf("%s%d.%d.%d", v.Prefix, v.Major, v.Minor, v.Patch)
+	if v.PreRelease != "" {
+		version += "-" + v.PreRelease
+	}
+	if v.Build != "" {
+		version += "+" + v.Build
+	}
+	return version
+}
+
+// MarshalText writes the version as it is tagged, e.g. v1.4.0-rc.1
+func (v Version) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+// Core returns the version without pre-release and build metadata
+func (v Version) Core() Version {
+	return Version{Prefix: v.Prefix, Major: v.Major, Minor: v.Minor, Patch: v.Patch}
+}
+
+// Compare orders versions by semantic version precedence, returning -1, 0 or 1.
+// Build metadata is ignored.
+func (v Version) Compare(other Version) int {
+	for _, diff := range []int{v.Major - other.Major, v.Minor - other.Minor, v.Patch - other.Patch} {
+		if diff != 0 {
+			return sign(diff)
+		}
+	}
+
+	// A version without a pre-release has higher precedence than one with
+	switch {
+	case v.PreRelease == other.PreRelease:
+		return 0
+	case v.PreRelease == "":
+		return 1
+	case other.PreRelease == "":
+		return -1
+	}
+
+	left, right := strings.Split(v.PreRelease, "."), strings.Split(other.PreRelease, ".")
+	for i := 0; i < len(left) && i < len(right); i++ {
+		leftNum, leftErr := strconv.Atoi(left[i])
+		rightNum, rightErr := strconv.Atoi(right[i])
+		switch {
+		case leftErr == nil && rightErr == nil:
+			if leftNum != rightNum {
+				return sign(leftNum - rightNum)
+			}
+		case leftErr == nil:
+			// Numeric identifiers have lower precedence than alphanumeric ones
+			return -1
+		case rightErr == nil:
+			return 1
+		case left[i] != right[i]:
+			return strings.Compare(left[i], right[i])
+		}
+	}
+	return sign(len(left) - len(right))
+}
+
+// Bump is the size of a version increment
+type Bump int
+
+// Version increments in increasing order of size
+const (
+	BumpNone Bump = iota
+	BumpPatch
+	BumpMinor
+	BumpMajor
+)
+
+// String returns the name of the bump
+func (b Bump) String() string {
+	switch b {
+	case BumpPatch:
+		return "patch"
+	case BumpMinor:
+		return "minor"
+	case BumpMajor:
+		return "major"
+	default:
+		return "none"
+	}
+}
+
+// MarshalText writes the name of the bump
+func (b Bump) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+// ParseBump parses "major", "minor" or "patch"
+func ParseBump(value string) (Bump, error) {
+	for _, bump := range []Bump{BumpPatch, BumpMinor, BumpMajor} {
+		if value == bump.String() {
+			return bump, nil
+		}
+	}
+	return BumpNone, fmt.Errorf("invalid bump %q: expected major, minor or patch", value)
+}
+
+// Apply returns the version incremented by the bump
+func (b Bump) Apply(v Version) Version {
+	next := v.Core()
+	switch b {
+	case BumpMajor:
+		next.Major, next.Minor, next.Patch = next.Major+1, 0, 0
+	case BumpMinor:
+		next.Minor, next.Patch = next.Minor+1, 0
+	case BumpPatch:
+		next.Patch++
+	}
+	return next
+}
+
+// CommitBump returns the bump a single commit calls for
+func CommitBump(commit Commit) Bump {
+	if !commit.Conventional {
+		return BumpNone
+	}
+	switch {
+	case commit.Message.IsBreaking():
+		return BumpMajor
+	case commit.Message.Type == "feat":
+		return BumpMinor
+	case commit.Message.Type == "fix", commit.Message.Type == "perf", commit.Message.Type == "revert":
+		return BumpPatch
+	default:
+		return BumpNone
+	}
+}
+
+// ReleaseOptions controls how the next version is computed
+type ReleaseOptions struct {
+	// PreRelease creates a pre-release with this identifier, e.g. "rc" gives v1.2.0-rc.1
+	PreRelease string
+	// Build adds build metadata, e.g. "20261016" gives v1.2.0+20261016
+	Build string
+	// Bump overrides the bump computed from the commits
+	Bump Bump
+}
+
+// ReleasePlan describes the next release and the commits that justify it
+type ReleasePlan struct {
+	// Previous is the latest release tag, or empty if there is none
+	Previous string  `json:"previous,omitempty"`
+	Next     Version `json:"next"`
+	Bump     Bump    `json:"bump"`
+	// Commits are the commits since the latest stable release that call for a version bump
+	Commits []Commit `json:"commits"`
+}
+
+// LatestVersion returns the highest semantic version tag reachable from HEAD.
+// When stableOnly is set pre-release tags are ignored. The bool is false if no
+// such tag exists.
+func (wm *WorkflowManager) LatestVersion(stableOnly bool) (string, Version, bool, error) {
+	output, err := wm.gitOutput("tag", "--list", "--merged", "HEAD")
+	if err != nil {
+		return "", Version{}, false, fmt.Errorf("failed to list tags: %w", err)
+	}
+
+	var latestTag string
+	var latest Version
+	found := false
+	for _, tag := range strings.Fields(output) {
+		version, err := ParseVersion(tag)
+		if err != nil || (stableOnly && version.PreRelease != "") {
+			continue
+		}
+		if !found || version.Compare(latest) > 0 {
+			latestTag, latest, found = tag, version, true
+		}
+	}
+	return latestTag, latest, found, nil
+}
+
+// PlanRelease computes the next version from the commits since the latest
+// stable release: major for breaking changes, minor for features and patch for fixes
+func (wm *WorkflowManager) PlanRelease(options ReleaseOptions) (*ReleasePlan, error) {
+	if options.PreRelease != "" && !identifierSegment.MatchString(options.PreRelease) {
+		return nil, fmt.Errorf("invalid pre-release identifier %q", options.PreRelease)
+	}
+	if options.Build != "" && !identifierSegment.MatchString(options.Build) {
+		return nil, fmt.Errorf("invalid build metadata %q", options.Build)
+	}
+
+	stableTag, stable, hasStable, err := wm.LatestVersion(true)
+	if err != nil {
+		return nil, err
+	}
+	latestTag, latest, hasLatest, err := wm.LatestVersion(false)
+	if err != nil {
+		return nil, err
+	}
+	if !hasStable {
+		stable = Version{Prefix: "v"}
+		if hasLatest {
+			stable.Prefix = latest.Prefix
+		}
+	}
+
+	commits, err := wm.CommitsBetween(stableTag, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &ReleasePlan{Previous: latestTag, Bump: options.Bump}
+	computed := BumpNone
+	for _, commit := range commits {
+		if bump := CommitBump(commit); bump != BumpNone {
+			plan.Commits = append(plan.Commits, commit)
+			if bump > computed {
+				computed = bump
+			}
+		}
+	}
+	if plan.Bump == BumpNone {
+		plan.Bump = computed
+	}
+	if plan.Bump == BumpNone {
+		return nil, fmt.Errorf("no releasable changes since %s: no feat, fix or breaking commits", describeTag(stableTag))
+	}
+
+	next := plan.Bump.Apply(stable)
+	if options.PreRelease != "" {
+		// Continue numbering an existing pre-release of the same version, e.g. rc.1 -> rc.2
+		number := 1
+		if hasLatest && latest.Core().Compare(next) == 0 && latest.Prefix == next.Prefix {
+			if identifier, n, ok := splitPreRelease(latest.PreRelease); ok && identifier == options.PreRelease {
+				number = n + 1
+			}
+		}
+		next.PreRelease = fmt.Sprintf("%s.%d", options.PreRelease, number)
+	}
+	next.Build = options.Build
+
+	plan.Next = next
+	if hasLatest && next.Compare(latest) <= 0 {
+		return nil, fmt.Errorf("computed version %s is not newer than %s", next, latestTag)
+	}
+	return plan, nil
+}
+
+// Release tags HEAD with the planned version and optionally pushes the tag
+func (wm *WorkflowManager) Release(plan *ReleasePlan, message string, push bool) error {
+	version := plan.Next.String()
+	if message == "" {
+		message = "Release " + version
+	}
+	if err := wm.CreateTag(version, message); err != nil {
+		return err
+	}
+	if push {
+		return wm.PushTag(version)
+	}
+	return nil
+}
+
+// splitPreRelease splits a pre-release like "rc.2" into its identifier and number
+func splitPreRelease(preRelease string) (string, int, bool) {
+	dot := strings.LastIndex(preRelease, ".")
+	if dot < 0 {
+		return "", 0, false
+	}
+	number, err := strconv.Atoi(preRelease[dot+1:])
+	if err != nil {
+		return "", 0, false
+	}
+	return preRelease[:dot], number, true
+}
+
+// describeTag names a tag for messages, handling the no-tag case
+func describeTag(tag string) string {
+	if tag == "" {
+		return "the first commit"
+	}
+	return tag
+}
+
+// sign returns -1, 0 or 1 according to the sign of n
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,194 @@
+package gitworkflow
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Version
+		wantErr bool
+	}{
+		{input: "v1.2.3", want: Version{Prefix: "v", Major: 1, Minor: 2, Patch: 3}},
+		{input: "0.4.0", want: Version{Minor: 4}},
+		{input: "v2.0.0-rc.1+build.7", want: Version{Prefix: "v", Major: 2, PreRelease: "rc.1", Build: "build.7"}},
+		{input: "v1.2", wantErr: true},
+		{input: "v01.2.3", wantErr: true},
+		{input: "release-1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseVersion(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseVersion(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (got != tt.want || got.String() != tt.input) {
+			t.Errorf("ParseVersion(%q) = %+v (%s), want %+v", tt.input, got, got, tt.want)
+		}
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	// Ascending precedence, from the semver specification
+	ordered := []string{
+		"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta", "1.0.0-beta.2",
+		"1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.0.1", "1.1.0", "2.0.0",
+	}
+	for i := 0; i+1 < len(ordered); i++ {
+		lower, _ := ParseVersion(ordered[i])
+		higher, _ := ParseVersion(ordered[i+1])
+		if lower.Compare(higher) != -1 || higher.Compare(lower) != 1 {
+			t.Errorf("Expected %s < %s", ordered[i], ordered[i+1])
+		}
+	}
+
+	withBuild, _ := ParseVersion("1.0.0+abc")
+	plain, _ := ParseVersion("1.0.0")
+	if withBuild.Compare(plain) != 0 {
+		t.Error("Expected build metadata to be ignored")
+	}
+}
+
+func TestPlanRelease(t *testing.T) {
+	logSince := func(tag string) string {
+		return "git log --format=%H\x1f%B\x1e " + tag + "..HEAD"
+	}
+
+	tests := []struct {
+		name      string
+		tags      string
+		since     string
+		log       string
+		options   ReleaseOptions
+		want      string
+		wantBump  Bump
+		wantCount int
+		wantErr   string
+	}{
+		{
+			name:      "fix is a patch",
+			tags:      "v1.2.0\nv1.1.0\nnot-a-version\n",
+			since:     logSince("v1.2.0"),
+			log:       logRecord("a1", "fix(auth): handle nil user") + logRecord("a2", "docs: typo"),
+			want:      "v1.2.1",
+			wantBump:  BumpPatch,
+			wantCount: 1,
+		},
+		{
+			name:      "feat is a minor",
+			tags:      "v1.2.0\n",
+			since:     logSince("v1.2.0"),
+			log:       logRecord("a1", "fix: one") + logRecord("a2", "feat(chat): two"),
+			want:      "v1.3.0",
+			wantBump:  BumpMinor,
+			wantCount: 2,
+		},
+		{
+			name:      "breaking footer is a major",
+			tags:      "v1.2.0\n",
+			since:     logSince("v1.2.0"),
+			log:       logRecord("a1", "refactor: rename\n\nBREAKING CHANGE: renamed API"),
+			want:      "v2.0.0",
+			wantBump:  BumpMajor,
+			wantCount: 1,
+		},
+		{
+			name:     "first pre-release",
+			tags:     "v1.2.0\n",
+			since:    logSince("v1.2.0"),
+			log:      logRecord("a1", "feat: new"),
+			options:  ReleaseOptions{PreRelease: "rc", Build: "20261016"},
+			want:     "v1.3.0-rc.1+20261016",
+			wantBump: BumpMinor,
+		},
+		{
+			name:     "next pre-release continues numbering",
+			tags:     "v1.2.0\nv1.3.0-rc.1\nv1.3.0-rc.2\n",
+			since:    logSince("v1.2.0"),
+			log:      logRecord("a1", "feat: new"),
+			options:  ReleaseOptions{PreRelease: "rc"},
+			want:     "v1.3.0-rc.3",
+			wantBump: BumpMinor,
+		},
+		{
+			name:     "stable release after pre-releases",
+			tags:     "v1.2.0\nv1.3.0-rc.2\n",
+			since:    logSince("v1.2.0"),
+			log:      logRecord("a1", "feat: new"),
+			want:     "v1.3.0",
+			wantBump: BumpMinor,
+		},
+		{
+			name:     "first release without tags",
+			tags:     "",
+			since:    "git log --format=%H\x1f%B\x1e HEAD",
+			log:      logRecord("a1", "feat: initial"),
+			want:     "v0.1.0",
+			wantBump: BumpMinor,
+		},
+		{
+			name:     "forced bump",
+			tags:     "v1.2.0\n",
+			since:    logSince("v1.2.0"),
+			log:      logRecord("a1", "chore: tidy"),
+			options:  ReleaseOptions{Bump: BumpMajor},
+			want:     "v2.0.0",
+			wantBump: BumpMajor,
+		},
+		{
+			name:    "nothing releasable",
+			tags:    "v1.2.0\n",
+			since:   logSince("v1.2.0"),
+			log:     logRecord("a1", "chore: tidy"),
+			wantErr: "no releasable changes",
+		},
+		{
+			name:    "invalid pre-release",
+			options: ReleaseOptions{PreRelease: "rc 1"},
+			wantErr: "invalid pre-release",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := NewRecordingExecutor().
+				OnOutput("git tag --list --merged HEAD", tt.tags).
+				OnOutput(tt.since, tt.log)
+			wm := NewWorkflowManagerWithExecutor(executor)
+
+			plan, err := wm.PlanRelease(tt.options)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("PlanRelease() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PlanRelease() unexpected error: %v", err)
+			}
+			if plan.Next.String() != tt.want || plan.Bump != tt.wantBump {
+				t.Errorf("PlanRelease() = %s (%s), want %s (%s)", plan.Next, plan.Bump, tt.want, tt.wantBump)
+			}
+			if tt.wantCount > 0 && len(plan.Commits) != tt.wantCount {
+				t.Errorf("Expected %d justifying commits, got %d", tt.wantCount, len(plan.Commits))
+			}
+		})
+	}
+}
+
+func TestRelease(t *testing.T) {
+	executor := NewRecordingExecutor()
+	wm := NewWorkflowManagerWithExecutor(executor)
+	plan := &ReleasePlan{Next: Version{Prefix: "v", Major: 1, Minor: 3}}
+
+	if err := wm.Release(plan, "", true); err != nil {
+		t.Fatalf("Release() unexpected error: %v", err)
+	}
+	assertCommands(t, executor, []string{
+		"git tag -a v1.3.0 -m Release v1.3.0",
+		"git push origin v1.3.0",
+	})
+}
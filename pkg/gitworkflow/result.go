@@ -0,0 +1,155 @@
+package gitworkflow
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// Exit codes of vamosGitWF. Each kind of failure has its own code so scripts
+// can react to it without parsing the error message.
+const (
+	ExitOK                     = 0
+	ExitFailure                = 1
+	ExitUsage                  = 2
+	ExitDirtyWorktree          = 3
+	ExitConflict               = 4
+	ExitDiverged               = 5
+	ExitAheadOfRemote          = 6
+	ExitRejectedNonFastForward = 7
+	ExitAuthFailed             = 8
+	ExitStashConflict          = 9
+	ExitNotMerged              = 10
+	ExitChecksFailed           = 11
+	ExitCommitRejected         = 12
+	ExitWorkspaceFailed        = 13
+)
+
+// ErrorCode names a kind of failure in a Result, e.g. "dirty_worktree"
+type ErrorCode string
+
+// Codes reported in ResultError.Code
+const (
+	CodeFailure                ErrorCode = "error"
+	CodeUsage                  ErrorCode = "usage"
+	CodeDirtyWorktree          ErrorCode = "dirty_worktree"
+	CodeConflict               ErrorCode = "conflict"
+	CodeDiverged               ErrorCode = "diverged"
+	CodeAheadOfRemote          ErrorCode = "ahead_of_remote"
+	CodeRejectedNonFastForward ErrorCode = "rejected_non_fast_forward"
+	CodeAuthFailed             ErrorCode = "auth_failed"
+	CodeStashConflict          ErrorCode = "stash_conflict"
+	CodeNotMerged              ErrorCode = "not_merged"
+	CodeChecksFailed           ErrorCode = "checks_failed"
+	CodeCommitRejected         ErrorCode = "commit_rejected"
+	CodeWorkspaceFailed        ErrorCode = "workspace_failed"
+)
+
+// errorCodes maps the kinds of failure to their codes. The first kind err
+// matches with errors.Is wins.
+var errorCodes = []struct {
+	kind     error
+	code     ErrorCode
+	exitCode int
+}{
+	{ErrDirtyWorktree, CodeDirtyWorktree, ExitDirtyWorktree},
+	{ErrConflict, CodeConflict, ExitConflict},
+	{ErrDiverged, CodeDiverged, ExitDiverged},
+	{ErrAheadOfRemote, CodeAheadOfRemote, ExitAheadOfRemote},
+	{ErrRejectedNonFastForward, CodeRejectedNonFastForward, ExitRejectedNonFastForward},
+	{ErrAuthFailed, CodeAuthFailed, ExitAuthFailed},
+	{ErrStashConflict, CodeStashConflict, ExitStashConflict},
+	{ErrNotMerged, CodeNotMerged, ExitNotMerged},
+	{ErrChecksFailed, CodeChecksFailed, ExitChecksFailed},
+	{ErrCommitRejected, CodeCommitRejected, ExitCommitRejected},
+}
+
+// ClassifyError returns the code and exit code for the kind of failure err
+// is, or CodeFailure and ExitFailure when the kind is not recognized
+func ClassifyError(err error) (ErrorCode, int) {
+	var workspaceErr *WorkspaceError
+	if errors.As(err, &workspaceErr) {
+		return CodeWorkspaceFailed, ExitWorkspaceFailed
+	}
+	for _, entry := range errorCodes {
+		if errors.Is(err, entry.kind) {
+			return entry.code, entry.exitCode
+		}
+	}
+	return CodeFailure, ExitFailure
+}
+
+// Result is what a vamosGitWF command did, written as JSON with --output json.
+// Fields are only ever added to it, so scripts can rely on the ones they use.
+type Result struct {
+	// Operation is the command that ran, e.g. "story-start"
+	Operation string `json:"operation"`
+	OK        bool   `json:"ok"`
+	DryRun    bool   `json:"dry_run"`
+	// Branch is the branch the command worked on
+	Branch string `json:"branch,omitempty"`
+	// Commits are the commits the command created or is based on
+	Commits []Commit `json:"commits,omitempty"`
+	// Tag is the tag the command created
+	Tag string `json:"tag,omitempty"`
+	// Message is the summary printed without --output json
+	Message  string   `json:"message,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+	// WouldRun lists the commands a dry run reported instead of running
+	WouldRun []string `json:"would_run,omitempty"`
+	// Repos has the outcome in every repository of a workspace
+	Repos []RepoResult `json:"repos,omitempty"`
+	// Data is the command's own output, e.g. the stories listed by story-list
+	Data  interface{}  `json:"data,omitempty"`
+	Error *ResultError `json:"error,omitempty"`
+}
+
+// ResultError describes why a command failed
+type ResultError struct {
+	Code     ErrorCode `json:"code"`
+	ExitCode int       `json:"exit_code"`
+	Message  string    `json:"message"`
+	Hint     string    `json:"hint,omitempty"`
+	// Command, GitExitCode and Stderr describe the git command that failed, if any
+	Command     string `json:"command,omitempty"`
+	GitExitCode int    `json:"git_exit_code,omitempty"`
+	Stderr      string `json:"stderr,omitempty"`
+	// Checks are the pre-push checks that ran when they failed
+	Checks []CheckResult `json:"checks,omitempty"`
+}
+
+// NewResultError describes err, or returns nil when err is nil
+func NewResultError(err error) *ResultError {
+	if err == nil {
+		return nil
+	}
+	code, exitCode := ClassifyError(err)
+	resultErr := &ResultError{Code: code, ExitCode: exitCode, Message: err.Error()}
+
+	var gitErr *GitError
+	if errors.As(err, &gitErr) {
+		resultErr.Hint = gitErr.Hint()
+		resultErr.Command = gitErr.Command
+		resultErr.GitExitCode = gitErr.ExitCode
+		resultErr.Stderr = strings.TrimSpace(gitErr.Stderr)
+	}
+	var conflictErr *ConflictError
+	if errors.As(err, &conflictErr) {
+		resultErr.Hint = conflictErr.Hint()
+	}
+	var checksErr *ChecksError
+	if errors.As(err, &checksErr) {
+		resultErr.Checks = checksErr.Results
+	}
+	return resultErr
+}
+
+// MarshalJSON writes the outcome with the error described by NewResultError
+func (r RepoResult) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Repo    string       `json:"repo"`
+		OK      bool         `json:"ok"`
+		Summary string       `json:"summary,omitempty"`
+		Error   *ResultError `json:"error,omitempty"`
+	}{r.Repo, r.Err == nil, r.Summary, NewResultError(r.Err)})
+}
@@ -0,0 +1,93 @@
+package gitworkflow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		wantCode     ErrorCode
+		wantExitCode int
+	}{
+		{name: "dirty", err: refused(ErrDirtyWorktree, "commit first"), wantCode: CodeDirtyWorktree, wantExitCode: ExitDirtyWorktree},
+		{name: "wrapped auth", err: fmt.Errorf("failed to push: %w", newGitError(&ExitError{Command: "git push", ExitCode: 128, Stderr: "fatal: Authentication failed"})),
+			wantCode: CodeAuthFailed, wantExitCode: ExitAuthFailed},
+		{name: "conflict", err: &ConflictError{State: &ConflictState{Operation: OperationRebase}}, wantCode: CodeConflict, wantExitCode: ExitConflict},
+		{name: "checks", err: &ChecksError{Results: []CheckResult{{Name: "vet", Status: CheckFailed}}}, wantCode: CodeChecksFailed, wantExitCode: ExitChecksFailed},
+		{name: "rejected message", err: ErrCommitRejected, wantCode: CodeCommitRejected, wantExitCode: ExitCommitRejected},
+		{name: "workspace", err: &WorkspaceError{Results: []RepoResult{{Repo: "api", Err: ErrDiverged}}}, wantCode: CodeWorkspaceFailed, wantExitCode: ExitWorkspaceFailed},
+		{name: "unrecognized", err: errors.New("boom"), wantCode: CodeFailure, wantExitCode: ExitFailure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, exitCode := ClassifyError(tt.err)
+			if code != tt.wantCode || exitCode != tt.wantExitCode {
+				t.Errorf("ClassifyError() = %s, %d, want %s, %d", code, exitCode, tt.wantCode, tt.wantExitCode)
+			}
+		})
+	}
+}
+
+func TestNewResultError(t *testing.T) {
+	if NewResultError(nil) != nil {
+		t.Errorf("Expected no ResultError without an error")
+	}
+
+	err := fmt.Errorf("failed to push: %w", newGitError(&ExitError{
+		Command: "git push -u origin W-1", ExitCode: 1,
+		Stderr: " ! [rejected]        W-1 -> W-1 (non-fast-forward)\n",
+	}))
+	resultErr := NewResultError(err)
+	if resultErr.Code != CodeRejectedNonFastForward || resultErr.ExitCode != ExitRejectedNonFastForward {
+		t.Errorf("Unexpected code %s, %d", resultErr.Code, resultErr.ExitCode)
+	}
+	if resultErr.Command != "git push -u origin W-1" || resultErr.GitExitCode != 1 || !strings.HasPrefix(resultErr.Stderr, "! [rejected]") {
+		t.Errorf("Expected the failed git command, got %+v", resultErr)
+	}
+	if !strings.Contains(resultErr.Hint, "vamosGitWF sync") {
+		t.Errorf("Expected the hint, got %q", resultErr.Hint)
+	}
+
+	conflict := NewResultError(&ConflictError{State: &ConflictState{Operation: OperationRebase}})
+	if conflict.Code != CodeConflict || !strings.Contains(conflict.Hint, "resolve --continue") {
+		t.Errorf("Expected the conflict hint, got %+v", conflict)
+	}
+
+	checks := NewResultError(&ChecksError{Results: []CheckResult{{Name: "test", Status: CheckFailed, Output: "FAIL pkg"}}})
+	if len(checks.Checks) != 1 || checks.Checks[0].Name != "test" {
+		t.Errorf("Expected the check results, got %+v", checks)
+	}
+}
+
+func TestResultJSON(t *testing.T) {
+	result := Result{
+		Operation: "tag",
+		Tag:       "v1.2.0",
+		Data:      &ReleasePlan{Next: Version{Prefix: "v", Major: 1, Minor: 2}, Bump: BumpMinor},
+		Repos: []RepoResult{
+			{Repo: "api", Summary: "created tag v1.2.0"},
+			{Repo: "web", Err: refused(ErrDirtyWorktree, "web has changes")},
+		},
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("json.Marshal() unexpected error: %v", err)
+	}
+	for _, field := range []string{
+		`"operation":"tag","ok":false,"dry_run":false`,
+		`"next":"v1.2.0","bump":"minor"`,
+		`{"repo":"api","ok":true,"summary":"created tag v1.2.0"}`,
+		`{"repo":"web","ok":false,"error":{"code":"dirty_worktree","exit_code":3,"message":"web has changes"`,
+	} {
+		if !strings.Contains(string(data), field) {
+			t.Errorf("Expected the JSON to contain %s, got %s", field, data)
+		}
+	}
+}
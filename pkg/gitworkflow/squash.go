@@ -0,0 +1,212 @@
+package gitworkflow
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SquashOptions controls how SquashStory combines the commits of a story
+type SquashOptions struct {
+	// Message is the message of the squashed commit. When its description is
+	// empty the header of the oldest Conventional Commit on the branch is used.
+	Message CommitMessage
+	// CombineBodies appends the original commit messages, oldest first, to the body
+	CombineBodies bool
+}
+
+// SquashResult describes the commit SquashStory created
+type SquashResult struct {
+	Branch string `json:"branch"`
+	// MergeBase is the commit the story branch was squashed onto
+	MergeBase string `json:"merge_base"`
+	// Squashed is the number of commits replaced
+	Squashed int           `json:"squashed"`
+	Message  CommitMessage `json:"-"`
+	// Published is true when the branch exists on the remote, which then needs a force push
+	Published bool `json:"published"`
+}
+
+// SquashStory replaces all commits on the current branch since its merge base
+// with the remote base branch by a single Conventional Commit. Unstaged
+// changes are left alone; staged ones are refused since they would end up in
+// the commit. A backup is taken first.
+func (wm *WorkflowManager) SquashStory(options SquashOptions) (*SquashResult, error) {
+	branch, mergeBase, commits, err := wm.storyCommits()
+	if err != nil {
+		return nil, err
+	}
+	if len(commits) < 2 {
+		return nil, fmt.Errorf("%s has %d commit(s) since %s; nothing to squash", branch, len(commits), ShortHash(mergeBase))
+	}
+	if err := wm.requireNothingStaged("squashing"); err != nil {
+		return nil, err
+	}
+
+	message := squashMessage(commits, options)
+	if message.Description == "" {
+		return nil, fmt.Errorf("none of the commits on %s follows Conventional Commits; give the squashed commit a description", branch)
+	}
+	message, err = wm.prepareCommitMessage(message)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := wm.backupBefore("story-squash"); err != nil {
+		return nil, err
+	}
+	wm.logf("squashing %d commit(s) on %s since %s", len(commits), branch, ShortHash(mergeBase))
+	if _, err := wm.git("reset", "--soft", mergeBase); err != nil {
+		return nil, fmt.Errorf("failed to reset %s to %s: %w", branch, ShortHash(mergeBase), err)
+	}
+	if err := wm.commit(message); err != nil {
+		return nil, err
+	}
+
+	result := &SquashResult{Branch: branch, MergeBase: mergeBase, Squashed: len(commits), Message: message}
+	if _, err := wm.git("rev-parse", "--verify", "--quiet", "refs/remotes/"+wm.remoteRef(branch)); err == nil {
+		result.Published = true
+	}
+	return result, nil
+}
+
+// FixupCommit stages the changes selected by staging, commits them as a fixup
+// of target and folds that into target with an autosquash rebase. target must
+// be one of the story's own commits. A backup is taken first.
+func (wm *WorkflowManager) FixupCommit(target string, staging StageOptions) (*Commit, error) {
+	hash, err := wm.gitOutput("rev-parse", "--verify", "--quiet", target+"^{commit}")
+	if err != nil || hash == "" {
+		return nil, fmt.Errorf("%s is not a commit", target)
+	}
+	branch, mergeBase, commits, err := wm.storyCommits()
+	if err != nil {
+		return nil, err
+	}
+	var fixed *Commit
+	for i := range commits {
+		if commits[i].Hash == hash {
+			fixed = &commits[i]
+		}
+	}
+	if fixed == nil {
+		return nil, fmt.Errorf("%s is not one of the commits on %s since %s", ShortHash(hash), branch, ShortHash(mergeBase))
+	}
+
+	if _, err := wm.stageForCommit(staging); err != nil {
+		return nil, err
+	}
+	if err := wm.backupBefore("story-fixup " + ShortHash(hash)); err != nil {
+		return nil, err
+	}
+	if _, err := wm.git("commit", "--fixup="+hash); err != nil {
+		return nil, fmt.Errorf("failed to create fixup commit: %w", err)
+	}
+
+	// An empty sequence editor accepts the todo list autosquash prepared
+	wm.logf("folding the fixup into %s %s", ShortHash(hash), fixed.Subject)
+	if _, err := wm.git("-c", "sequence.editor=:", "rebase", "-i", "--autosquash", "--autostash", mergeBase); err != nil {
+		return nil, wm.conflictOrError(err, fmt.Sprintf("failed to fold the fixup into %s", ShortHash(hash)))
+	}
+	return fixed, nil
+}
+
+// storyCommits returns the current branch, its merge base with the remote base
+// branch and the commits since then, newest first
+func (wm *WorkflowManager) storyCommits() (string, string, []Commit, error) {
+	branch, err := wm.GetCurrentBranch()
+	if err != nil {
+		return "", "", nil, err
+	}
+	if branch == "HEAD" {
+		return "", "", nil, fmt.Errorf("HEAD is detached; check out the story branch first")
+	}
+	baseBranch, err := wm.getDefaultBranch()
+	if err != nil {
+		return "", "", nil, err
+	}
+	if branch == baseBranch {
+		return "", "", nil, fmt.Errorf("%s is the base branch; check out a story branch first", branch)
+	}
+
+	mergeBase, err := wm.gitOutput("merge-base", wm.remoteRef(baseBranch), "HEAD")
+	if err != nil {
+		return "", "", nil, fmt.Errorf("failed to find where %s branched off %s: %w", branch, wm.remoteRef(baseBranch), err)
+	}
+	commits, err := wm.CommitsBetween(mergeBase, "HEAD")
+	if err != nil {
+		return "", "", nil, err
+	}
+	return branch, mergeBase, commits, nil
+}
+
+// requireNothingStaged refuses to continue while the index has changes, which
+// would otherwise be committed along with what the operation commits
+func (wm *WorkflowManager) requireNothingStaged(operation string) error {
+	changes, err := wm.ChangedFiles()
+	if err != nil {
+		return err
+	}
+	var staged []string
+	for _, change := range filterChanges(changes, FileChange.Staged) {
+		staged = append(staged, change.Path)
+	}
+	if len(staged) > 0 {
+		return refused(ErrDirtyWorktree, "staged changes in %s; commit or unstage them before %s", strings.Join(staged, ", "), operation)
+	}
+	return nil
+}
+
+// squashMessage builds the message for the commits being squashed, newest first
+func squashMessage(commits []Commit, options SquashOptions) CommitMessage {
+	message := options.Message
+	message.Refs = nil
+	if message.Description == "" {
+		for i := len(commits) - 1; i >= 0; i-- {
+			if commits[i].Conventional {
+				oldest := commits[i].Message
+				if message.Type == "" {
+					message.Type, message.Scope = oldest.Type, oldest.Scope
+				}
+				message.Description = oldest.Description
+				break
+			}
+		}
+	}
+
+	// Keep breaking changes so the next release is still versioned correctly
+	var breaking []string
+	if message.BreakingChange != "" {
+		breaking = append(breaking, message.BreakingChange)
+	}
+	for i := len(commits) - 1; i >= 0; i-- {
+		commit := commits[i]
+		if commit.Message.Breaking {
+			message.Breaking = true
+		}
+		if change := commit.Message.BreakingChange; change != "" && !contains(breaking, change) {
+			breaking = append(breaking, change)
+		}
+	}
+	message.BreakingChange = strings.Join(breaking, "; ")
+
+	if options.CombineBodies {
+		var entries []string
+		for i := len(commits) - 1; i >= 0; i-- {
+			entry := "* " + commits[i].Subject
+			// Trailers of Conventional Commits are gathered above and by prepareCommitMessage
+			body := commits[i].Body
+			if commits[i].Conventional {
+				body = strings.TrimSpace(commits[i].Message.Body)
+			}
+			if body != "" {
+				entry += "\n" + indent(body, "  ")
+			}
+			entries = append(entries, entry)
+		}
+		combined := strings.Join(entries, "\n")
+		if body := strings.TrimSpace(message.Body); body != "" {
+			combined = body + "\n\n" + combined
+		}
+		message.Body = combined
+	}
+	return message
+}
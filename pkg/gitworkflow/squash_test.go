@@ -0,0 +1,267 @@
+package gitworkflow
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	mergeBaseCommand  = "git merge-base origin/main HEAD"
+	storyLogCommand   = "git log --format=%H\x1f%B\x1e 0000000base..HEAD"
+	squashHeadCommand = "git rev-parse --verify --quiet HEAD"
+)
+
+// squashRepo returns a manager on W-1-login, branched off main at 0000000base,
+// whose commits since then are returned by log
+func squashRepo(log string) (*WorkflowManager, *RecordingExecutor) {
+	executor := NewRecordingExecutor().
+		OnOutput("git rev-parse --abbrev-ref HEAD", "W-1-login\n").
+		OnOutput(mergeBaseCommand, "0000000base\n").
+		OnOutput(storyLogCommand, log).
+		OnOutput(squashHeadCommand, "3333333cccc\n")
+	wm := testManager(executor)
+	return wm, executor
+}
+
+// storyLog lists three commits on W-1-login, newest first
+var storyLog = logRecord("3333333cccc", "wip") +
+	logRecord("2222222bbbb", "fix(auth): handle empty password\n\nReject it before hashing.\n\nRefs: W-1") +
+	logRecord("1111111aaaa", "feat(auth): add login form\n\nRefs: W-1")
+
+func TestSquashStory(t *testing.T) {
+	wm, executor := squashRepo(storyLog)
+
+	result, err := wm.SquashStory(SquashOptions{})
+	if err != nil {
+		t.Fatalf("SquashStory() unexpected error: %v", err)
+	}
+	if result.Squashed != 3 || result.MergeBase != "0000000base" || !result.Published {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	backup := "git commit-tree 3333333cccc^{tree} -p 3333333cccc -m " + backupMessage("W-1-login", "story-squash")
+	want := "git commit -m feat(auth): add login form\n\nRefs: W-1"
+	var order []string
+	for _, command := range executor.Commands {
+		if command == backup || command == "git reset --soft 0000000base" || command == want {
+			order = append(order, command)
+		}
+	}
+	if strings.Join(order, "|") != strings.Join([]string{backup, "git reset --soft 0000000base", want}, "|") {
+		t.Errorf("Expected a backup, a soft reset and the squashed commit in order, got %q", executor.Commands)
+	}
+}
+
+func TestSquashStoryMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		options SquashOptions
+		log     string
+		want    string
+	}{
+		{
+			name:    "combined bodies",
+			options: SquashOptions{CombineBodies: true},
+			log:     storyLog,
+			want: "feat(auth): add login form\n\n* feat(auth): add login form\n" +
+				"* fix(auth): handle empty password\n  Reject it before hashing.\n* wip\n\nRefs: W-1",
+		},
+		{
+			name:    "combined bodies of other commits",
+			options: SquashOptions{CombineBodies: true},
+			log: logRecord("3333333cccc", "fix typo\n\nThe form said loign.") +
+				logRecord("2222222bbbb", "wip\n\nHalf of the form.\nStill needs styling.") +
+				logRecord("1111111aaaa", "feat(auth): add login form\n\nRefs: W-1"),
+			want: "feat(auth): add login form\n\n* feat(auth): add login form\n" +
+				"* wip\n  Half of the form.\n  Still needs styling.\n* fix typo\n  The form said loign.\n\nRefs: W-1",
+		},
+		{
+			name:    "explicit message",
+			options: SquashOptions{Message: CommitMessage{Scope: "auth", Description: "log in with a password", Body: "Closes the story."}},
+			log:     storyLog,
+			want:    "feat(auth): log in with a password\n\nCloses the story.\n\nRefs: W-1",
+		},
+		{
+			name: "breaking changes are kept",
+			log: logRecord("2222222bbbb", "refactor(api)!: drop v1\n\nBREAKING CHANGE: the /v1 routes are gone") +
+				logRecord("1111111aaaa", "feat(api): add v2"),
+			want: "feat(api)!: add v2\n\nBREAKING CHANGE: the /v1 routes are gone\nRefs: W-1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wm, executor := squashRepo(tt.log)
+			if _, err := wm.SquashStory(tt.options); err != nil {
+				t.Fatalf("SquashStory() unexpected error: %v", err)
+			}
+			if last := executor.Commands[len(executor.Commands)-2]; last != "git commit -m "+tt.want {
+				t.Errorf("Expected commit message %q, got %q", tt.want, last)
+			}
+		})
+	}
+}
+
+func TestSquashStoryRefuses(t *testing.T) {
+	wm, _ := squashRepo(logRecord("1111111aaaa", "feat(auth): add login form"))
+	if _, err := wm.SquashStory(SquashOptions{}); err == nil || !strings.Contains(err.Error(), "nothing to squash") {
+		t.Errorf("SquashStory() error = %v, want nothing to squash", err)
+	}
+
+	wm, _ = squashRepo(logRecord("2222222bbbb", "more") + logRecord("1111111aaaa", "wip"))
+	if _, err := wm.SquashStory(SquashOptions{}); err == nil || !strings.Contains(err.Error(), "give the squashed commit a description") {
+		t.Errorf("SquashStory() error = %v, want a description to be asked for", err)
+	}
+
+	wm, executor := squashRepo(storyLog)
+	executor.OnOutput(statusCommand, "M  main.go\x00")
+	_, err := wm.SquashStory(SquashOptions{})
+	if !errors.Is(err, ErrDirtyWorktree) || !strings.Contains(err.Error(), "staged changes in main.go") {
+		t.Errorf("SquashStory() error = %v, want staged changes refused", err)
+	}
+	for _, command := range executor.Commands {
+		if strings.HasPrefix(command, "git reset") {
+			t.Errorf("Expected nothing to be reset, got %q", command)
+		}
+	}
+}
+
+func TestFixupCommit(t *testing.T) {
+	wm, executor := squashRepo(storyLog)
+	executor.
+		OnOutput("git rev-parse --verify --quiet 1111111^{commit}", "1111111aaaa\n").
+		OnOutput(statusCommand, " M login.go\x00").
+		OnFailure("git diff --cached --quiet", 1, "")
+
+	fixed, err := wm.FixupCommit("1111111", StageOptions{})
+	if err != nil {
+		t.Fatalf("FixupCommit() unexpected error: %v", err)
+	}
+	if fixed.Subject != "feat(auth): add login form" {
+		t.Errorf("Unexpected commit: %+v", fixed)
+	}
+
+	commands := executor.Commands
+	want := []string{
+		"git commit-tree 3333333cccc^{tree} -p 3333333cccc -m " + backupMessage("W-1-login", "story-fixup 1111111"),
+		"git update-ref refs/vamos/backup/20261016-142501-",
+		"git commit --fixup=1111111aaaa",
+		"git -c sequence.editor=: rebase -i --autosquash --autostash 0000000base",
+	}
+	if !strings.HasPrefix(commands[len(commands)-4], want[0]) || !strings.HasPrefix(commands[len(commands)-3], want[1]) ||
+		commands[len(commands)-2] != want[2] || commands[len(commands)-1] != want[3] {
+		t.Errorf("Expected the commands to end with %q, got %q", want, commands)
+	}
+	if !contains(commands, "git add --all -- :/login.go") {
+		t.Errorf("Expected the changes to be staged, got %q", commands)
+	}
+}
+
+func TestFixupCommitRefuses(t *testing.T) {
+	wm, executor := squashRepo(storyLog)
+	executor.OnOutput("git rev-parse --verify --quiet 9999999^{commit}", "9999999zzzz\n")
+	if _, err := wm.FixupCommit("9999999", StageOptions{}); err == nil || !strings.Contains(err.Error(), "is not one of the commits on W-1-login") {
+		t.Errorf("FixupCommit() error = %v, want commit outside the story refused", err)
+	}
+
+	wm, executor = squashRepo(storyLog)
+	executor.OnFailure("git rev-parse --verify --quiet nope^{commit}", 1, "")
+	if _, err := wm.FixupCommit("nope", StageOptions{}); err == nil || !strings.Contains(err.Error(), "nope is not a commit") {
+		t.Errorf("FixupCommit() error = %v, want unknown commit refused", err)
+	}
+	for _, command := range executor.Commands {
+		if strings.HasPrefix(command, "git commit") || strings.HasPrefix(command, "git add") {
+			t.Errorf("Expected nothing to be committed, got %q", command)
+		}
+	}
+}
+
+func TestFixupCommitConflict(t *testing.T) {
+	wm, executor := squashRepo(storyLog)
+	executor.
+		OnOutput("git rev-parse --verify --quiet 1111111^{commit}", "1111111aaaa\n").
+		OnOutput(statusCommand, " M login.go\x00").
+		OnFailure("git diff --cached --quiet", 1, "").
+		OnFailure("git -c sequence.editor=: rebase -i --autosquash --autostash 0000000base", 1, "CONFLICT (content): Merge conflict in login.go")
+
+	_, err := wm.FixupCommit("1111111", StageOptions{})
+	if err == nil || !strings.Contains(err.Error(), "failed to fold the fixup into 1111111") {
+		t.Fatalf("FixupCommit() error = %v, want the rebase failure", err)
+	}
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected errors.Is(err, ErrConflict), got %v", err)
+	}
+}
+
+// storyBranchRepo returns a repository on W-5-search with two commits on top of
+// main, adding search.go and query.go, and README.md changed but not staged
+func storyBranchRepo(t *testing.T) (*gitRepo, string) {
+	repo := newGitRepo(t)
+	repo.git("checkout", "--quiet", "-b", "W-5-search")
+	first := repo.commit("search.go", "feat(search): add index")
+	repo.commit("query.go", "fix(search): handle empty query")
+	if err := os.WriteFile(filepath.Join(repo.dir, "README.md"), []byte("unfinished\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return repo, first
+}
+
+func TestSquashStoryInRepo(t *testing.T) {
+	repo, _ := storyBranchRepo(t)
+	before := repo.git("rev-parse", "HEAD")
+	wm := repo.manager()
+
+	result, err := wm.SquashStory(SquashOptions{})
+	if err != nil {
+		t.Fatalf("SquashStory() unexpected error: %v", err)
+	}
+	if result.Squashed != 2 || result.Published {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if count := repo.git("rev-list", "--count", "origin/main..HEAD"); count != "1" {
+		t.Errorf("Expected a single commit on the branch, got %s", count)
+	}
+	if subject := repo.git("log", "-1", "--format=%s"); subject != "feat(search): add index" {
+		t.Errorf("Unexpected subject %q", subject)
+	}
+	if files := repo.git("show", "--name-only", "--format=", "HEAD"); files != "query.go\nsearch.go" {
+		t.Errorf("Expected the squashed commit to hold both files, got %q", files)
+	}
+	if tree := repo.git("rev-parse", "HEAD^{tree}"); tree != repo.git("rev-parse", before+"^{tree}") {
+		t.Error("Expected the squashed commit to have the tree of the old tip")
+	}
+	if status := repo.git("status", "--porcelain"); status != "M README.md" {
+		t.Errorf("Expected README.md to stay changed, got %q", status)
+	}
+	backups, err := wm.Backups()
+	if err != nil || len(backups) != 1 || backups[0].Commit != before {
+		t.Errorf("Expected the old tip %s to be backed up, got %+v (%v)", before, backups, err)
+	}
+}
+
+func TestFixupCommitInRepo(t *testing.T) {
+	repo, first := storyBranchRepo(t)
+	if err := os.WriteFile(filepath.Join(repo.dir, "search.go"), []byte("feat(search): add a faster index\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fixed, err := repo.manager().FixupCommit(ShortHash(first), StageOptions{Paths: []string{"search.go"}})
+	if err != nil {
+		t.Fatalf("FixupCommit() unexpected error: %v", err)
+	}
+	if fixed.Hash != first {
+		t.Errorf("Expected %s to be fixed, got %+v", first, fixed)
+	}
+	if subjects := repo.git("log", "--format=%s", "origin/main..HEAD"); subjects != "fix(search): handle empty query\nfeat(search): add index" {
+		t.Errorf("Expected the fixup to be folded in, got:\n%s", subjects)
+	}
+	if contents := repo.git("show", "HEAD~1:search.go"); contents != "feat(search): add a faster index" {
+		t.Errorf("Expected the fixed commit to hold the change, got %q", contents)
+	}
+	if status := repo.git("status", "--porcelain"); status != "M README.md" {
+		t.Errorf("Expected README.md to stay changed, got %q", status)
+	}
+}
@@ -0,0 +1,294 @@
+package gitworkflow
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// DefaultDenyPatterns are the file patterns story-commit refuses to commit when none are configured
+var DefaultDenyPatterns = []string{".env", ".env.*", "*.pem", "*.key", "id_rsa", "id_rsa.*"}
+
+// StageOptions selects which changes CommitStory stages before committing.
+// The zero value stages every change in the working tree.
+type StageOptions struct {
+	// StagedOnly commits the index as it is without staging anything
+	StagedOnly bool
+	// Paths lists files or directories to stage, relative to the current directory
+	Paths []string
+	// Globs lists patterns matched against repository-relative paths; "**" matches across directories
+	Globs []string
+	// Interactive shows the diff of each changed file and asks whether to stage it
+	Interactive bool
+	// In and Out are used for the interactive prompt
+	In  io.Reader
+	Out io.Writer
+}
+
+// FileChange describes a changed file reported by git status
+type FileChange struct {
+	// Path is relative to the repository root
+	Path string
+	// Index and Worktree are the porcelain status codes, e.g. 'M', 'A', 'D', '?'
+	Index    byte
+	Worktree byte
+}
+
+// Untracked reports whether the file is not yet known to git
+func (f FileChange) Untracked() bool {
+	return f.Index == '?'
+}
+
+// Staged reports whether the file has changes in the index
+func (f FileChange) Staged() bool {
+	return f.Index != ' ' && f.Index != '?'
+}
+
+// Unstaged reports whether the file has changes that are not in the index
+func (f FileChange) Unstaged() bool {
+	return f.Worktree != ' '
+}
+
+// ChangedFiles lists every staged, unstaged and untracked file in the working tree
+func (wm *WorkflowManager) ChangedFiles() ([]FileChange, error) {
+	result, err := wm.git("status", "--porcelain", "-z", "--untracked-files=all")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read git status: %w", err)
+	}
+
+	var changes []FileChange
+	records := strings.Split(result.Stdout, "\x00")
+	for i := 0; i < len(records); i++ {
+		record := records[i]
+		if len(record) < 4 {
+			continue
+		}
+		change := FileChange{Index: record[0], Worktree: record[1], Path: record[3:]}
+		// Renames and copies are followed by a record holding the original path
+		if change.Index == 'R' || change.Index == 'C' {
+			i++
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// stageForCommit stages the changes selected by options, refusing to continue
+// if any file that would be committed matches the deny list. It returns the
+// pathspecs it added, which a dry run only reports.
+func (wm *WorkflowManager) stageForCommit(options StageOptions) ([]string, error) {
+	changes, err := wm.ChangedFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var pathspecs []string
+	switch {
+	case options.StagedOnly:
+		// Nothing to add; commit the index as it is
+
+	case len(options.Paths) > 0 || len(options.Globs) > 0:
+		pathspecs = append(pathspecs, options.Paths...)
+		if len(options.Globs) > 0 {
+			matched := filterChanges(changes, func(change FileChange) bool {
+				return change.Unstaged() && matchesAny(options.Globs, change.Path)
+			})
+			if len(matched) == 0 {
+				return nil, fmt.Errorf("no changed files match %s", strings.Join(options.Globs, ", "))
+			}
+			pathspecs = append(pathspecs, topPathspecs(matched)...)
+		}
+
+	case options.Interactive:
+		picked, err := wm.pickChanges(changes, options.In, options.Out)
+		if err != nil {
+			return nil, err
+		}
+		pathspecs = topPathspecs(picked)
+
+	default:
+		pathspecs = topPathspecs(filterChanges(changes, FileChange.Unstaged))
+	}
+
+	// Explicit paths may name directories, so resolve what they would actually stage
+	candidates := filterChanges(changes, FileChange.Staged)
+	if len(options.Paths) > 0 {
+		output, err := wm.gitOutput(append([]string{"add", "--dry-run", "--all", "--"}, options.Paths...)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve paths to stage: %w", err)
+		}
+		for _, line := range strings.Split(output, "\n") {
+			if _, file, ok := strings.Cut(strings.TrimSpace(line), " "); ok {
+				candidates = append(candidates, FileChange{Path: strings.Trim(file, "'")})
+			}
+		}
+	}
+	for _, spec := range pathspecs {
+		if file, ok := strings.CutPrefix(spec, ":/"); ok {
+			candidates = append(candidates, FileChange{Path: file})
+		}
+	}
+
+	if denied := wm.deniedFiles(candidates); len(denied) > 0 {
+		return nil, fmt.Errorf("refusing to commit files matching the deny list (%s): %s",
+			strings.Join(wm.config.Commit.Deny, ", "), strings.Join(denied, ", "))
+	}
+
+	if len(pathspecs) > 0 {
+		if _, err := wm.git(append([]string{"add", "--all", "--"}, pathspecs...)...); err != nil {
+			return nil, fmt.Errorf("failed to add changes: %w", err)
+		}
+		// Nothing was really staged, so the check below would fail
+		if wm.dryRun {
+			return pathspecs, nil
+		}
+	}
+
+	// Make sure the commit will not be empty
+	if _, err := wm.git("diff", "--cached", "--quiet"); err == nil {
+		return nil, fmt.Errorf("nothing staged to commit")
+	} else if exitCode(err) != 1 {
+		return nil, fmt.Errorf("failed to check staged changes: %w", err)
+	}
+
+	return pathspecs, nil
+}
+
+// pickChanges shows the diff of each unstaged or untracked file and asks whether to stage it
+func (wm *WorkflowManager) pickChanges(changes []FileChange, in io.Reader, out io.Writer) ([]FileChange, error) {
+	if in == nil || out == nil {
+		return nil, fmt.Errorf("interactive staging needs an input and output")
+	}
+
+	reader := bufio.NewReader(in)
+	var picked []FileChange
+	stageRest := false
+	for _, change := range filterChanges(changes, FileChange.Unstaged) {
+		if matchesAny(wm.config.Commit.Deny, change.Path) {
+			fmt.Fprintf(out, "\nskipping %s: matches the deny list\n", change.Path)
+			continue
+		}
+		if stageRest {
+			picked = append(picked, change)
+			continue
+		}
+
+		if change.Untracked() {
+			fmt.Fprintf(out, "\nnew file: %s\n", change.Path)
+		} else {
+			diff, err := wm.git("diff", "--", ":/"+change.Path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to diff %s: %w", change.Path, err)
+			}
+			fmt.Fprintf(out, "\n%s", diff.Stdout)
+		}
+
+		for {
+			fmt.Fprintf(out, "Stage %s [y,n,a,q,?]? ", change.Path)
+			answer, err := reader.ReadString('\n')
+			if err != nil && answer == "" {
+				return nil, fmt.Errorf("interactive staging aborted: %w", err)
+			}
+
+			switch strings.ToLower(strings.TrimSpace(answer)) {
+			case "y", "yes":
+				picked = append(picked, change)
+			case "n", "no":
+			case "a", "all":
+				picked = append(picked, change)
+				stageRest = true
+			case "q", "quit":
+				return nil, fmt.Errorf("interactive staging aborted")
+			default:
+				fmt.Fprintln(out, "y - stage this file\nn - skip this file\na - stage this and all remaining files\nq - quit without committing")
+				continue
+			}
+			break
+		}
+	}
+
+	if len(picked) == 0 {
+		return nil, fmt.Errorf("no files selected")
+	}
+	return picked, nil
+}
+
+// deniedFiles returns the paths among changes that match the configured deny list
+func (wm *WorkflowManager) deniedFiles(changes []FileChange) []string {
+	var denied []string
+	seen := make(map[string]bool)
+	for _, change := range changes {
+		if !seen[change.Path] && matchesAny(wm.config.Commit.Deny, change.Path) {
+			denied = append(denied, change.Path)
+		}
+		seen[change.Path] = true
+	}
+	return denied
+}
+
+// filterChanges returns the changes for which keep returns true
+func filterChanges(changes []FileChange, keep func(FileChange) bool) []FileChange {
+	var kept []FileChange
+	for _, change := range changes {
+		if keep(change) {
+			kept = append(kept, change)
+		}
+	}
+	return kept
+}
+
+// topPathspecs converts repository-relative paths to pathspecs that work from any subdirectory
+func topPathspecs(changes []FileChange) []string {
+	specs := make([]string, 0, len(changes))
+	for _, change := range changes {
+		specs = append(specs, ":/"+change.Path)
+	}
+	return specs
+}
+
+// matchesAny reports whether file matches any of patterns. Patterns without a
+// slash match the file's base name, like .gitignore; "**" matches across directories.
+func matchesAny(patterns []string, file string) bool {
+	for _, pattern := range patterns {
+		target := file
+		if !strings.Contains(pattern, "/") {
+			target = path.Base(file)
+		}
+		if globPattern(pattern).MatchString(target) {
+			return true
+		}
+	}
+	return false
+}
+
+// globPattern compiles a glob with "*", "?" and "**" into a regular expression
+func globPattern(glob string) *regexp.Regexp {
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				// "**/" also matches zero directories
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					i++
+					expr.WriteString("(?:.*/)?")
+				} else {
+					expr.WriteString(".*")
+				}
+			} else {
+				expr.WriteString("[^/]*")
+			}
+		case '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.MustCompile(expr.String())
+}
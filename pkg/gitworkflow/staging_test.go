@@ -0,0 +1,221 @@
+package gitworkflow
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const statusCommand = "git status --porcelain -z --untracked-files=all"
+
+func TestChangedFiles(t *testing.T) {
+	executor := NewRecordingExecutor().
+		OnOutput(statusCommand, "M  staged.go\x00 M unstaged.go\x00?? new file.txt\x00R  renamed.go\x00old.go\x00")
+	wm := NewWorkflowManagerWithExecutor(executor)
+
+	changes, err := wm.ChangedFiles()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var paths []string
+	for _, change := range changes {
+		paths = append(paths, change.Path)
+	}
+	if strings.Join(paths, ",") != "staged.go,unstaged.go,new file.txt,renamed.go" {
+		t.Fatalf("Unexpected paths %v", paths)
+	}
+	if !changes[0].Staged() || changes[0].Unstaged() {
+		t.Errorf("Expected staged.go to be staged only: %+v", changes[0])
+	}
+	if changes[1].Staged() || !changes[1].Unstaged() {
+		t.Errorf("Expected unstaged.go to be unstaged only: %+v", changes[1])
+	}
+	if !changes[2].Untracked() || !changes[2].Unstaged() {
+		t.Errorf("Expected new file.txt to be untracked: %+v", changes[2])
+	}
+}
+
+func TestCommitStoryStaging(t *testing.T) {
+	status := " M main.go\x00 M pkg/a/a.go\x00?? pkg/b/b_test.go\x00?? notes.txt\x00"
+
+	tests := []struct {
+		name       string
+		options    StageOptions
+		input      string
+		wantAdd    string
+		wantErr    string
+		extraSetup func(*RecordingExecutor)
+	}{
+		{
+			name:    "staged only adds nothing",
+			options: StageOptions{StagedOnly: true},
+		},
+		{
+			name:    "explicit paths",
+			options: StageOptions{Paths: []string{"main.go"}},
+			extraSetup: func(e *RecordingExecutor) {
+				e.OnOutput("git add --dry-run --all -- main.go", "add 'main.go'\n")
+			},
+			wantAdd: "git add --all -- main.go",
+		},
+		{
+			name:    "glob across directories",
+			options: StageOptions{Globs: []string{"pkg/**/*.go"}},
+			wantAdd: "git add --all -- :/pkg/a/a.go :/pkg/b/b_test.go",
+		},
+		{
+			name:    "glob without matches",
+			options: StageOptions{Globs: []string{"*.md"}},
+			wantErr: "no changed files match",
+		},
+		{
+			name:    "interactive picks per file",
+			options: StageOptions{Interactive: true},
+			input:   "y\nn\nwhat\ny\nn\n",
+			wantAdd: "git add --all -- :/main.go :/pkg/b/b_test.go",
+		},
+		{
+			name:    "interactive stage all remaining",
+			options: StageOptions{Interactive: true},
+			input:   "n\na\n",
+			wantAdd: "git add --all -- :/pkg/a/a.go :/pkg/b/b_test.go :/notes.txt",
+		},
+		{
+			name:    "interactive quit",
+			options: StageOptions{Interactive: true},
+			input:   "q\n",
+			wantErr: "aborted",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := NewRecordingExecutor().
+				OnOutput(statusCommand, status).
+				OnFailure("git diff --cached --quiet", 1, "")
+			if tt.extraSetup != nil {
+				tt.extraSetup(executor)
+			}
+			var out bytes.Buffer
+			tt.options.In = strings.NewReader(tt.input)
+			tt.options.Out = &out
+			wm := NewWorkflowManagerWithExecutor(executor)
+
+			_, err := wm.CommitStory(CommitMessage{Description: "update"}, tt.options)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("CommitStory() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				for _, command := range executor.Commands {
+					if strings.HasPrefix(command, "git add --all") || strings.HasPrefix(command, "git commit") {
+						t.Errorf("Expected nothing staged or committed, got %q", command)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CommitStory() unexpected error: %v", err)
+			}
+
+			var adds []string
+			for _, command := range executor.Commands {
+				if strings.HasPrefix(command, "git add --all") {
+					adds = append(adds, command)
+				}
+			}
+			if tt.wantAdd == "" && len(adds) != 0 {
+				t.Errorf("Expected no git add, got %v", adds)
+			}
+			if tt.wantAdd != "" && (len(adds) != 1 || adds[0] != tt.wantAdd) {
+				t.Errorf("git add = %v, want %q", adds, tt.wantAdd)
+			}
+			if last := executor.Commands[len(executor.Commands)-1]; last != "git commit -m feat: update" {
+				t.Errorf("Expected commit last, got %q", last)
+			}
+		})
+	}
+}
+
+func TestCommitStoryRefusesDeniedFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  string
+		options StageOptions
+		setup   func(*RecordingExecutor)
+	}{
+		{
+			name:   "untracked env file in default mode",
+			status: " M main.go\x00?? config/.env\x00",
+		},
+		{
+			name:    "already staged key with staged only",
+			status:  "A  certs/server.pem\x00",
+			options: StageOptions{StagedOnly: true},
+		},
+		{
+			name:    "directory path containing a key",
+			status:  "?? certs/server.key\x00",
+			options: StageOptions{Paths: []string{"certs"}},
+			setup: func(e *RecordingExecutor) {
+				e.OnOutput("git add --dry-run --all -- certs", "add 'certs/server.key'\n")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := NewRecordingExecutor().OnOutput(statusCommand, tt.status)
+			if tt.setup != nil {
+				tt.setup(executor)
+			}
+			wm := NewWorkflowManagerWithExecutor(executor)
+
+			_, err := wm.CommitStory(CommitMessage{Description: "update"}, tt.options)
+			if err == nil || !strings.Contains(err.Error(), "deny list") {
+				t.Fatalf("Expected deny list refusal, got %v", err)
+			}
+			for _, command := range executor.Commands {
+				if strings.HasPrefix(command, "git add --all") || strings.HasPrefix(command, "git commit") {
+					t.Errorf("Expected nothing staged or committed, got %q", command)
+				}
+			}
+		})
+	}
+}
+
+func TestCommitStoryNothingStaged(t *testing.T) {
+	executor := NewRecordingExecutor()
+	wm := NewWorkflowManagerWithExecutor(executor)
+
+	_, err := wm.CommitStory(CommitMessage{Description: "update"}, StageOptions{StagedOnly: true})
+	if err == nil || !strings.Contains(err.Error(), "nothing staged") {
+		t.Fatalf("Expected nothing staged error, got %v", err)
+	}
+}
+
+func TestMatchesAny(t *testing.T) {
+	tests := []struct {
+		pattern string
+		file    string
+		want    bool
+	}{
+		{pattern: ".env", file: ".env", want: true},
+		{pattern: ".env", file: "deploy/.env", want: true},
+		{pattern: ".env.*", file: ".env.local", want: true},
+		{pattern: "*.pem", file: "certs/ca.pem", want: true},
+		{pattern: "*.pem", file: "certs/ca.pem.txt", want: false},
+		{pattern: "pkg/*.go", file: "pkg/a.go", want: true},
+		{pattern: "pkg/*.go", file: "pkg/sub/a.go", want: false},
+		{pattern: "pkg/**/*.go", file: "pkg/a.go", want: true},
+		{pattern: "pkg/**/*.go", file: "pkg/sub/deep/a.go", want: true},
+		{pattern: "docs/**", file: "docs/a/b.md", want: true},
+		{pattern: "file?.txt", file: "file1.txt", want: true},
+	}
+
+	for _, tt := range tests {
+		if got := matchesAny([]string{tt.pattern}, tt.file); got != tt.want {
+			t.Errorf("matchesAny(%q, %q) = %v, want %v", tt.pattern, tt.file, got, tt.want)
+		}
+	}
+}
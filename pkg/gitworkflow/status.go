@@ -0,0 +1,116 @@
+package gitworkflow
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StatusOptions controls Status
+type StatusOptions struct {
+	// Fetch updates the remote-tracking branches first so the counts are current
+	Fetch bool
+}
+
+// Status is where the current branch stands. Counts against remote branches
+// are as of the last fetch.
+type Status struct {
+	// Branch is the checked out branch, or "HEAD" when Detached
+	Branch   string `json:"branch"`
+	Detached bool   `json:"detached"`
+	// StoryID is parsed from Branch, e.g. "W-123", or empty for other branches
+	StoryID string `json:"story_id,omitempty"`
+	// Upstream is the branch's upstream, e.g. "origin/W-123-login", or empty when it has none
+	Upstream string `json:"upstream,omitempty"`
+	// Ahead and Behind count the commits only on the branch and only on Upstream
+	Ahead  int `json:"ahead"`
+	Behind int `json:"behind"`
+	// Base is the remote base branch, e.g. "origin/main", or empty when it has not been fetched
+	Base string `json:"base,omitempty"`
+	// BaseAhead and BaseBehind count the commits only on the branch and only on Base
+	BaseAhead  int `json:"base_ahead"`
+	BaseBehind int `json:"base_behind"`
+	Staged     int `json:"staged"`
+	Unstaged   int `json:"unstaged"`
+	Untracked  int `json:"untracked"`
+	Conflicted int `json:"conflicted"`
+	// Operation is the rebase, merge, cherry-pick or revert waiting to be continued, if any
+	Operation GitOperation `json:"operation,omitempty"`
+	// LatestTag is the most recent tag reachable from HEAD, or empty
+	LatestTag string `json:"latest_tag,omitempty"`
+	Stashes   int    `json:"stashes"`
+}
+
+// Status reports the current branch and story, how far the branch is from its
+// upstream and from the remote base branch, the uncommitted changes, any
+// operation in progress, the latest tag and the number of stashes
+func (wm *WorkflowManager) Status(options StatusOptions) (*Status, error) {
+	if options.Fetch {
+		if err := wm.fetchRemote(); err != nil {
+			return nil, fmt.Errorf("failed to fetch from %s: %w", wm.config.Remote, err)
+		}
+	}
+
+	branch, err := wm.GetCurrentBranch()
+	if err != nil {
+		return nil, err
+	}
+	status := &Status{Branch: branch, Detached: branch == "HEAD"}
+	status.StoryID, _ = wm.StoryID(branch)
+
+	if !status.Detached {
+		// Exits non-zero when the branch has no upstream
+		if upstream, err := wm.gitOutput("rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{upstream}"); err == nil && upstream != "" {
+			status.Upstream = upstream
+			if status.Ahead, status.Behind, err = wm.aheadBehind(upstream, "HEAD"); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	baseBranch, err := wm.getDefaultBranch()
+	if err != nil {
+		return nil, err
+	}
+	base := wm.remoteRef(baseBranch)
+	if _, err := wm.git("rev-parse", "--verify", "--quiet", "refs/remotes/"+base); err == nil {
+		status.Base = base
+		if status.BaseAhead, status.BaseBehind, err = wm.aheadBehind(base, "HEAD"); err != nil {
+			return nil, err
+		}
+	}
+
+	changes, err := wm.ChangedFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, change := range changes {
+		switch {
+		case isUnmerged(change):
+			status.Conflicted++
+		case change.Untracked():
+			status.Untracked++
+		default:
+			if change.Staged() {
+				status.Staged++
+			}
+			if change.Unstaged() {
+				status.Unstaged++
+			}
+		}
+	}
+
+	if status.Operation, err = wm.InProgressOperation(); err != nil {
+		return nil, err
+	}
+	if status.LatestTag, err = wm.LatestTag("HEAD"); err != nil {
+		return nil, err
+	}
+	stashes, err := wm.gitOutput("stash", "list")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stashes: %w", err)
+	}
+	if stashes != "" {
+		status.Stashes = len(strings.Split(stashes, "\n"))
+	}
+	return status, nil
+}
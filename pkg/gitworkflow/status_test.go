@@ -0,0 +1,74 @@
+package gitworkflow
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const statusUpstreamCommand = "git rev-parse --abbrev-ref --symbolic-full-name @{upstream}"
+
+func TestStatus(t *testing.T) {
+	executor := NewRecordingExecutor().
+		OnOutput("git rev-parse --abbrev-ref HEAD", "W-123-login\n").
+		OnOutput(statusUpstreamCommand, "origin/W-123-login\n").
+		OnOutput("git rev-list --left-right --count origin/W-123-login...HEAD", "1\t2\n").
+		OnOutput("git rev-list --left-right --count origin/main...HEAD", "5\t3\n").
+		OnOutput(statusCommand, "M  staged.go\x00MM both.go\x00 M edited.go\x00?? new.go\x00UU conflict.go\x00").
+		OnOutput("git describe --tags --abbrev=0 HEAD", "v1.2.0\n").
+		OnOutput("git stash list", "stash@{0}: WIP on main\nstash@{1}: On W-1: draft\n")
+	wm := testManager(executor)
+
+	status, err := wm.Status(StatusOptions{})
+	if err != nil {
+		t.Fatalf("Status() unexpected error: %v", err)
+	}
+	want := Status{
+		Branch: "W-123-login", StoryID: "W-123",
+		Upstream: "origin/W-123-login", Ahead: 2, Behind: 1,
+		Base: "origin/main", BaseAhead: 3, BaseBehind: 5,
+		Staged: 2, Unstaged: 2, Untracked: 1, Conflicted: 1,
+		LatestTag: "v1.2.0", Stashes: 2,
+	}
+	if *status != want {
+		t.Errorf("Status() = %+v, want %+v", *status, want)
+	}
+	if contains(executor.Commands, "git fetch origin") {
+		t.Errorf("Expected no fetch without Fetch, got %q", executor.Commands)
+	}
+
+	data, err := json.Marshal(status)
+	if err != nil {
+		t.Fatalf("json.Marshal() unexpected error: %v", err)
+	}
+	for _, field := range []string{`"story_id":"W-123"`, `"base_behind":5`, `"stashes":2`} {
+		if !strings.Contains(string(data), field) {
+			t.Errorf("Expected the JSON to contain %s, got %s", field, data)
+		}
+	}
+}
+
+func TestStatusWithoutUpstreamOrBase(t *testing.T) {
+	executor := NewRecordingExecutor().
+		OnOutput("git rev-parse --abbrev-ref HEAD", "spike\n").
+		OnFailure(statusUpstreamCommand, 128, "fatal: no upstream configured for branch 'spike'").
+		OnFailure("git rev-parse --verify --quiet refs/remotes/origin/main", 1, "").
+		OnFailure("git describe --tags --abbrev=0 HEAD", 128, "fatal: No names found, cannot describe anything.")
+	wm := testManager(executor)
+
+	status, err := wm.Status(StatusOptions{Fetch: true})
+	if err != nil {
+		t.Fatalf("Status() unexpected error: %v", err)
+	}
+	if status.StoryID != "" || status.Upstream != "" || status.Base != "" || status.LatestTag != "" || status.Stashes != 0 {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+	if executor.Commands[0] != "git fetch origin" {
+		t.Errorf("Expected a fetch first, got %q", executor.Commands)
+	}
+	for _, command := range executor.Commands {
+		if strings.HasPrefix(command, "git rev-list") {
+			t.Errorf("Expected nothing to be compared, got %q", command)
+		}
+	}
+}
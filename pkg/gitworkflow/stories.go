@@ -0,0 +1,386 @@
+package gitworkflow
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Story is a story branch that exists locally, on the remote or both
+type Story struct {
+	ID     string `json:"id"`
+	Branch string `json:"branch"`
+	Local  bool   `json:"local"`
+	Remote bool   `json:"remote"`
+	// Current is true when Branch is checked out
+	Current bool `json:"current"`
+	// Ahead and Behind count the commits only on the branch and only on the
+	// remote base branch. The local branch is compared when there is one.
+	Ahead  int `json:"ahead"`
+	Behind int `json:"behind"`
+	// LastCommit is the committer date of the newest of the local and remote tips
+	LastCommit time.Time `json:"last_commit"`
+	// Merged is true when the branch had commits of its own and every one of
+	// them is on the remote base branch. A story with nothing committed since
+	// it was started is not merged.
+	Merged bool `json:"merged"`
+}
+
+// StoryListOptions controls ListStories
+type StoryListOptions struct {
+	// Fetch updates the remote-tracking branches first, dropping those deleted on the remote
+	Fetch bool
+}
+
+// FinishResult describes what FinishStory cleaned up
+type FinishResult struct {
+	Branch        string `json:"branch"`
+	Base          string `json:"base"`
+	DeletedLocal  bool   `json:"deleted_local"`
+	DeletedRemote bool   `json:"deleted_remote"`
+}
+
+// ListStories returns the local and remote story branches, most recently
+// committed to first, with how far each is from the base branch
+func (wm *WorkflowManager) ListStories(options StoryListOptions) ([]Story, error) {
+	if options.Fetch {
+		if err := wm.fetchPrune(); err != nil {
+			return nil, err
+		}
+	}
+	baseBranch, err := wm.getDefaultBranch()
+	if err != nil {
+		return nil, err
+	}
+	stories, err := wm.storyBranches()
+	if err != nil {
+		return nil, err
+	}
+	history, err := wm.baseHistory(wm.remoteRef(baseBranch))
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range stories {
+		story := &stories[i]
+		story.Ahead, story.Behind, err = wm.aheadBehind(wm.remoteRef(baseBranch), wm.storyTip(story))
+		if err != nil {
+			return nil, err
+		}
+		if story.Ahead == 0 {
+			if story.Merged, err = wm.storyHasOwnCommits(story, history); err != nil {
+				return nil, err
+			}
+		}
+	}
+	sort.SliceStable(stories, func(i, j int) bool {
+		return stories[i].LastCommit.After(stories[j].LastCommit)
+	})
+	return stories, nil
+}
+
+// SwitchStory checks out the branch of the story with storyID, which may be
+// given with or without the branch prefix, e.g. "W-123" or "123". A story that
+// only exists on the remote gets a local branch tracking it. The branch name is
+// returned.
+func (wm *WorkflowManager) SwitchStory(storyID string) (string, error) {
+	stories, err := wm.storyBranches()
+	if err != nil {
+		return "", err
+	}
+	story, err := wm.findStory(stories, storyID)
+	if err != nil {
+		return "", err
+	}
+
+	if story.Local {
+		if err := wm.checkoutBranch(story.Branch); err != nil {
+			return "", fmt.Errorf("failed to checkout %s: %w", story.Branch, err)
+		}
+		return story.Branch, nil
+	}
+	if _, err := wm.git("checkout", "--track", wm.remoteRef(story.Branch)); err != nil {
+		return "", fmt.Errorf("failed to checkout %s: %w", wm.remoteRef(story.Branch), err)
+	}
+	return story.Branch, nil
+}
+
+// FinishStory cleans up after the story with storyID, or the current story
+// branch when storyID is empty, has been merged: it checks that all of its
+// commits are on the remote base branch, switches to the base branch and pulls
+// it, and deletes the local and remote story branches.
+func (wm *WorkflowManager) FinishStory(storyID string) (*FinishResult, error) {
+	if err := wm.fetchPrune(); err != nil {
+		return nil, err
+	}
+	baseBranch, err := wm.getDefaultBranch()
+	if err != nil {
+		return nil, err
+	}
+	stories, err := wm.storyBranches()
+	if err != nil {
+		return nil, err
+	}
+
+	var story *Story
+	if storyID == "" {
+		for i := range stories {
+			if stories[i].Current {
+				story = &stories[i]
+			}
+		}
+		if story == nil {
+			current, err := wm.GetCurrentBranch()
+			if err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%s is not a story branch; pass the story ID to finish", current)
+		}
+	} else if story, err = wm.findStory(stories, storyID); err != nil {
+		return nil, err
+	}
+
+	if err := wm.requireCleanTrackedFiles("commit or stash them before finishing the story"); err != nil {
+		return nil, err
+	}
+
+	// Check both tips, so commits pushed from elsewhere are not lost either
+	var tips []string
+	if story.Local {
+		tips = append(tips, story.Branch)
+	}
+	if story.Remote {
+		tips = append(tips, wm.remoteRef(story.Branch))
+	}
+	for _, tip := range tips {
+		ahead, _, err := wm.aheadBehind(wm.remoteRef(baseBranch), tip)
+		if err != nil {
+			return nil, err
+		}
+		if ahead > 0 {
+			return nil, refused(ErrNotMerged, "%s has %d commit(s) that are not on %s", tip, ahead, wm.remoteRef(baseBranch))
+		}
+	}
+	history, err := wm.baseHistory(wm.remoteRef(baseBranch))
+	if err != nil {
+		return nil, err
+	}
+	if own, err := wm.storyHasOwnCommits(story, history); err != nil {
+		return nil, err
+	} else if !own {
+		return nil, refused(ErrNotMerged, "%s has no commits of its own, so there is nothing merged to finish", story.Branch)
+	}
+
+	wm.logf("%s is merged into %s", story.Branch, wm.remoteRef(baseBranch))
+	if err := wm.checkoutBranch(baseBranch); err != nil {
+		return nil, fmt.Errorf("failed to checkout %s branch: %w", baseBranch, err)
+	}
+	if err := wm.pullLatest(baseBranch); err != nil {
+		return nil, fmt.Errorf("failed to pull latest changes: %w", err)
+	}
+
+	result := &FinishResult{Branch: story.Branch, Base: baseBranch}
+	if story.Local {
+		if _, err := wm.git("branch", "-d", story.Branch); err != nil {
+			return result, fmt.Errorf("failed to delete %s: %w", story.Branch, err)
+		}
+		result.DeletedLocal = true
+	}
+	if story.Remote {
+		if _, err := wm.git("push", wm.config.Remote, "--delete", story.Branch); err != nil {
+			return result, fmt.Errorf("failed to delete %s: %w", wm.remoteRef(story.Branch), err)
+		}
+		result.DeletedRemote = true
+	}
+	return result, nil
+}
+
+// storyBranches lists the local and remote-tracking story branches, one Story
+// per branch name, without comparing them to the base branch
+func (wm *WorkflowManager) storyBranches() ([]Story, error) {
+	current, err := wm.GetCurrentBranch()
+	if err != nil {
+		return nil, err
+	}
+	remotePrefix := "refs/remotes/" + wm.config.Remote + "/"
+	output, err := wm.gitOutput("for-each-ref", "--format=%(refname)"+logFieldSeparator+"%(committerdate:unix)", "refs/heads", strings.TrimSuffix(remotePrefix, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branches: %w", err)
+	}
+
+	var stories []Story
+	index := map[string]int{}
+	for _, line := range strings.Split(output, "\n") {
+		refname, date, ok := strings.Cut(line, logFieldSeparator)
+		if !ok {
+			continue
+		}
+		branch, local := strings.CutPrefix(refname, "refs/heads/")
+		if !local {
+			branch = strings.TrimPrefix(refname, remotePrefix)
+		}
+		id, ok := wm.StoryID(branch)
+		if !ok {
+			continue
+		}
+
+		i, seen := index[branch]
+		if !seen {
+			i = len(stories)
+			index[branch] = i
+			stories = append(stories, Story{ID: id, Branch: branch, Current: branch == current})
+		}
+		if local {
+			stories[i].Local = true
+		} else {
+			stories[i].Remote = true
+		}
+		if seconds, err := strconv.ParseInt(date, 10, 64); err == nil {
+			if committed := time.Unix(seconds, 0); committed.After(stories[i].LastCommit) {
+				stories[i].LastCommit = committed
+			}
+		}
+	}
+	return stories, nil
+}
+
+// findStory returns the one story branch for storyID
+func (wm *WorkflowManager) findStory(stories []Story, storyID string) (*Story, error) {
+	if !strings.HasPrefix(storyID, wm.config.Branch.Prefix) {
+		storyID = wm.config.Branch.Prefix + storyID
+	}
+
+	var matches []*Story
+	var branches []string
+	for i := range stories {
+		if stories[i].ID == storyID {
+			matches = append(matches, &stories[i])
+			branches = append(branches, stories[i].Branch)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no branch found for story %s; run 'vamosGitWF story-list --fetch' to see the stories on %s", storyID, wm.config.Remote)
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, fmt.Errorf("story %s has several branches: %s; check out the one you want with git", storyID, strings.Join(branches, ", "))
+	}
+}
+
+// storyTip returns the local branch of story, or its remote-tracking branch if it has none
+func (wm *WorkflowManager) storyTip(story *Story) string {
+	if story.Local {
+		return story.Branch
+	}
+	return wm.remoteRef(story.Branch)
+}
+
+// baseHistory returns the commits on the first-parent history of base, the
+// commits made on or fast-forwarded into the base branch itself
+func (wm *WorkflowManager) baseHistory(base string) (map[string]bool, error) {
+	output, err := wm.gitOutput("rev-list", "--first-parent", base)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the history of %s: %w", base, err)
+	}
+	history := map[string]bool{}
+	for _, commit := range strings.Fields(output) {
+		history[commit] = true
+	}
+	return history, nil
+}
+
+// hasOwnCommits reports whether branch has ever had commits of its own,
+// unlike a story that was started and has nothing committed yet. tips are the
+// commits its local and remote branches are at, and remote tells whether it
+// exists on the remote. A tip off the first-parent history of the base branch,
+// as a merge commit leaves it, has commits of its own. Otherwise the start
+// recorded by StartStory tells, and without one a branch that was published,
+// i.e. exists on the remote or has an upstream, is taken to have had commits.
+func (wm *WorkflowManager) hasOwnCommits(branch string, tips []string, remote bool, history map[string]bool) (bool, error) {
+	for _, tip := range tips {
+		if !history[tip] {
+			return true, nil
+		}
+	}
+
+	start, err := wm.storyStart(branch)
+	if err != nil {
+		return false, err
+	}
+	if start != "" {
+		for _, tip := range tips {
+			if tip != start {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	if remote {
+		return true, nil
+	}
+	if _, err := wm.git("config", "--get", "branch."+branch+".merge"); err != nil {
+		if exitCode(err) != 1 {
+			return false, fmt.Errorf("failed to read the upstream of %s: %w", branch, err)
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+// storyHasOwnCommits reports whether the local or the remote branch of story has had commits of its own
+func (wm *WorkflowManager) storyHasOwnCommits(story *Story, history map[string]bool) (bool, error) {
+	var refs, tips []string
+	if story.Local {
+		refs = append(refs, "refs/heads/"+story.Branch)
+	}
+	if story.Remote {
+		refs = append(refs, "refs/remotes/"+wm.remoteRef(story.Branch))
+	}
+	for _, ref := range refs {
+		tip, err := wm.gitOutput("rev-parse", "--verify", ref)
+		if err != nil {
+			return false, fmt.Errorf("failed to resolve %s: %w", ref, err)
+		}
+		tips = append(tips, tip)
+	}
+	return wm.hasOwnCommits(story.Branch, tips, story.Remote, history)
+}
+
+// storyStartKey is the branch config key the commit a story branch was created
+// from is recorded in, e.g. branch.W-1-login.vamosBase
+func storyStartKey(branch string) string {
+	return "branch." + branch + ".vamosBase"
+}
+
+// recordStoryStart records that branch was created from commit, so a story
+// without commits of its own can be told from a merged one
+func (wm *WorkflowManager) recordStoryStart(branch, commit string) error {
+	if _, err := wm.git("config", storyStartKey(branch), commit); err != nil {
+		return fmt.Errorf("failed to record the start of %s: %w", branch, err)
+	}
+	return nil
+}
+
+// storyStart returns the commit recorded by recordStoryStart for branch, or
+// an empty string for a branch StartStory did not create
+func (wm *WorkflowManager) storyStart(branch string) (string, error) {
+	start, err := wm.gitOutput("config", "--get", storyStartKey(branch))
+	if err != nil {
+		if exitCode(err) == 1 {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read the start of %s: %w", branch, err)
+	}
+	return start, nil
+}
+
+// fetchPrune fetches the configured remote and drops remote-tracking branches deleted there
+func (wm *WorkflowManager) fetchPrune() error {
+	if _, err := wm.git("fetch", "--prune", wm.config.Remote); err != nil {
+		return fmt.Errorf("failed to fetch from %s: %w", wm.config.Remote, err)
+	}
+	return nil
+}
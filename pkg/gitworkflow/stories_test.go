@@ -0,0 +1,320 @@
+package gitworkflow
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const branchesCommand = "git for-each-ref --format=%(refname)\x1f%(committerdate:unix) refs/heads refs/remotes/origin"
+
+// storiesRepo returns an executor on current with these branches: W-1-login
+// local and remote, W-2 only remote and W-3-chat only local. login is the
+// rev-list count of W-1-login against origin/main.
+func storiesRepo(current, login string) *RecordingExecutor {
+	return NewRecordingExecutor().
+		OnOutput("git rev-parse --abbrev-ref HEAD", current+"\n").
+		OnOutput(branchesCommand, strings.Join([]string{
+			"refs/heads/main\x1f1700000000",
+			"refs/heads/W-1-login\x1f1700000300",
+			"refs/heads/W-3-chat\x1f1700000100",
+			"refs/remotes/origin/HEAD\x1f1700000000",
+			"refs/remotes/origin/W-1-login\x1f1700000200",
+			"refs/remotes/origin/W-2\x1f1700000400",
+		}, "\n")).
+		OnOutput("git rev-list --left-right --count origin/main...W-1-login", login).
+		OnOutput("git rev-list --left-right --count origin/main...origin/W-2", "0\t0\n").
+		OnOutput("git rev-list --left-right --count origin/main...W-3-chat", "5\t1\n")
+}
+
+func TestListStories(t *testing.T) {
+	executor := storiesRepo("W-1-login", "3\t2\n")
+	stories, err := testManager(executor).ListStories(StoryListOptions{Fetch: true})
+	if err != nil {
+		t.Fatalf("ListStories() unexpected error: %v", err)
+	}
+
+	want := []Story{
+		{ID: "W-2", Branch: "W-2", Remote: true, Merged: true},
+		{ID: "W-1", Branch: "W-1-login", Local: true, Remote: true, Current: true, Ahead: 2, Behind: 3},
+		{ID: "W-3", Branch: "W-3-chat", Local: true, Ahead: 1, Behind: 5},
+	}
+	if len(stories) != len(want) {
+		t.Fatalf("ListStories() returned %d stories, want %d: %+v", len(stories), len(want), stories)
+	}
+	for i, story := range stories {
+		story.LastCommit = want[i].LastCommit
+		if story != want[i] {
+			t.Errorf("stories[%d] = %+v, want %+v", i, story, want[i])
+		}
+	}
+	if got := stories[1].LastCommit.Unix(); got != 1700000300 {
+		t.Errorf("Expected the newest tip's date, got %d", got)
+	}
+	if executor.Commands[0] != "git fetch --prune origin" {
+		t.Errorf("Expected a fetch first, got %q", executor.Commands[0])
+	}
+}
+
+func TestListStoriesWithoutCommits(t *testing.T) {
+	tests := []struct {
+		name       string
+		tip        string
+		start      string
+		wantMerged bool
+	}{
+		// W-2 was pushed right after story-start, so its tip is where it started
+		{name: "started", tip: "c1", start: "c1", wantMerged: false},
+		// Its commits were fast-forwarded into main
+		{name: "fast-forwarded", tip: "c2", start: "c1", wantMerged: true},
+		// It was started elsewhere, so only its being on the remote tells
+		{name: "started elsewhere", tip: "c2", wantMerged: true},
+		// A merge commit leaves its tip off the first-parent history of main
+		{name: "merge commit", tip: "b1", wantMerged: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := storiesRepo("W-1-login", "3\t2\n").
+				OnOutput("git rev-list --first-parent origin/main", "m1\nc2\nc1\n").
+				OnOutput("git rev-parse --verify refs/remotes/origin/W-2", tt.tip+"\n")
+			if tt.start == "" {
+				executor.OnFailure("git config --get branch.W-2.vamosBase", 1, "")
+			} else {
+				executor.OnOutput("git config --get branch.W-2.vamosBase", tt.start+"\n")
+			}
+			stories, err := testManager(executor).ListStories(StoryListOptions{})
+			if err != nil {
+				t.Fatalf("ListStories() unexpected error: %v", err)
+			}
+			if stories[0].Branch != "W-2" || stories[0].Merged != tt.wantMerged {
+				t.Errorf("Expected W-2 to have Merged %t, got %+v", tt.wantMerged, stories[0])
+			}
+		})
+	}
+}
+
+func TestSwitchStory(t *testing.T) {
+	tests := []struct {
+		id          string
+		wantBranch  string
+		wantCommand string
+		wantErr     string
+	}{
+		{id: "3", wantBranch: "W-3-chat", wantCommand: "git checkout W-3-chat"},
+		{id: "W-2", wantBranch: "W-2", wantCommand: "git checkout --track origin/W-2"},
+		{id: "W-9", wantErr: "no branch found for story W-9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			executor := storiesRepo("W-1-login", "3\t2\n")
+			branch, err := testManager(executor).SwitchStory(tt.id)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("SwitchStory() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SwitchStory() unexpected error: %v", err)
+			}
+			if branch != tt.wantBranch {
+				t.Errorf("SwitchStory() = %q, want %q", branch, tt.wantBranch)
+			}
+			if last := executor.Commands[len(executor.Commands)-1]; last != tt.wantCommand {
+				t.Errorf("Expected %q, got %q", tt.wantCommand, last)
+			}
+		})
+	}
+}
+
+func TestSwitchStoryAmbiguous(t *testing.T) {
+	executor := NewRecordingExecutor().
+		OnOutput(branchesCommand, "refs/heads/W-1-login\x1f1\nrefs/heads/W-1-signup\x1f2\n")
+	_, err := testManager(executor).SwitchStory("W-1")
+	if err == nil || !strings.Contains(err.Error(), "W-1-login, W-1-signup") {
+		t.Fatalf("SwitchStory() error = %v, want both branches named", err)
+	}
+}
+
+func TestFinishStory(t *testing.T) {
+	executor := storiesRepo("W-1-login", "3\t0\n").
+		OnOutput("git rev-list --left-right --count origin/main...origin/W-1-login", "3\t0\n")
+
+	result, err := testManager(executor).FinishStory("")
+	if err != nil {
+		t.Fatalf("FinishStory() unexpected error: %v", err)
+	}
+	if *result != (FinishResult{Branch: "W-1-login", Base: "main", DeletedLocal: true, DeletedRemote: true}) {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	assertCommands(t, executor, []string{
+		"git fetch --prune origin",
+		"git rev-parse --abbrev-ref HEAD",
+		branchesCommand,
+		statusCommand,
+		"git rev-list --left-right --count origin/main...W-1-login",
+		"git rev-list --left-right --count origin/main...origin/W-1-login",
+		"git rev-list --first-parent origin/main",
+		"git rev-parse --verify refs/heads/W-1-login",
+		"git rev-parse --verify refs/remotes/origin/W-1-login",
+		"git checkout main",
+		"git pull origin main",
+		"git branch -d W-1-login",
+		"git push origin --delete W-1-login",
+	})
+}
+
+func TestFinishStoryRemoteOnly(t *testing.T) {
+	executor := storiesRepo("W-1-login", "3\t2\n")
+	result, err := testManager(executor).FinishStory("W-2")
+	if err != nil {
+		t.Fatalf("FinishStory() unexpected error: %v", err)
+	}
+	if result.DeletedLocal || !result.DeletedRemote {
+		t.Errorf("Expected only the remote branch to be deleted, got %+v", result)
+	}
+	for _, command := range executor.Commands {
+		if strings.HasPrefix(command, "git branch -d") {
+			t.Errorf("Expected no local branch to be deleted, got %q", command)
+		}
+	}
+}
+
+func TestFinishStoryRefuses(t *testing.T) {
+	tests := []struct {
+		name     string
+		executor *RecordingExecutor
+		id       string
+		wantKind error
+		wantErr  string
+	}{
+		{
+			name:     "not merged",
+			executor: storiesRepo("W-1-login", "3\t2\n"),
+			wantKind: ErrNotMerged,
+			wantErr:  "W-1-login has 2 commit(s) that are not on origin/main",
+		},
+		{
+			name: "remote has more commits",
+			executor: storiesRepo("W-1-login", "3\t0\n").
+				OnOutput("git rev-list --left-right --count origin/main...origin/W-1-login", "3\t1\n"),
+			wantKind: ErrNotMerged,
+			wantErr:  "origin/W-1-login has 1 commit(s)",
+		},
+		{
+			name: "no commits",
+			executor: storiesRepo("W-1-login", "3\t2\n").
+				OnOutput("git rev-list --first-parent origin/main", "c1\n").
+				OnOutput("git rev-parse --verify refs/remotes/origin/W-2", "c1\n").
+				OnOutput("git config --get branch.W-2.vamosBase", "c1\n"),
+			id:       "W-2",
+			wantKind: ErrNotMerged,
+			wantErr:  "W-2 has no commits of its own",
+		},
+		{
+			name:     "dirty",
+			executor: storiesRepo("W-1-login", "3\t2\n").OnOutput(statusCommand, " M main.go\x00"),
+			id:       "W-3",
+			wantKind: ErrDirtyWorktree,
+			wantErr:  "uncommitted changes in main.go",
+		},
+		{
+			name:     "not a story branch",
+			executor: storiesRepo("main", "3\t2\n"),
+			wantErr:  "main is not a story branch",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := testManager(tt.executor).FinishStory(tt.id)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("FinishStory() error = %v, want %q", err, tt.wantErr)
+			}
+			if tt.wantKind != nil && !errors.Is(err, tt.wantKind) {
+				t.Errorf("Expected errors.Is(err, %v)", tt.wantKind)
+			}
+			for _, command := range tt.executor.Commands {
+				if strings.HasPrefix(command, "git checkout") || strings.HasPrefix(command, "git branch -d") || strings.Contains(command, "--delete") {
+					t.Errorf("Expected nothing to change, got %q", command)
+				}
+			}
+		})
+	}
+}
+
+func TestFinishStoryInRepo(t *testing.T) {
+	t.Run("started without commits", func(t *testing.T) {
+		repo := newGitRepo(t)
+		wm := repo.manager()
+		if _, err := wm.StartStory("1", "login", StartOptions{}); err != nil {
+			t.Fatalf("StartStory() unexpected error: %v", err)
+		}
+		repo.git("push", "--quiet", "-u", "origin", "W-1-login")
+
+		stories, err := wm.ListStories(StoryListOptions{})
+		if err != nil || len(stories) != 1 || stories[0].Merged {
+			t.Fatalf("ListStories() = %+v, %v, want W-1-login not merged", stories, err)
+		}
+		if _, err := wm.FinishStory(""); !errors.Is(err, ErrNotMerged) {
+			t.Fatalf("FinishStory() error = %v, want ErrNotMerged", err)
+		}
+	})
+
+	t.Run("fast-forwarded after the reflog expired", func(t *testing.T) {
+		repo := newGitRepo(t)
+		wm := repo.manager()
+		if _, err := wm.StartStory("1", "login", StartOptions{}); err != nil {
+			t.Fatalf("StartStory() unexpected error: %v", err)
+		}
+		repo.commit("login.go", "feat: add login")
+		repo.git("push", "--quiet", "-u", "origin", "W-1-login")
+		repo.git("reflog", "expire", "--expire=now", "--all")
+
+		other := repo.clone("other")
+		other.git("merge", "--quiet", "--ff-only", "origin/W-1-login")
+		other.git("push", "--quiet", "origin", "main")
+
+		result, err := wm.FinishStory("")
+		if err != nil {
+			t.Fatalf("FinishStory() unexpected error: %v", err)
+		}
+		if !result.DeletedLocal || !result.DeletedRemote {
+			t.Errorf("Expected both branches to be deleted, got %+v", result)
+		}
+	})
+
+	t.Run("switched to and fast-forwarded", func(t *testing.T) {
+		repo := newGitRepo(t)
+		other := repo.clone("other")
+		other.git("checkout", "--quiet", "-b", "W-2-search")
+		other.commit("search.go", "feat: add search")
+		other.git("push", "--quiet", "-u", "origin", "W-2-search")
+
+		wm := repo.manager()
+		repo.git("fetch", "--quiet")
+		if _, err := wm.SwitchStory("2"); err != nil {
+			t.Fatalf("SwitchStory() unexpected error: %v", err)
+		}
+
+		// The pull request is fast-forwarded and its branch deleted on the remote
+		other.git("checkout", "--quiet", "main")
+		other.git("merge", "--quiet", "--ff-only", "W-2-search")
+		other.git("push", "--quiet", "origin", "main", ":W-2-search")
+
+		stories, err := wm.ListStories(StoryListOptions{Fetch: true})
+		if err != nil || len(stories) != 1 || !stories[0].Merged {
+			t.Fatalf("ListStories() = %+v, %v, want W-2-search merged", stories, err)
+		}
+		result, err := wm.FinishStory("W-2")
+		if err != nil {
+			t.Fatalf("FinishStory() unexpected error: %v", err)
+		}
+		if !result.DeletedLocal || result.DeletedRemote {
+			t.Errorf("Expected only the local branch to be deleted, got %+v", result)
+		}
+	})
+}
@@ -0,0 +1,251 @@
+package gitworkflow
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Strategies for reconciling a branch that has diverged from its remote
+const (
+	SyncRebase = "rebase"
+	SyncMerge  = "merge"
+)
+
+// SyncAction is what Sync did to bring a branch up to date
+type SyncAction string
+
+// Actions reported in SyncResult.Action
+const (
+	SyncUpToDate      SyncAction = "up-to-date"
+	SyncAhead         SyncAction = "ahead"
+	SyncFastForwarded SyncAction = "fast-forwarded"
+	SyncRebased       SyncAction = "rebased"
+	SyncMerged        SyncAction = "merged"
+	SyncNoUpstream    SyncAction = "no-upstream"
+)
+
+// SyncOptions controls how Sync reconciles the current branch with its remote
+type SyncOptions struct {
+	// Strategy is SyncRebase or SyncMerge; empty uses the configured sync.strategy
+	Strategy string
+	// Push publishes local commits once the branch contains everything on its remote,
+	// creating the remote branch if it does not exist yet
+	Push bool
+	// Autostash stashes uncommitted changes, including untracked files, while
+	// remote commits are brought in and reapplies them afterwards
+	Autostash bool
+	// Strict refuses with ErrAheadOfRemote when the branch is only ahead and
+	// Push is not set, for scripts that need every commit published
+	Strict bool
+}
+
+// SyncResult describes what Sync found and did
+type SyncResult struct {
+	Branch   string `json:"branch"`
+	Upstream string `json:"upstream,omitempty"`
+	// Ahead and Behind count the commits only on the branch and only on Upstream before syncing
+	Ahead  int        `json:"ahead"`
+	Behind int        `json:"behind"`
+	Action SyncAction `json:"action"`
+	Pushed bool       `json:"pushed"`
+}
+
+// Summary describes the result in a sentence
+func (r *SyncResult) Summary() string {
+	var summary string
+	switch r.Action {
+	case SyncUpToDate:
+		summary = fmt.Sprintf("%s is up to date with %s", r.Branch, r.Upstream)
+	case SyncAhead:
+		if r.Pushed {
+			return fmt.Sprintf("Pushed %d commit(s) from %s to %s", r.Ahead, r.Branch, r.Upstream)
+		}
+		summary = fmt.Sprintf("%s is %d commit(s) ahead of %s", r.Branch, r.Ahead, r.Upstream)
+	case SyncFastForwarded:
+		summary = fmt.Sprintf("Fast-forwarded %s by %d commit(s) from %s", r.Branch, r.Behind, r.Upstream)
+	case SyncRebased:
+		summary = fmt.Sprintf("Rebased %d local commit(s) of %s onto %d new commit(s) from %s", r.Ahead, r.Branch, r.Behind, r.Upstream)
+	case SyncMerged:
+		summary = fmt.Sprintf("Merged %d new commit(s) from %s into %s", r.Behind, r.Upstream, r.Branch)
+	case SyncNoUpstream:
+		if r.Pushed {
+			return fmt.Sprintf("Published %s as %s", r.Branch, r.Upstream)
+		}
+		return fmt.Sprintf("%s does not exist yet; run 'vamosGitWF sync --push' to publish %s", r.Upstream, r.Branch)
+	}
+
+	switch {
+	case r.Pushed:
+		summary += fmt.Sprintf(" and pushed to %s", r.Upstream)
+	case r.Ahead > 0:
+		summary += "; run 'vamosGitWF sync --push' to push"
+	}
+	return summary
+}
+
+// SyncWithRemote syncs the current branch with remote using the configured strategy
+func (wm *WorkflowManager) SyncWithRemote() error {
+	_, err := wm.Sync(SyncOptions{})
+	return err
+}
+
+// Sync fetches the remote and brings the current branch up to date with its
+// remote branch: a branch that is only behind is fast-forwarded, one that has
+// diverged is rebased or merged according to the strategy, and one that is
+// ahead is pushed when options.Push is set; without it, a branch that is only
+// ahead is left as it is unless options.Strict is set. Untracked files do not
+// block a sync; uncommitted changes to tracked files do when remote commits come in,
+// unless options.Autostash is set.
+func (wm *WorkflowManager) Sync(options SyncOptions) (*SyncResult, error) {
+	strategy := options.Strategy
+	if strategy == "" {
+		strategy = wm.config.Sync.Strategy
+	}
+	if strategy != SyncRebase && strategy != SyncMerge {
+		return nil, fmt.Errorf("unknown sync strategy %q: must be %s or %s", strategy, SyncRebase, SyncMerge)
+	}
+
+	// First fetch to get latest changes without merging
+	if err := wm.fetchRemote(); err != nil {
+		return nil, fmt.Errorf("failed to fetch from %s: %w", wm.config.Remote, err)
+	}
+
+	currentBranch, err := wm.GetCurrentBranch()
+	if err != nil {
+		return nil, err
+	}
+	if currentBranch == "HEAD" {
+		return nil, fmt.Errorf("HEAD is detached; check out a branch to sync")
+	}
+
+	upstream := wm.remoteRef(currentBranch)
+	result := &SyncResult{Branch: currentBranch, Upstream: upstream}
+
+	// A branch that was never pushed has nothing to bring in
+	if _, err := wm.git("rev-parse", "--verify", "--quiet", "refs/remotes/"+upstream); err != nil {
+		if exitCode(err) != 1 {
+			return nil, fmt.Errorf("failed to look up %s: %w", upstream, err)
+		}
+		wm.logf("%s does not exist yet", upstream)
+		result.Action = SyncNoUpstream
+		if options.Push {
+			if _, err := wm.git("push", "-u", wm.config.Remote, currentBranch); err != nil {
+				return result, fmt.Errorf("failed to push %s: %w", currentBranch, err)
+			}
+			result.Pushed = true
+		}
+		return result, nil
+	}
+
+	result.Ahead, result.Behind, err = wm.aheadBehind(upstream, currentBranch)
+	if err != nil {
+		return nil, err
+	}
+	wm.logf("%s is %d commit(s) ahead of and %d behind %s", currentBranch, result.Ahead, result.Behind, upstream)
+
+	switch {
+	case result.Behind > 0:
+		integrate := func() error { return wm.integrateUpstream(result, strategy) }
+		if options.Autostash {
+			err = wm.autostash("sync", integrate)
+		} else if err = wm.requireCleanTrackedFiles("commit or stash them before syncing, or sync with --autostash"); err == nil {
+			err = integrate()
+		}
+		if err != nil {
+			return result, err
+		}
+
+	case result.Ahead > 0:
+		result.Action = SyncAhead
+		if !options.Push && options.Strict {
+			return result, refused(ErrAheadOfRemote, "%s is %d commit(s) ahead of %s; push with 'vamosGitWF sync --push'",
+				currentBranch, result.Ahead, upstream)
+		}
+
+	default:
+		result.Action = SyncUpToDate
+	}
+
+	if options.Push && result.Ahead > 0 {
+		if _, err := wm.git("push", wm.config.Remote, currentBranch); err != nil {
+			return result, fmt.Errorf("failed to push %s: %w", currentBranch, err)
+		}
+		result.Pushed = true
+	}
+	return result, nil
+}
+
+// integrateUpstream brings the commits on result.Upstream into result.Branch,
+// fast-forwarding when it has no commits of its own
+func (wm *WorkflowManager) integrateUpstream(result *SyncResult, strategy string) error {
+	switch {
+	case result.Ahead == 0:
+		if _, err := wm.git("merge", "--ff-only", result.Upstream); err != nil {
+			return fmt.Errorf("failed to fast-forward to %s: %w", result.Upstream, err)
+		}
+		result.Action = SyncFastForwarded
+
+	case strategy == SyncRebase:
+		wm.logf("%s has diverged from %s; rebasing", result.Branch, result.Upstream)
+		if err := wm.backupBefore("rebase onto " + result.Upstream); err != nil {
+			return err
+		}
+		if _, err := wm.git("rebase", result.Upstream); err != nil {
+			return wm.conflictOrError(err, fmt.Sprintf("failed to rebase onto %s", result.Upstream))
+		}
+		result.Action = SyncRebased
+
+	default:
+		wm.logf("%s has diverged from %s; merging", result.Branch, result.Upstream)
+		if _, err := wm.git("merge", "--no-edit", result.Upstream); err != nil {
+			return wm.conflictOrError(err, fmt.Sprintf("failed to merge %s", result.Upstream))
+		}
+		result.Action = SyncMerged
+	}
+	return nil
+}
+
+// aheadBehind counts the commits only on branch and only on upstream
+func (wm *WorkflowManager) aheadBehind(upstream, branch string) (int, int, error) {
+	// The left side counts commits only on upstream, the right side those only on branch
+	output, err := wm.gitOutput("rev-list", "--left-right", "--count", fmt.Sprintf("%s...%s", upstream, branch))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to compare %s with %s: %w", branch, upstream, err)
+	}
+
+	parts := strings.Fields(output)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("unexpected output from rev-list: %q", output)
+	}
+	behind, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("unexpected output from rev-list: %q", output)
+	}
+	ahead, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("unexpected output from rev-list: %q", output)
+	}
+	return ahead, behind, nil
+}
+
+// requireCleanTrackedFiles refuses to continue while tracked files have
+// uncommitted changes, telling the user what to do with advice. Untracked files
+// are allowed, and ignored files are never reported.
+func (wm *WorkflowManager) requireCleanTrackedFiles(advice string) error {
+	changes, err := wm.ChangedFiles()
+	if err != nil {
+		return err
+	}
+
+	var dirty []string
+	for _, change := range changes {
+		if !change.Untracked() {
+			dirty = append(dirty, change.Path)
+		}
+	}
+	if len(dirty) > 0 {
+		return refused(ErrDirtyWorktree, "uncommitted changes in %s; %s", strings.Join(dirty, ", "), advice)
+	}
+	return nil
+}
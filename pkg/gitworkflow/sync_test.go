@@ -0,0 +1,227 @@
+package gitworkflow
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const (
+	upstreamCommand = "git rev-parse --verify --quiet refs/remotes/origin/W-1"
+	countCommand    = "git rev-list --left-right --count origin/W-1...W-1"
+)
+
+// syncRepo returns an executor on branch W-1 whose rev-list against origin/W-1
+// prints counts, i.e. "<behind>\t<ahead>"
+func syncRepo(counts string) *RecordingExecutor {
+	return NewRecordingExecutor().
+		OnOutput("git rev-parse --abbrev-ref HEAD", "W-1\n").
+		OnOutput(countCommand, counts)
+}
+
+func TestSync(t *testing.T) {
+	prelude := []string{"git fetch origin", "git rev-parse --abbrev-ref HEAD", upstreamCommand, countCommand}
+	rebaseBackup := "git commit-tree 1111111aaaa^{tree} -p 1111111aaaa -m " + backupMessage("W-1", "rebase onto origin/W-1")
+
+	tests := []struct {
+		name         string
+		executor     *RecordingExecutor
+		options      SyncOptions
+		wantAction   SyncAction
+		wantPushed   bool
+		wantCommands []string
+	}{
+		{
+			name:         "up to date",
+			executor:     syncRepo("0\t0\n"),
+			wantAction:   SyncUpToDate,
+			wantCommands: prelude,
+		},
+		{
+			name:         "ahead pushes",
+			executor:     syncRepo("0\t2\n"),
+			options:      SyncOptions{Push: true},
+			wantAction:   SyncAhead,
+			wantPushed:   true,
+			wantCommands: append(prelude, "git push origin W-1"),
+		},
+		{
+			name:         "behind fast-forwards with untracked files present",
+			executor:     syncRepo("3\t0\n").OnOutput(statusCommand, "?? notes.txt\x00"),
+			wantAction:   SyncFastForwarded,
+			wantCommands: append(prelude, statusCommand, "git merge --ff-only origin/W-1"),
+		},
+		{
+			name: "diverged rebases after a backup",
+			executor: syncRepo("3\t2\n").
+				OnOutput("git rev-parse --verify --quiet HEAD", "1111111aaaa\n").
+				OnOutput(rebaseBackup, "2222222bbbb\n"),
+			wantAction: SyncRebased,
+			wantCommands: append(prelude,
+				statusCommand,
+				"git rev-parse --verify --quiet HEAD",
+				"git rev-parse --abbrev-ref HEAD",
+				"git stash create",
+				rebaseBackup,
+				"git update-ref refs/vamos/backup/20261016-142501-2222222 2222222bbbb",
+				"git rebase origin/W-1",
+			),
+		},
+		{
+			name:         "diverged merges and pushes",
+			executor:     syncRepo("3\t2\n"),
+			options:      SyncOptions{Strategy: SyncMerge, Push: true},
+			wantAction:   SyncMerged,
+			wantPushed:   true,
+			wantCommands: append(prelude, statusCommand, "git merge --no-edit origin/W-1", "git push origin W-1"),
+		},
+		{
+			name:         "missing upstream",
+			executor:     syncRepo("").OnFailure(upstreamCommand, 1, ""),
+			wantAction:   SyncNoUpstream,
+			wantCommands: prelude[:3],
+		},
+		{
+			name:         "missing upstream is published",
+			executor:     syncRepo("").OnFailure(upstreamCommand, 1, ""),
+			options:      SyncOptions{Push: true},
+			wantAction:   SyncNoUpstream,
+			wantPushed:   true,
+			wantCommands: append(prelude[:3:3], "git push -u origin W-1"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wm := NewWorkflowManagerWithExecutor(tt.executor)
+			wm.now = fixedNow
+			result, err := wm.Sync(tt.options)
+			if err != nil {
+				t.Fatalf("Sync() unexpected error: %v", err)
+			}
+			if result.Action != tt.wantAction || result.Pushed != tt.wantPushed {
+				t.Errorf("Sync() = %s (pushed %v), want %s (pushed %v)", result.Action, result.Pushed, tt.wantAction, tt.wantPushed)
+			}
+			assertCommands(t, tt.executor, tt.wantCommands)
+		})
+	}
+}
+
+func TestSyncAheadWithoutPush(t *testing.T) {
+	executor := syncRepo("0\t2\n")
+	result, err := NewWorkflowManagerWithExecutor(executor).Sync(SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() unexpected error: %v", err)
+	}
+	if result.Action != SyncAhead || result.Ahead != 2 || result.Pushed {
+		t.Errorf("Sync() result = %+v, want ahead by 2 and not pushed", result)
+	}
+	if !strings.Contains(result.Summary(), "sync --push") {
+		t.Errorf("Summary %q does not say how to push", result.Summary())
+	}
+}
+
+func TestSyncAheadStrict(t *testing.T) {
+	executor := syncRepo("0\t2\n")
+	result, err := NewWorkflowManagerWithExecutor(executor).Sync(SyncOptions{Strict: true})
+	if !errors.Is(err, ErrAheadOfRemote) {
+		t.Fatalf("Sync() error = %v, want ErrAheadOfRemote", err)
+	}
+	if !strings.Contains(err.Error(), "sync --push") {
+		t.Errorf("Error %q does not say how to push", err)
+	}
+	if result == nil || result.Action != SyncAhead || result.Ahead != 2 || result.Pushed {
+		t.Errorf("Sync() result = %+v, want ahead by 2 and not pushed", result)
+	}
+	if code, exitCode := ClassifyError(err); code != CodeAheadOfRemote || exitCode != ExitAheadOfRemote {
+		t.Errorf("ClassifyError() = %s, %d, want %s, %d", code, exitCode, CodeAheadOfRemote, ExitAheadOfRemote)
+	}
+}
+
+func TestSyncUsesConfiguredStrategy(t *testing.T) {
+	executor := syncRepo("1\t1\n")
+	config := DefaultConfig()
+	config.Sync.Strategy = SyncMerge
+	wm := NewWorkflowManagerWithConfig(config, executor)
+
+	result, err := wm.Sync(SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() unexpected error: %v", err)
+	}
+	if result.Action != SyncMerged {
+		t.Errorf("Expected a merge, got %s", result.Action)
+	}
+}
+
+func TestSyncRefusesDirtyTrackedFiles(t *testing.T) {
+	executor := syncRepo("2\t0\n").OnOutput(statusCommand, " M main.go\x00?? notes.txt\x00")
+	_, err := NewWorkflowManagerWithExecutor(executor).Sync(SyncOptions{})
+	if !errors.Is(err, ErrDirtyWorktree) {
+		t.Fatalf("Sync() error = %v, want %v", err, ErrDirtyWorktree)
+	}
+	if !strings.Contains(err.Error(), "main.go") || strings.Contains(err.Error(), "notes.txt") {
+		t.Errorf("Expected only tracked files in the error, got %v", err)
+	}
+	for _, command := range executor.Commands {
+		if strings.HasPrefix(command, "git merge") {
+			t.Errorf("Expected no merge, got %q", command)
+		}
+	}
+}
+
+func TestSyncIgnoresDirtyFilesWhenNothingComesIn(t *testing.T) {
+	executor := syncRepo("0\t1\n").OnOutput(statusCommand, " M main.go\x00")
+	result, err := NewWorkflowManagerWithExecutor(executor).Sync(SyncOptions{Push: true})
+	if err != nil || result.Action != SyncAhead || !result.Pushed {
+		t.Fatalf("Sync() = %v, %v, want %s and pushed", result, err, SyncAhead)
+	}
+}
+
+func TestSyncRebaseConflict(t *testing.T) {
+	// The worktree is clean until the rebase stops
+	executor := conflictRepo(t, "rebase-merge", "").
+		OnOutput(statusCommand, conflictedStatus).
+		OnOutput("git rev-parse --abbrev-ref HEAD", "W-1\n").
+		OnOutput(countCommand, "1\t1\n").
+		OnFailure("git rebase origin/W-1", 1, "CONFLICT (content): Merge conflict in main.go\nerror: could not apply abc123... feat: login")
+	wm := NewWorkflowManagerWithExecutor(executor)
+	wm.now = fixedNow
+
+	_, err := wm.Sync(SyncOptions{})
+	var conflictErr *ConflictError
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("Sync() error = %v, want a *ConflictError", err)
+	}
+}
+
+func TestSyncStrategyValidation(t *testing.T) {
+	executor := NewRecordingExecutor()
+	_, err := NewWorkflowManagerWithExecutor(executor).Sync(SyncOptions{Strategy: "squash"})
+	if err == nil || !strings.Contains(err.Error(), "unknown sync strategy") {
+		t.Fatalf("Sync() error = %v, want unknown strategy", err)
+	}
+	assertCommands(t, executor, nil)
+}
+
+func TestSyncResultSummary(t *testing.T) {
+	tests := []struct {
+		result SyncResult
+		want   string
+	}{
+		{SyncResult{Action: SyncUpToDate}, "W-1 is up to date with origin/W-1"},
+		{SyncResult{Action: SyncAhead, Ahead: 2}, "W-1 is 2 commit(s) ahead of origin/W-1; run 'vamosGitWF sync --push' to push"},
+		{SyncResult{Action: SyncAhead, Ahead: 2, Pushed: true}, "Pushed 2 commit(s) from W-1 to origin/W-1"},
+		{SyncResult{Action: SyncFastForwarded, Behind: 3}, "Fast-forwarded W-1 by 3 commit(s) from origin/W-1"},
+		{SyncResult{Action: SyncRebased, Ahead: 1, Behind: 3}, "Rebased 1 local commit(s) of W-1 onto 3 new commit(s) from origin/W-1; run 'vamosGitWF sync --push' to push"},
+		{SyncResult{Action: SyncMerged, Ahead: 1, Behind: 3, Pushed: true}, "Merged 3 new commit(s) from origin/W-1 into W-1 and pushed to origin/W-1"},
+		{SyncResult{Action: SyncNoUpstream}, "origin/W-1 does not exist yet; run 'vamosGitWF sync --push' to publish W-1"},
+		{SyncResult{Action: SyncNoUpstream, Pushed: true}, "Published W-1 as origin/W-1"},
+	}
+
+	for _, tt := range tests {
+		tt.result.Branch, tt.result.Upstream = "W-1", "origin/W-1"
+		if got := tt.result.Summary(); got != tt.want {
+			t.Errorf("Summary() = %q, want %q", got, tt.want)
+		}
+	}
+}
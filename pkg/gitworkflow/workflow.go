@@ -1,99 +1,129 @@
 package gitworkflow
 
 import (
+	"errors"
 	"fmt"
-	"os/exec"
-	"strconv"
+	"io"
 	"strings"
+	"time"
 )
 
 // WorkflowManager handles git workflow operations
 type WorkflowManager struct {
-	// Add configuration fields as needed
+	config     Config
+	executor   CommandExecutorInterface
+	baseBranch string
+	now        func() time.Time
+	logger     io.Writer
+	dryRun     bool
+	// pullRequests opens pull requests; nil until SetPullRequestClient is called
+	pullRequests PullRequestClientInterface
+	// generator suggests commit messages and pull request descriptions; nil until SetTextGenerator is called
+	generator TextGeneratorInterface
 }
 
 // NewWorkflowManager creates a new WorkflowManager instance
 func NewWorkflowManager() *WorkflowManager {
-	return &WorkflowManager{}
+	return NewWorkflowManagerWithExecutor(NewExecExecutor(""))
 }
 
-// SyncWithRemote syncs the current branch with remote
-func (wm *WorkflowManager) SyncWithRemote() error {
-	// First fetch to get latest changes without merging
-	if err := wm.fetchOrigin(); err != nil {
-		return fmt.Errorf("failed to fetch from origin: %w", err)
-	}
+// NewWorkflowManagerWithExecutor creates a new WorkflowManager that runs git through executor
+func NewWorkflowManagerWithExecutor(executor CommandExecutorInterface) *WorkflowManager {
+	return NewWorkflowManagerWithConfig(DefaultConfig(), executor)
+}
 
-	// Get current branch name
-	currentBranch, err := wm.GetCurrentBranch()
-	if err != nil {
-		return fmt.Errorf("failed to get current branch: %w", err)
+// NewWorkflowManagerWithConfig creates a new WorkflowManager that follows config and runs git through executor
+func NewWorkflowManagerWithConfig(config Config, executor CommandExecutorInterface) *WorkflowManager {
+	config = config.withDefaults()
+	return &WorkflowManager{
+		config:     config,
+		executor:   executor,
+		baseBranch: config.BaseBranch,
+		now:        time.Now,
 	}
+}
 
-	// Check if there are uncommitted changes (ignoring untracked files)
-	cmd := exec.Command("git", "diff-files", "--quiet")
-	if err := cmd.Run(); err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
-			return fmt.Errorf("uncommitted changes detected. Please commit or stash your changes before syncing")
-		}
-		return fmt.Errorf("failed to check git status: %w", err)
-	}
+// Config returns the configuration the WorkflowManager follows
+func (wm *WorkflowManager) Config() Config {
+	return wm.config
+}
 
-	// Check if there are staged changes
-	cmd = exec.Command("git", "diff-index", "--quiet", "--cached", "HEAD")
-	if err := cmd.Run(); err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
-			return fmt.Errorf("staged changes detected. Please commit your changes before syncing")
-		}
-		return fmt.Errorf("failed to check git status: %w", err)
+// Remote returns the name of the remote the WorkflowManager fetches from and pushes to
+func (wm *WorkflowManager) Remote() string {
+	return wm.config.Remote
+}
+
+// BaseBranch returns the configured or detected base branch
+func (wm *WorkflowManager) BaseBranch() (string, error) {
+	return wm.getDefaultBranch()
+}
+
+// remoteRef returns the remote-tracking ref for branch, e.g. origin/main
+func (wm *WorkflowManager) remoteRef(branch string) string {
+	return fmt.Sprintf("%s/%s", wm.config.Remote, branch)
+}
+
+// git runs a git command through the configured executor. A failed command is
+// returned as a *GitError carrying git's stderr.
+func (wm *WorkflowManager) git(args ...string) (CommandResult, error) {
+	result, err := wm.executor.Execute("git", args...)
+	if err != nil {
+		return result, newGitError(err)
 	}
+	return result, nil
+}
 
-	// Check if local branch has diverged from remote
-	cmd = exec.Command("git", "rev-list", "--left-right", "--count", fmt.Sprintf("origin/%s...%s", currentBranch, currentBranch))
-	output, err := cmd.Output()
+// gitOutput runs a git command and returns its trimmed stdout
+func (wm *WorkflowManager) gitOutput(args ...string) (string, error) {
+	result, err := wm.git(args...)
 	if err != nil {
-		// If the remote branch doesn't exist yet, that's okay - we'll create it later
-		if strings.Contains(err.Error(), "unknown revision") {
-			return nil
-		}
-		return fmt.Errorf("failed to check branch divergence: %w", err)
+		return "", err
 	}
+	return strings.TrimSpace(result.Stdout), nil
+}
 
-	// Parse the output (format: "X\tY" where X is commits ahead, Y is commits behind)
-	parts := strings.Fields(string(output))
-	if len(parts) != 2 {
-		return fmt.Errorf("unexpected output from rev-list command")
+// exitCode returns the exit code carried by err, or -1 if err is not an ExitError
+func exitCode(err error) int {
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode
 	}
+	return -1
+}
 
-	ahead, _ := strconv.Atoi(parts[0])
-	behind, _ := strconv.Atoi(parts[1])
+// getDefaultBranch returns the configured base branch, or detects it from the
+// remote's HEAD and finally by checking whether 'main' or 'master' exists
+func (wm *WorkflowManager) getDefaultBranch() (string, error) {
+	if wm.baseBranch != "" {
+		return wm.baseBranch, nil
+	}
 
-	if ahead > 0 {
-		return fmt.Errorf("local branch is ahead of remote by %d commits. Please push your changes first using 'git push' or 'make story-push'", ahead)
+	branch, err := wm.detectDefaultBranch()
+	if err != nil {
+		return "", err
 	}
+	wm.logf("detected base branch %s", branch)
+	wm.baseBranch = branch
+	return branch, nil
+}
 
-	if behind > 0 {
-		// Pull changes from remote
-		cmd = exec.Command("git", "pull", "--rebase")
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("failed to pull changes: %w", err)
+// detectDefaultBranch inspects the repository to find its default branch
+func (wm *WorkflowManager) detectDefaultBranch() (string, error) {
+	// Prefer the branch the remote's HEAD points at, e.g. refs/remotes/origin/HEAD -> origin/develop
+	remoteHead, err := wm.gitOutput("symbolic-ref", "--quiet", "--short", fmt.Sprintf("refs/remotes/%s/HEAD", wm.config.Remote))
+	if err == nil {
+		if branch := strings.TrimPrefix(remoteHead, wm.config.Remote+"/"); branch != "" && branch != remoteHead {
+			return branch, nil
 		}
 	}
 
-	return nil
-}
-
-// getDefaultBranch determines whether the repository uses 'main' or 'master'
-func (wm *WorkflowManager) getDefaultBranch() (string, error) {
 	// Try to get the ref for main
-	cmdMain := exec.Command("git", "rev-parse", "--verify", "refs/heads/main")
-	if err := cmdMain.Run(); err == nil {
+	if _, err := wm.git("rev-parse", "--verify", "refs/heads/main"); err == nil {
 		return "main", nil
 	}
 
 	// Try to get the ref for master
-	cmdMaster := exec.Command("git", "rev-parse", "--verify", "refs/heads/master")
-	if err := cmdMaster.Run(); err == nil {
+	if _, err := wm.git("rev-parse", "--verify", "refs/heads/master"); err == nil {
 		return "master", nil
 	}
 
@@ -114,142 +144,176 @@ func (wm *WorkflowManager) SyncMainBranch() error {
 	}
 
 	// Pull latest changes
-	if err := wm.pullLatest(); err != nil {
+	if err := wm.pullLatest(defaultBranch); err != nil {
 		return fmt.Errorf("failed to pull latest changes: %w", err)
 	}
 
 	return nil
 }
 
-// ResolveConflictsRebase resolves conflicts by rebasing onto origin/main
+// ResolveConflictsRebase brings the current branch up to date by rebasing onto the
+// remote base branch. If the rebase stops on conflicts a *ConflictError is returned
+// and the rebase is left in progress for ResolveFile, ContinueResolve or AbortResolve.
 func (wm *WorkflowManager) ResolveConflictsRebase() error {
-	// Fetch latest changes
-	if err := wm.fetchOrigin(); err != nil {
-		return fmt.Errorf("failed to fetch from origin: %w", err)
+	upstream, err := wm.fetchBaseBranch()
+	if err != nil {
+		return err
+	}
+
+	if err := wm.backupBefore("rebase onto " + upstream); err != nil {
+		return err
 	}
 
-	// Rebase onto origin/main
-	cmd := exec.Command("git", "rebase", "origin/main")
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to rebase onto origin/main: %w", err)
+	// Rebase onto the remote base branch
+	if _, err := wm.git("rebase", upstream); err != nil {
+		return wm.conflictOrError(err, fmt.Sprintf("failed to rebase onto %s", upstream))
 	}
 
 	return nil
 }
 
-// ResolveConflictsMerge resolves conflicts by merging origin/main
+// ResolveConflictsMerge brings the current branch up to date by merging the remote
+// base branch. Conflicts are reported the same way as by ResolveConflictsRebase.
 func (wm *WorkflowManager) ResolveConflictsMerge() error {
-	// Fetch latest changes
-	if err := wm.fetchOrigin(); err != nil {
-		return fmt.Errorf("failed to fetch from origin: %w", err)
+	upstream, err := wm.fetchBaseBranch()
+	if err != nil {
+		return err
 	}
 
-	// Merge origin/main
-	cmd := exec.Command("git", "merge", "origin/main")
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to merge origin/main: %w", err)
+	// Merge the remote base branch
+	if _, err := wm.git("merge", upstream); err != nil {
+		return wm.conflictOrError(err, fmt.Sprintf("failed to merge %s", upstream))
 	}
 
 	return nil
 }
 
-// Helper function to fetch from origin
-func (wm *WorkflowManager) fetchOrigin() error {
-	cmd := exec.Command("git", "fetch", "origin")
-	return cmd.Run()
+// fetchBaseBranch fetches the latest changes and returns the remote-tracking base ref
+func (wm *WorkflowManager) fetchBaseBranch() (string, error) {
+	baseBranch, err := wm.getDefaultBranch()
+	if err != nil {
+		return "", err
+	}
+
+	// Fetch latest changes
+	if err := wm.fetchRemote(); err != nil {
+		return "", fmt.Errorf("failed to fetch from %s: %w", wm.config.Remote, err)
+	}
+
+	return wm.remoteRef(baseBranch), nil
+}
+
+// Helper function to fetch from the configured remote
+func (wm *WorkflowManager) fetchRemote() error {
+	_, err := wm.git("fetch", wm.config.Remote)
+	return err
 }
 
 // Helper function to checkout a branch
 func (wm *WorkflowManager) checkoutBranch(branchName string) error {
-	cmd := exec.Command("git", "checkout", branchName)
-	return cmd.Run()
+	_, err := wm.git("checkout", branchName)
+	return err
+}
+
+// Helper function to pull latest changes for branch from the configured remote
+func (wm *WorkflowManager) pullLatest(branch string) error {
+	_, err := wm.git("pull", wm.config.Remote, branch)
+	return err
 }
 
-// Helper function to pull latest changes
-func (wm *WorkflowManager) pullLatest() error {
-	cmd := exec.Command("git", "pull")
-	return cmd.Run()
+// StartOptions controls how StartStory creates a story branch
+type StartOptions struct {
+	// Autostash stashes uncommitted changes, including untracked files, before
+	// switching branches and reapplies them on the new story branch
+	Autostash bool
 }
 
-// CreateStoryBranch creates a new story branch from the main branch
-func (wm *WorkflowManager) CreateStoryBranch(storyID string, description string) error {
+// CreateStoryBranch creates a new story branch from the main branch and returns its name
+func (wm *WorkflowManager) CreateStoryBranch(storyID string, description string) (string, error) {
+	return wm.StartStory(storyID, description, StartOptions{})
+}
+
+// StartStory creates a new story branch from the latest base branch and returns its name
+func (wm *WorkflowManager) StartStory(storyID string, description string, options StartOptions) (string, error) {
 	// Format the branch name
-	var branchName string
-	if description != "" {
-		branchName = fmt.Sprintf("W-%s-%s", storyID, strings.ToLower(strings.ReplaceAll(description, " ", "-")))
-	} else {
-		branchName = fmt.Sprintf("W-%s", storyID)
+	branchName, err := wm.StoryBranchName(storyID, description)
+	if err != nil {
+		return "", err
 	}
 
 	// Ensure we're on main branch
 	defaultBranch, err := wm.getDefaultBranch()
 	if err != nil {
-		return err
+		return "", err
 	}
+	wm.logf("creating %s from the latest %s", branchName, defaultBranch)
 
-	if err := wm.checkoutBranch(defaultBranch); err != nil {
-		return fmt.Errorf("failed to checkout %s branch: %w", defaultBranch, err)
-	}
+	start := func() error {
+		if err := wm.checkoutBranch(defaultBranch); err != nil {
+			return fmt.Errorf("failed to checkout %s branch: %w", defaultBranch, err)
+		}
 
-	// Pull latest changes
-	if err := wm.pullLatest(); err != nil {
-		return fmt.Errorf("failed to pull latest changes: %w", err)
-	}
+		// Pull latest changes
+		if err := wm.pullLatest(defaultBranch); err != nil {
+			return fmt.Errorf("failed to pull latest changes: %w", err)
+		}
 
-	// Create and checkout new story branch
-	cmd := exec.Command("git", "checkout", "-b", branchName)
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to create story branch: %w", err)
+		// Create and checkout new story branch
+		if _, err := wm.git("checkout", "-b", branchName); err != nil {
+			return fmt.Errorf("failed to create story branch: %w", err)
+		}
+		head, err := wm.gitOutput("rev-parse", "--verify", "HEAD")
+		if err != nil {
+			return fmt.Errorf("failed to resolve HEAD: %w", err)
+		}
+		return wm.recordStoryStart(branchName, head)
 	}
 
-	return nil
+	if options.Autostash {
+		return branchName, wm.autostash("story-start "+branchName, start)
+	}
+	return branchName, start()
 }
 
-// CommitChanges creates a commit with a formatted message
+// CommitChanges creates a commit with a formatted message using the default commit type
 func (wm *WorkflowManager) CommitChanges(scope string, description string) error {
-	// Format the commit message
-	commitMessage := fmt.Sprintf("feat(%s): %s", scope, description)
+	_, err := wm.CommitStory(CommitMessage{Scope: scope, Description: description}, StageOptions{})
+	return err
+}
 
-	// Add all changes
-	addCmd := exec.Command("git", "add", ".")
-	if err := addCmd.Run(); err != nil {
-		return fmt.Errorf("failed to add changes: %w", err)
+// CommitStory stages the changes selected by staging and commits them with a
+// Conventional Commits message
+func (wm *WorkflowManager) CommitStory(message CommitMessage, staging StageOptions) (CommitMessage, error) {
+	// Validate the message before touching the index
+	message, err := wm.prepareCommitMessage(message)
+	if err != nil {
+		return message, err
 	}
 
-	// Create the commit
-	commitCmd := exec.Command("git", "commit", "-m", commitMessage)
-	if err := commitCmd.Run(); err != nil {
-		return fmt.Errorf("failed to create commit: %w", err)
+	// Stage the selected changes
+	if _, err := wm.stageForCommit(staging); err != nil {
+		return message, err
 	}
 
-	return nil
+	// Create the commit
+	return message, wm.commit(message)
 }
 
 // PushStoryBranch pushes the current story branch to remote
 func (wm *WorkflowManager) PushStoryBranch() error {
-	// Get current branch name
-	branchName, err := wm.GetCurrentBranch()
-	if err != nil {
-		return fmt.Errorf("failed to get current branch: %w", err)
-	}
-
-	// Push the branch to remote
-	cmd := exec.Command("git", "push", "-u", "origin", branchName)
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to push branch: %w", err)
-	}
-
-	return nil
+	return wm.PushStory(PushOptions{})
 }
 
 // validateBranchName checks if the branch name follows the convention
 func (wm *WorkflowManager) validateBranchName(branchName string) error {
-	if !strings.HasPrefix(branchName, "W-") {
-		return fmt.Errorf("branch name must follow the format: W-STORY_ID (e.g., W-123)")
+	prefix := wm.branchIDPrefix()
+
+	if !strings.HasPrefix(branchName, prefix) {
+		return fmt.Errorf("branch name must follow the format: %sSTORY_ID (e.g., %s123)", prefix, prefix)
 	}
 
-	// Check if there's a story ID after the "W-" prefix
-	parts := strings.SplitN(branchName[2:], "-", 2) // Skip "W-" prefix and split the rest
+	// Check if there's a story ID after the prefix
+	parts := strings.SplitN(branchName[len(prefix):], "-", 2) // Skip the prefix and split the rest
 	if len(parts) == 0 || parts[0] == "" {
 		return fmt.Errorf("branch name must include a story ID")
 	}
@@ -257,6 +321,15 @@ func (wm *WorkflowManager) validateBranchName(branchName string) error {
 	return nil
 }
 
+// branchIDPrefix returns the fixed part of the branch template before {id}, e.g. "W-"
+func (wm *WorkflowManager) branchIDPrefix() string {
+	template := strings.ReplaceAll(wm.config.Branch.Template, placeholderPrefix, wm.config.Branch.Prefix)
+	if idx := strings.Index(template, placeholderID); idx >= 0 {
+		return template[:idx]
+	}
+	return wm.config.Branch.Prefix
+}
+
 // CreateFeatureBranch creates a new feature branch from the main branch
 func (wm *WorkflowManager) CreateFeatureBranch(branchName string) error {
 	// Validate branch name format
@@ -264,64 +337,53 @@ func (wm *WorkflowManager) CreateFeatureBranch(branchName string) error {
 		return err
 	}
 
-	// Ensure we're on main branch
-	if err := wm.checkoutBranch("main"); err != nil {
-		return fmt.Errorf("failed to checkout main branch: %w", err)
+	// Ensure we're on the base branch
+	baseBranch, err := wm.getDefaultBranch()
+	if err != nil {
+		return err
+	}
+
+	if err := wm.checkoutBranch(baseBranch); err != nil {
+		return fmt.Errorf("failed to checkout %s branch: %w", baseBranch, err)
 	}
 
 	// Pull latest changes
-	if err := wm.pullLatest(); err != nil {
+	if err := wm.pullLatest(baseBranch); err != nil {
 		return fmt.Errorf("failed to pull latest changes: %w", err)
 	}
 
 	// Create and checkout new branch
-	cmd := exec.Command("git", "checkout", "-b", branchName)
-	if err := cmd.Run(); err != nil {
+	if _, err := wm.git("checkout", "-b", branchName); err != nil {
 		return fmt.Errorf("failed to create branch: %w", err)
 	}
 
 	return nil
 }
 
-// CreatePullRequest prepares a branch for pull request submission
-func (wm *WorkflowManager) CreatePullRequest(branchName string) error {
-	// Ensure we're on the feature branch
-	if err := wm.checkoutBranch(branchName); err != nil {
-		return fmt.Errorf("failed to checkout feature branch: %w", err)
-	}
-
-	// Push the branch to remote
-	cmd := exec.Command("git", "push", "-u", "origin", branchName)
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to push branch: %w", err)
-	}
-
-	return nil
-}
-
 // GetCurrentBranch returns the name of the current git branch
 func (wm *WorkflowManager) GetCurrentBranch() (string, error) {
-	cmd := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
-	output, err := cmd.Output()
+	output, err := wm.gitOutput("rev-parse", "--abbrev-ref", "HEAD")
 	if err != nil {
 		return "", fmt.Errorf("failed to get current branch: %w", err)
 	}
-	return strings.TrimSpace(string(output)), nil
+	return output, nil
 }
 
 // UndoLastCommit undoes the last commit, keeping changes in working directory
 func (wm *WorkflowManager) UndoLastCommit() error {
-	cmd := exec.Command("git", "reset", "HEAD~1")
-	if err := cmd.Run(); err != nil {
+	if _, err := wm.git("reset", "HEAD~1"); err != nil {
 		return fmt.Errorf("failed to undo last commit: %w", err)
 	}
 	return nil
 }
 
-// UndoLastCommitHard undoes the last commit and discards changes
+// UndoLastCommitHard undoes the last commit and discards changes. A backup is
+// taken first so the commit and changes can be restored.
 func (wm *WorkflowManager) UndoLastCommitHard() error {
-	cmd := exec.Command("git", "reset", "--hard", "HEAD~1")
-	if err := cmd.Run(); err != nil {
+	if err := wm.backupBefore("undo --hard"); err != nil {
+		return err
+	}
+	if _, err := wm.git("reset", "--hard", "HEAD~1"); err != nil {
 		return fmt.Errorf("failed to undo last commit (hard): %w", err)
 	}
 	return nil
@@ -329,8 +391,7 @@ func (wm *WorkflowManager) UndoLastCommitHard() error {
 
 // RevertCommit creates a new commit that undoes the changes of a specific commit
 func (wm *WorkflowManager) RevertCommit(commitHash string) error {
-	cmd := exec.Command("git", "revert", commitHash)
-	if err := cmd.Run(); err != nil {
+	if _, err := wm.git("revert", commitHash); err != nil {
 		return fmt.Errorf("failed to revert commit %s: %w", commitHash, err)
 	}
 	return nil
@@ -338,8 +399,7 @@ func (wm *WorkflowManager) RevertCommit(commitHash string) error {
 
 // CreateTag creates a new tag at the current commit
 func (wm *WorkflowManager) CreateTag(version, message string) error {
-	cmd := exec.Command("git", "tag", "-a", version, "-m", message)
-	if err := cmd.Run(); err != nil {
+	if _, err := wm.git("tag", "-a", version, "-m", message); err != nil {
 		return fmt.Errorf("failed to create tag %s: %w", version, err)
 	}
 	return nil
@@ -347,8 +407,7 @@ func (wm *WorkflowManager) CreateTag(version, message string) error {
 
 // PushTag pushes a specific tag to remote
 func (wm *WorkflowManager) PushTag(version string) error {
-	cmd := exec.Command("git", "push", "origin", version)
-	if err := cmd.Run(); err != nil {
+	if _, err := wm.git("push", wm.config.Remote, version); err != nil {
 		return fmt.Errorf("failed to push tag %s: %w", version, err)
 	}
 	return nil
@@ -356,8 +415,7 @@ func (wm *WorkflowManager) PushTag(version string) error {
 
 // PushAllTags pushes all tags to remote
 func (wm *WorkflowManager) PushAllTags() error {
-	cmd := exec.Command("git", "push", "origin", "--tags")
-	if err := cmd.Run(); err != nil {
+	if _, err := wm.git("push", wm.config.Remote, "--tags"); err != nil {
 		return fmt.Errorf("failed to push all tags: %w", err)
 	}
 	return nil
@@ -365,12 +423,11 @@ func (wm *WorkflowManager) PushAllTags() error {
 
 // GetLastCommitHash returns the hash of the last commit
 func (wm *WorkflowManager) GetLastCommitHash() (string, error) {
-	cmd := exec.Command("git", "rev-parse", "HEAD")
-	output, err := cmd.Output()
+	output, err := wm.gitOutput("rev-parse", "HEAD")
 	if err != nil {
 		return "", fmt.Errorf("failed to get last commit hash: %w", err)
 	}
-	return strings.TrimSpace(string(output)), nil
+	return output, nil
 }
 
 // PrintExample prints out an end-to-end example of the git workflow
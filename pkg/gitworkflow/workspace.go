@@ -0,0 +1,241 @@
+package gitworkflow
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// WorkspaceFileName is the workspace file looked for in the current directory
+const WorkspaceFileName = "vamos-workspace.yaml"
+
+// DefaultWorkspaceParallelism is how many repositories are worked on at once when none is configured
+const DefaultWorkspaceParallelism = 4
+
+// WorkspaceConfig lists repositories that are checked out side by side and
+// worked on together
+type WorkspaceConfig struct {
+	// Repos are the repository directories, relative to the workspace file
+	Repos []string `yaml:"repos"`
+	// Parallelism limits how many repositories are worked on at once
+	Parallelism int `yaml:"parallelism,omitempty"`
+}
+
+// LoadWorkspaceFile reads a workspace file and resolves its repository
+// directories relative to the directory the file is in
+func LoadWorkspaceFile(path string) (WorkspaceConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return WorkspaceConfig{}, fmt.Errorf("failed to read workspace file %s: %w", path, err)
+	}
+	var config WorkspaceConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return WorkspaceConfig{}, fmt.Errorf("failed to parse workspace file %s: %w", path, err)
+	}
+
+	var problems []string
+	if len(config.Repos) == 0 {
+		problems = append(problems, "repos must list at least one repository")
+	}
+	if config.Parallelism < 0 {
+		problems = append(problems, fmt.Sprintf("parallelism %d must not be negative", config.Parallelism))
+	}
+	seen := map[string]bool{}
+	for i, repo := range config.Repos {
+		if strings.TrimSpace(repo) == "" {
+			problems = append(problems, "repos entries must not be empty")
+			continue
+		}
+		if !filepath.IsAbs(repo) {
+			repo = filepath.Join(filepath.Dir(path), repo)
+		}
+		repo = filepath.Clean(repo)
+		if seen[repo] {
+			problems = append(problems, fmt.Sprintf("repository %s is listed twice", repo))
+		}
+		seen[repo] = true
+		config.Repos[i] = repo
+	}
+	if len(problems) > 0 {
+		return WorkspaceConfig{}, fmt.Errorf("invalid workspace file %s:\n  - %s", path, strings.Join(problems, "\n  - "))
+	}
+
+	if config.Parallelism == 0 {
+		config.Parallelism = DefaultWorkspaceParallelism
+	}
+	return config, nil
+}
+
+// WorkspaceRepo is one repository of a workspace and the WorkflowManager that works on it
+type WorkspaceRepo struct {
+	// Name identifies the repository in results and output, e.g. its directory name
+	Name    string
+	Manager *WorkflowManager
+}
+
+// RepoResult is the outcome of an operation in one repository
+type RepoResult struct {
+	Repo    string
+	Summary string
+	Err     error
+}
+
+// WorkspaceError reports the repositories in which an operation failed
+type WorkspaceError struct {
+	Results []RepoResult
+}
+
+// Error names the repositories that failed
+func (e *WorkspaceError) Error() string {
+	var failed []string
+	for _, result := range e.Results {
+		if result.Err != nil {
+			failed = append(failed, result.Repo)
+		}
+	}
+	return fmt.Sprintf("%d of %d repositories failed: %s", len(failed), len(e.Results), strings.Join(failed, ", "))
+}
+
+// Workspace runs workflow operations across several repositories at once
+type Workspace struct {
+	repos       []WorkspaceRepo
+	parallelism int
+}
+
+// NewWorkspace creates a Workspace that works on at most parallelism
+// repositories at a time; 0 uses DefaultWorkspaceParallelism
+func NewWorkspace(repos []WorkspaceRepo, parallelism int) *Workspace {
+	if parallelism <= 0 {
+		parallelism = DefaultWorkspaceParallelism
+	}
+	return &Workspace{repos: repos, parallelism: parallelism}
+}
+
+// Repos returns the repositories of the workspace
+func (ws *Workspace) Repos() []WorkspaceRepo {
+	return ws.repos
+}
+
+// EnableDryRun makes every repository only report the commands that would
+// change it. Each line written to out is prefixed with the repository name.
+func (ws *Workspace) EnableDryRun(out io.Writer) {
+	var mu sync.Mutex
+	for _, repo := range ws.repos {
+		repo.Manager.EnableDryRun(&prefixWriter{out: out, prefix: "[" + repo.Name + "] ", mu: &mu})
+	}
+}
+
+// Run calls operation for every repository, running at most the configured
+// number at once, and returns the results in the order of the repositories.
+// Every repository is attempted; if any of them failed a *WorkspaceError is
+// returned along with the results.
+func (ws *Workspace) Run(operation func(wm *WorkflowManager) (string, error)) ([]RepoResult, error) {
+	results := make([]RepoResult, len(ws.repos))
+	slots := make(chan struct{}, ws.parallelism)
+	var wg sync.WaitGroup
+	for i, repo := range ws.repos {
+		wg.Add(1)
+		go func(i int, repo WorkspaceRepo) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			summary, err := operation(repo.Manager)
+			results[i] = RepoResult{Repo: repo.Name, Summary: summary, Err: err}
+		}(i, repo)
+	}
+	wg.Wait()
+
+	for _, result := range results {
+		if result.Err != nil {
+			return results, &WorkspaceError{Results: results}
+		}
+	}
+	return results, nil
+}
+
+// StartStory creates the story branch in every repository
+func (ws *Workspace) StartStory(storyID string, description string, options StartOptions) ([]RepoResult, error) {
+	return ws.Run(func(wm *WorkflowManager) (string, error) {
+		branchName, err := wm.StartStory(storyID, description, options)
+		if err != nil {
+			return "", err
+		}
+		return "created and switched to " + branchName, nil
+	})
+}
+
+// Sync syncs the current branch of every repository with its remote
+func (ws *Workspace) Sync(options SyncOptions) ([]RepoResult, error) {
+	return ws.Run(func(wm *WorkflowManager) (string, error) {
+		result, err := wm.Sync(options)
+		if err != nil {
+			return "", err
+		}
+		return result.Summary(), nil
+	})
+}
+
+// PushStory runs the pre-push checks and pushes the current branch of every repository
+func (ws *Workspace) PushStory(options PushOptions) ([]RepoResult, error) {
+	return ws.Run(func(wm *WorkflowManager) (string, error) {
+		if err := wm.PushStory(options); err != nil {
+			return "", err
+		}
+		branchName, err := wm.GetCurrentBranch()
+		if err != nil {
+			return "", err
+		}
+		return "pushed " + branchName, nil
+	})
+}
+
+// Tag creates the annotated tag version in every repository and, with push,
+// pushes it to each repository's remote
+func (ws *Workspace) Tag(version string, message string, push bool) ([]RepoResult, error) {
+	return ws.Run(func(wm *WorkflowManager) (string, error) {
+		if err := wm.CreateTag(version, message); err != nil {
+			return "", err
+		}
+		if !push {
+			return "created tag " + version, nil
+		}
+		if err := wm.PushTag(version); err != nil {
+			return "", err
+		}
+		return "created tag " + version + " and pushed it to " + wm.Remote(), nil
+	})
+}
+
+// prefixWriter writes whole lines to out with a prefix, holding mu so the
+// lines of repositories worked on at once do not interleave
+type prefixWriter struct {
+	out     io.Writer
+	prefix  string
+	mu      *sync.Mutex
+	partial []byte
+}
+
+// Write buffers p until it completes a line
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+	for {
+		end := bytes.IndexByte(w.partial, '\n')
+		if end < 0 {
+			return len(p), nil
+		}
+		w.mu.Lock()
+		_, err := fmt.Fprintf(w.out, "%s%s", w.prefix, w.partial[:end+1])
+		w.mu.Unlock()
+		w.partial = w.partial[end+1:]
+		if err != nil {
+			return len(p), err
+		}
+	}
+}
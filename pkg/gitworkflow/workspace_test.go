@@ -0,0 +1,166 @@
+package gitworkflow
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoadWorkspaceFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, WorkspaceFileName)
+	if err := os.WriteFile(path, []byte("repos: [api, ../web, /src/mobile]\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write workspace file: %v", err)
+	}
+
+	config, err := LoadWorkspaceFile(path)
+	if err != nil {
+		t.Fatalf("LoadWorkspaceFile() unexpected error: %v", err)
+	}
+	want := []string{filepath.Join(dir, "api"), filepath.Join(filepath.Dir(dir), "web"), "/src/mobile"}
+	if strings.Join(config.Repos, " ") != strings.Join(want, " ") {
+		t.Errorf("Repos = %v, want %v", config.Repos, want)
+	}
+	if config.Parallelism != DefaultWorkspaceParallelism {
+		t.Errorf("Parallelism = %d, want the default %d", config.Parallelism, DefaultWorkspaceParallelism)
+	}
+}
+
+func TestLoadWorkspaceFileRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		wantErr  string
+	}{
+		{name: "no repos", contents: "parallelism: 2\n", wantErr: "at least one repository"},
+		{name: "duplicate repo", contents: "repos: [api, ./api]\n", wantErr: "listed twice"},
+		{name: "negative parallelism", contents: "repos: [api]\nparallelism: -1\n", wantErr: "parallelism -1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), WorkspaceFileName)
+			if err := os.WriteFile(path, []byte(tt.contents), 0o644); err != nil {
+				t.Fatalf("Failed to write workspace file: %v", err)
+			}
+			if _, err := LoadWorkspaceFile(path); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadWorkspaceFile() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// workspaceRepos returns n repositories named repo-0, repo-1, ... on main,
+// each with its own RecordingExecutor
+func workspaceRepos(n int) ([]WorkspaceRepo, []*RecordingExecutor) {
+	var repos []WorkspaceRepo
+	var executors []*RecordingExecutor
+	for i := 0; i < n; i++ {
+		executor := NewRecordingExecutor().
+			OnOutput("git rev-parse --abbrev-ref HEAD", "W-7-search\n").
+			OnOutput("git rev-parse --verify HEAD", "1111111aaaa\n")
+		repos = append(repos, WorkspaceRepo{Name: fmt.Sprintf("repo-%d", i), Manager: testManager(executor)})
+		executors = append(executors, executor)
+	}
+	return repos, executors
+}
+
+func TestWorkspaceRunBoundsParallelism(t *testing.T) {
+	repos, _ := workspaceRepos(6)
+	var running, most int32
+	results, err := NewWorkspace(repos, 2).Run(func(wm *WorkflowManager) (string, error) {
+		now := atomic.AddInt32(&running, 1)
+		for {
+			seen := atomic.LoadInt32(&most)
+			if now <= seen || atomic.CompareAndSwapInt32(&most, seen, now) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return "done", nil
+	})
+	if err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+	if most > 2 {
+		t.Errorf("Expected at most 2 repositories at once, got %d", most)
+	}
+	for i, result := range results {
+		if result.Repo != fmt.Sprintf("repo-%d", i) || result.Summary != "done" {
+			t.Errorf("Result %d = %+v, want repo-%d done", i, result, i)
+		}
+	}
+}
+
+func TestWorkspaceRunReportsFailures(t *testing.T) {
+	repos, executors := workspaceRepos(3)
+	executors[1].OnFailure("git push -u origin W-7-search", 1, "fatal: Authentication failed")
+
+	results, err := NewWorkspace(repos, 0).PushStory(PushOptions{})
+	var workspaceErr *WorkspaceError
+	if !errors.As(err, &workspaceErr) || err.Error() != "1 of 3 repositories failed: repo-1" {
+		t.Fatalf("PushStory() error = %v, want repo-1 to fail", err)
+	}
+	if !errors.Is(results[1].Err, ErrAuthFailed) {
+		t.Errorf("Expected repo-1 to fail with ErrAuthFailed, got %v", results[1].Err)
+	}
+	for _, i := range []int{0, 2} {
+		if results[i].Err != nil || results[i].Summary != "pushed W-7-search" {
+			t.Errorf("Result %d = %+v, want pushed", i, results[i])
+		}
+	}
+}
+
+func TestWorkspaceStartStoryAndTag(t *testing.T) {
+	repos, executors := workspaceRepos(2)
+	ws := NewWorkspace(repos, 0)
+
+	results, err := ws.StartStory("7", "Search", StartOptions{})
+	if err != nil {
+		t.Fatalf("StartStory() unexpected error: %v", err)
+	}
+	if results[0].Summary != "created and switched to W-7-search" {
+		t.Errorf("Unexpected summary %q", results[0].Summary)
+	}
+	if _, err := ws.Tag("v1.2.0", "Release 1.2.0", true); err != nil {
+		t.Fatalf("Tag() unexpected error: %v", err)
+	}
+
+	for i, executor := range executors {
+		assertCommands(t, executor, []string{
+			"git checkout main",
+			"git pull origin main",
+			"git checkout -b W-7-search",
+			"git rev-parse --verify HEAD",
+			"git config branch.W-7-search.vamosBase 1111111aaaa",
+			"git tag -a v1.2.0 -m Release 1.2.0",
+			"git push origin v1.2.0",
+		})
+		if t.Failed() {
+			t.Fatalf("Unexpected commands in repo-%d", i)
+		}
+	}
+}
+
+func TestWorkspaceDryRunPrefixesOutput(t *testing.T) {
+	repos, _ := workspaceRepos(2)
+	ws := NewWorkspace(repos, 1)
+	var out bytes.Buffer
+	ws.EnableDryRun(&out)
+
+	if _, err := ws.Tag("v1.2.0", "Release 1.2.0", false); err != nil {
+		t.Fatalf("Tag() unexpected error: %v", err)
+	}
+	for _, want := range []string{"[repo-0] would run: git tag -a v1.2.0 -m \"Release 1.2.0\"\n", "[repo-1] would run: git tag"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected the output to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
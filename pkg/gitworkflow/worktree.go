@@ -0,0 +1,160 @@
+package gitworkflow
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Worktree is a working tree of the repository as listed by git worktree list
+type Worktree struct {
+	Path string `json:"path"`
+	// Branch is the checked out branch, or empty when HEAD is detached
+	Branch string `json:"branch,omitempty"`
+	Head   string `json:"head"`
+	// StoryID is the story Branch belongs to, e.g. "W-123", or empty
+	StoryID string `json:"story_id,omitempty"`
+	// Main is the working tree the repository was cloned into, which cannot be removed
+	Main bool `json:"main"`
+	// Current is the working tree the command runs in
+	Current  bool `json:"current"`
+	Locked   bool `json:"locked"`
+	Prunable bool `json:"prunable"`
+}
+
+// WorktreeRemoveOptions controls RemoveWorktree
+type WorktreeRemoveOptions struct {
+	// Force removes the worktree even if it has uncommitted changes, which are lost
+	Force bool
+}
+
+// StartStoryInWorktree creates a new story branch from the latest remote base
+// branch and checks it out in a new worktree next to the main one, e.g.
+// ../app-W-123-search for a repository in ../app. The current working tree is
+// left untouched, so uncommitted changes in it stay where they are.
+func (wm *WorkflowManager) StartStoryInWorktree(storyID string, description string) (*Worktree, error) {
+	branchName, err := wm.StoryBranchName(storyID, description)
+	if err != nil {
+		return nil, err
+	}
+	upstream, err := wm.fetchBaseBranch()
+	if err != nil {
+		return nil, err
+	}
+	worktrees, err := wm.ListWorktrees()
+	if err != nil {
+		return nil, err
+	}
+	if len(worktrees) == 0 {
+		return nil, fmt.Errorf("git worktree list returned no working trees")
+	}
+
+	// The first worktree listed is always the main one
+	mainPath := worktrees[0].Path
+	path := filepath.Join(filepath.Dir(mainPath), filepath.Base(mainPath)+"-"+strings.ReplaceAll(branchName, "/", "-"))
+	wm.logf("creating %s from %s in %s", branchName, upstream, path)
+	// --no-track keeps the base branch from becoming the story branch's upstream
+	if _, err := wm.git("worktree", "add", "--no-track", "-b", branchName, path, upstream); err != nil {
+		return nil, fmt.Errorf("failed to create worktree for %s: %w", branchName, err)
+	}
+	start, err := wm.gitOutput("rev-parse", "--verify", upstream)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", upstream, err)
+	}
+	if err := wm.recordStoryStart(branchName, start); err != nil {
+		return nil, err
+	}
+
+	id, _ := wm.StoryID(branchName)
+	return &Worktree{Path: path, Branch: branchName, StoryID: id}, nil
+}
+
+// ListWorktrees returns the working trees of the repository, the main one first
+func (wm *WorkflowManager) ListWorktrees() ([]Worktree, error) {
+	output, err := wm.gitOutput("worktree", "list", "--porcelain")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list worktrees: %w", err)
+	}
+	current, err := wm.gitOutput("rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the current worktree: %w", err)
+	}
+
+	var worktrees []Worktree
+	for _, record := range strings.Split(output, "\n\n") {
+		var worktree Worktree
+		for _, line := range strings.Split(strings.TrimSpace(record), "\n") {
+			key, value, _ := strings.Cut(line, " ")
+			switch key {
+			case "worktree":
+				worktree.Path = value
+			case "HEAD":
+				worktree.Head = value
+			case "branch":
+				worktree.Branch = strings.TrimPrefix(value, "refs/heads/")
+			case "locked":
+				worktree.Locked = true
+			case "prunable":
+				worktree.Prunable = true
+			}
+		}
+		if worktree.Path == "" {
+			continue
+		}
+		worktree.Main = len(worktrees) == 0
+		worktree.Current = filepath.Clean(worktree.Path) == filepath.Clean(current)
+		worktree.StoryID, _ = wm.StoryID(worktree.Branch)
+		worktrees = append(worktrees, worktree)
+	}
+	return worktrees, nil
+}
+
+// RemoveWorktree removes the worktree of the story with storyID, which may be
+// given with or without the branch prefix. The story branch itself is kept;
+// delete it with FinishStory once it is merged. The main worktree and the one
+// the command runs in are never removed.
+func (wm *WorkflowManager) RemoveWorktree(storyID string, options WorktreeRemoveOptions) (*Worktree, error) {
+	if !strings.HasPrefix(storyID, wm.config.Branch.Prefix) {
+		storyID = wm.config.Branch.Prefix + storyID
+	}
+	worktrees, err := wm.ListWorktrees()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []*Worktree
+	var paths []string
+	for i := range worktrees {
+		if worktrees[i].StoryID == storyID {
+			matches = append(matches, &worktrees[i])
+			paths = append(paths, worktrees[i].Path)
+		}
+	}
+	switch {
+	case len(matches) == 0:
+		return nil, fmt.Errorf("no worktree found for story %s; run 'vamosGitWF worktree-list' to see them", storyID)
+	case len(matches) > 1:
+		return nil, fmt.Errorf("story %s has several worktrees: %s; remove the one you want with 'git worktree remove'", storyID, strings.Join(paths, ", "))
+	}
+
+	worktree := matches[0]
+	switch {
+	case worktree.Main:
+		return nil, fmt.Errorf("%s is the main worktree and cannot be removed", worktree.Path)
+	case worktree.Current:
+		return nil, fmt.Errorf("%s is the current worktree; run the command from another worktree", worktree.Path)
+	}
+
+	args := []string{"worktree", "remove"}
+	if options.Force {
+		args = append(args, "--force")
+	}
+	if _, err := wm.git(append(args, worktree.Path)...); err != nil {
+		if errors.Is(err, ErrDirtyWorktree) {
+			return nil, refused(ErrDirtyWorktree, "%s has uncommitted changes; commit or discard them, or remove it with --force", worktree.Path)
+		}
+		return nil, fmt.Errorf("failed to remove worktree %s: %w", worktree.Path, err)
+	}
+	return worktree, nil
+}
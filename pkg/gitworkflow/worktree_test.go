@@ -0,0 +1,126 @@
+package gitworkflow
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const worktreeListCommand = "git worktree list --porcelain"
+
+// worktreeRepo returns a manager running in the worktree at current, with the
+// main worktree /src/app on main, /src/app-W-1-login on W-1-login, a detached
+// worktree and /src/app-W-2-search on W-2-search
+func worktreeRepo(current string) (*WorkflowManager, *RecordingExecutor) {
+	executor := NewRecordingExecutor().
+		OnOutput(worktreeListCommand, strings.Join([]string{
+			"worktree /src/app\nHEAD 1111111aaaa\nbranch refs/heads/main\n",
+			"worktree /src/app-W-1-login\nHEAD 2222222bbbb\nbranch refs/heads/W-1-login\n",
+			"worktree /tmp/bisect\nHEAD 3333333cccc\ndetached\n",
+			"worktree /src/app-W-2-search\nHEAD 4444444dddd\nbranch refs/heads/W-2-search\nlocked\n",
+		}, "\n")).
+		OnOutput("git rev-parse --show-toplevel", current+"\n").
+		OnOutput("git rev-parse --verify origin/main", "1111111aaaa\n")
+	return testManager(executor), executor
+}
+
+func TestListWorktrees(t *testing.T) {
+	wm, _ := worktreeRepo("/src/app-W-1-login")
+	worktrees, err := wm.ListWorktrees()
+	if err != nil {
+		t.Fatalf("ListWorktrees() unexpected error: %v", err)
+	}
+	if len(worktrees) != 4 {
+		t.Fatalf("Expected 4 worktrees, got %+v", worktrees)
+	}
+
+	want := []Worktree{
+		{Path: "/src/app", Branch: "main", Head: "1111111aaaa", Main: true},
+		{Path: "/src/app-W-1-login", Branch: "W-1-login", Head: "2222222bbbb", StoryID: "W-1", Current: true},
+		{Path: "/tmp/bisect", Head: "3333333cccc"},
+		{Path: "/src/app-W-2-search", Branch: "W-2-search", Head: "4444444dddd", StoryID: "W-2", Locked: true},
+	}
+	for i := range want {
+		if worktrees[i] != want[i] {
+			t.Errorf("Worktree %d = %+v, want %+v", i, worktrees[i], want[i])
+		}
+	}
+}
+
+func TestStartStoryInWorktree(t *testing.T) {
+	wm, executor := worktreeRepo("/src/app")
+	worktree, err := wm.StartStoryInWorktree("3", "Dark mode")
+	if err != nil {
+		t.Fatalf("StartStoryInWorktree() unexpected error: %v", err)
+	}
+	if worktree.Path != "/src/app-W-3-dark-mode" || worktree.Branch != "W-3-dark-mode" || worktree.StoryID != "W-3" {
+		t.Errorf("Unexpected worktree: %+v", worktree)
+	}
+	assertCommands(t, executor, []string{
+		"git fetch origin",
+		worktreeListCommand,
+		"git rev-parse --show-toplevel",
+		"git worktree add --no-track -b W-3-dark-mode /src/app-W-3-dark-mode origin/main",
+		"git rev-parse --verify origin/main",
+		"git config branch.W-3-dark-mode.vamosBase 1111111aaaa",
+	})
+}
+
+func TestRemoveWorktree(t *testing.T) {
+	wm, executor := worktreeRepo("/src/app")
+	worktree, err := wm.RemoveWorktree("1", WorktreeRemoveOptions{})
+	if err != nil {
+		t.Fatalf("RemoveWorktree() unexpected error: %v", err)
+	}
+	if worktree.Path != "/src/app-W-1-login" {
+		t.Errorf("Unexpected worktree: %+v", worktree)
+	}
+	if last := executor.Commands[len(executor.Commands)-1]; last != "git worktree remove /src/app-W-1-login" {
+		t.Errorf("Expected the worktree to be removed, got %q", last)
+	}
+
+	wm, executor = worktreeRepo("/src/app")
+	if _, err := wm.RemoveWorktree("W-2", WorktreeRemoveOptions{Force: true}); err != nil {
+		t.Fatalf("RemoveWorktree() unexpected error: %v", err)
+	}
+	if last := executor.Commands[len(executor.Commands)-1]; last != "git worktree remove --force /src/app-W-2-search" {
+		t.Errorf("Expected the worktree to be force removed, got %q", last)
+	}
+}
+
+func TestRemoveWorktreeRefuses(t *testing.T) {
+	tests := []struct {
+		name    string
+		current string
+		storyID string
+		wantErr string
+	}{
+		{name: "unknown story", current: "/src/app", storyID: "9", wantErr: "no worktree found for story W-9"},
+		{name: "current worktree", current: "/src/app-W-1-login", storyID: "W-1", wantErr: "is the current worktree"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wm, executor := worktreeRepo(tt.current)
+			if _, err := wm.RemoveWorktree(tt.storyID, WorktreeRemoveOptions{}); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("RemoveWorktree() error = %v, want %q", err, tt.wantErr)
+			}
+			for _, command := range executor.Commands {
+				if strings.HasPrefix(command, "git worktree remove") {
+					t.Errorf("Expected nothing to be removed, got %q", command)
+				}
+			}
+		})
+	}
+}
+
+func TestRemoveWorktreeWithChanges(t *testing.T) {
+	wm, executor := worktreeRepo("/src/app")
+	executor.OnFailure("git worktree remove /src/app-W-1-login", 128,
+		"fatal: '/src/app-W-1-login' contains modified or untracked files, use --force to delete it")
+
+	_, err := wm.RemoveWorktree("W-1", WorktreeRemoveOptions{})
+	if !errors.Is(err, ErrDirtyWorktree) || !strings.Contains(err.Error(), "remove it with --force") {
+		t.Errorf("RemoveWorktree() error = %v, want ErrDirtyWorktree", err)
+	}
+}